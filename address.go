@@ -3,7 +3,6 @@ package sms
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"regexp"
@@ -122,8 +121,12 @@ func (a *Address) UnmarshalJSON(b []byte) (e error) {
 
 	if len(al.Addr) == 0 {
 		a.Addr = nil
-	} else if a.TON == 0x05 {
-		a.Addr, e = StringToGSM7bit(al.Addr)
+	} else if a.TON == TypeAlphanumeric {
+		var s GSM7bitString
+		if s, e = StringToGSM7bit(al.Addr); e == nil && s.Length() > 11 {
+			e = ErrInvalidLength
+		}
+		a.Addr = s
 	} else {
 		a.Addr, e = teldata.ParseTBCD(al.Addr)
 	}
@@ -151,21 +154,40 @@ func (a Address) RegexpMatch(re *regexp.Regexp) bool {
 	return re.MatchString(a.Addr.String())
 }
 
-// Marshal generate binary data and semi-octet length of this Address
-func (a Address) Marshal() (l byte, b []byte) {
-	switch v := a.Addr.(type) {
+// Marshal generate binary data and semi-octet length of this Address.
+// Address that is longer than 20 semi-octets is not truncated but
+// returns ErrInvalidLength.
+func (a Address) Marshal() (l byte, b []byte, e error) {
+	if a.Addr != nil && a.Addr.Length() > 20 {
+		e = ErrInvalidLength
+		return
+	}
+	if v, ok := a.Addr.(GSM7bitString); ok && v.Length() > 11 {
+		e = ErrInvalidLength
+		return
+	}
+	l, b = a.marshal()
+	return
+}
+
+func (a Address) marshal() (l byte, b []byte) {
+	l, t := a.header()
+	b = []byte{t}
+	if a.Addr != nil {
+		b = append(b, a.Addr.Bytes()...)
+	}
+	return
+}
+
+// header returns semi-octet length and type of address
+func (a Address) header() (l, t byte) {
+	switch a.Addr.(type) {
 	case teldata.TBCD:
-		if a.Addr.Length() > 20 {
-			a.Addr = teldata.TBCD(v[:10])
-		}
 		l = byte(a.Addr.Length())
 		if a.TON == TypeAlphanumeric {
 			a.TON = TypeUnknown
 		}
 	case GSM7bitString:
-		if a.Addr.Length() > 11 {
-			a.Addr = v.trim(11)
-		}
 		l = byte(a.Addr.Length() * 7 / 4)
 		if a.Addr.Length()*7%4 != 0 {
 			l++
@@ -176,27 +198,70 @@ func (a Address) Marshal() (l byte, b []byte) {
 		// null addr
 	}
 
-	b = []byte{0x80}
-	b[0] |= (a.TON & 0x07) << 4
-	b[0] |= a.NPI & 0x0f
+	t = 0x80
+	t |= (a.TON & 0x07) << 4
+	t |= a.NPI & 0x0f
+	return
+}
+
+// truncate returns a that is cut to the longest address
+// the decoder accepts, 20 digits or 11 characters
+func (a Address) truncate() Address {
+	switch v := a.Addr.(type) {
+	case teldata.TBCD:
+		if v.Length() > 20 {
+			a.Addr = v[:10]
+		}
+	case GSM7bitString:
+		if v.Length() > 11 {
+			a.Addr = v.trim(11)
+		}
+	}
+	return a
+}
 
+// appendTP append TP address field to dst,
+// too long address is truncated and it is reported by Validate
+func (a Address) appendTP(dst []byte) []byte {
+	a = a.truncate()
+	l, t := a.header()
+	dst = append(dst, l, t)
 	if a.Addr != nil {
-		b = append(b, a.Addr.Bytes()...)
+		dst = append(dst, a.Addr.Bytes()...)
 	}
-	return
+	return dst
+}
+
+// appendRP append RP address field to dst,
+// too long address is truncated as appendTP
+func (a Address) appendRP(dst []byte) []byte {
+	a = a.truncate()
+	_, t := a.header()
+	p := len(dst)
+	dst = append(dst, 0, t)
+	if a.Addr != nil {
+		dst = append(dst, a.Addr.Bytes()...)
+	}
+	dst[p] = byte(len(dst) - p - 1)
+	return dst
 }
 
 // UnmarshalAddress make Address from binary data and semi-octet length
 func UnmarshalAddress(l byte, b []byte) (a Address) {
+	if len(b) == 0 {
+		return
+	}
 	a.TON = (b[0] >> 4) & 0x07
 	a.NPI = b[0] & 0x0f
 
 	b = b[1:]
 	if a.TON == TypeAlphanumeric {
-		l = l * 4 / 7
-		a.Addr = UnmarshalGSM7bitString(0, int(l), b)
+		// semi-octet length is rounded up from septets,
+		// so padding bits never make an extra character
+		n := int(l) * 4 / 7
+		a.Addr = UnmarshalGSM7bitString(0, n, b).trim(n)
 	} else {
-		if l%2 == 1 {
+		if l%2 == 1 && len(b) != 0 {
 			b[len(b)-1] |= 0xf0
 		}
 		a.Addr = teldata.TBCD(b)
@@ -204,6 +269,20 @@ func UnmarshalAddress(l byte, b []byte) (a Address) {
 	return
 }
 
+// check reports the Address can be used as the field
+func (a Address) check(field string, alpha bool) error {
+	if a.TON == TypeAlphanumeric && !alpha {
+		return UnexpectedAddressTypeError{Field: field, TON: a.TON}
+	}
+	if _, ok := a.Addr.(GSM7bitString); ok && !alpha {
+		return UnexpectedAddressTypeError{Field: field, TON: TypeAlphanumeric}
+	}
+	if _, _, e := a.Marshal(); e != nil {
+		return e
+	}
+	return nil
+}
+
 func readTPAddr(r *bytes.Reader) (a Address, e error) {
 	var l byte
 	if l, e = r.ReadByte(); e != nil {
@@ -226,7 +305,7 @@ func readTPAddr(r *bytes.Reader) (a Address, e error) {
 	return
 }
 
-func readRPAddr(r *bytes.Reader) (a Address, e error) {
+func readRPAddr(r *bytes.Reader, field string) (a Address, e error) {
 	var l byte
 	if l, e = r.ReadByte(); e != nil {
 		return
@@ -245,7 +324,8 @@ func readRPAddr(r *bytes.Reader) (a Address, e error) {
 		if i != len(b) {
 			e = io.EOF
 		} else if (b[0]>>4)&0x07 == TypeAlphanumeric {
-			e = errors.New("unexpected type of number")
+			e = UnexpectedAddressTypeError{
+				Field: field, TON: TypeAlphanumeric}
 		} else {
 			a = UnmarshalAddress((l-1)*2, b)
 		}
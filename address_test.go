@@ -2,6 +2,7 @@ package sms_test
 
 import (
 	"encoding/json"
+	"errors"
 	"math/rand"
 	"testing"
 
@@ -32,7 +33,10 @@ func TestConvertAddress(t *testing.T) {
 	for i := 0; i < 1000; i++ {
 		orig := randAddress()
 		t.Logf("%s", orig)
-		l, b := orig.Marshal()
+		l, b, e := orig.Marshal()
+		if e != nil {
+			t.Fatal(e)
+		}
 		t.Logf("\nlen=%d\ndata=% x", l, b)
 		ocom := sms.UnmarshalAddress(l, b)
 		t.Logf("%s", ocom)
@@ -64,6 +68,12 @@ func randAddress() (a sms.Address) {
 	return
 }
 
+func randNumberAddress() (a sms.Address) {
+	for a = randAddress(); a.TON == sms.TypeAlphanumeric; a = randAddress() {
+	}
+	return
+}
+
 func TestNilAddr(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		orig := randAddress()
@@ -71,3 +81,108 @@ func TestNilAddr(t *testing.T) {
 		t.Logf("%s", orig)
 	}
 }
+
+func TestParseAddress(t *testing.T) {
+	p := sms.NumberPlan{
+		CountryCode:  "81",
+		TrunkPrefix:  "0",
+		IntlPrefix:   "010",
+		MaxShortCode: 5}
+	for _, c := range []struct {
+		in  string
+		ton byte
+		npi byte
+		out string
+	}{
+		{"+819012345678", sms.TypeInternational, sms.PlanISDNTelephone, "+819012345678"},
+		{"+81 90-1234-5678", sms.TypeInternational, sms.PlanISDNTelephone, "+819012345678"},
+		{"010819012345678", sms.TypeInternational, sms.PlanISDNTelephone, "+819012345678"},
+		{"09012345678", sms.TypeNational, sms.PlanISDNTelephone, "09012345678"},
+		{"12345", sms.TypeAbbreviated, sms.PlanUnknown, "12345"},
+		{"123456", sms.TypeUnknown, sms.PlanISDNTelephone, "123456"},
+		{"Operator", sms.TypeAlphanumeric, sms.PlanUnknown, "Operator"},
+	} {
+		a, e := p.Parse(c.in)
+		if e != nil {
+			t.Fatalf("parse %s failed: %s", c.in, e)
+		}
+		t.Log(a)
+		if a.TON != c.ton || a.NPI != c.npi {
+			t.Fatalf("TON/NPI mismatch for %s: %d/%d", c.in, a.TON, a.NPI)
+		}
+		if s := p.Format(a); s != c.out {
+			t.Fatalf("format mismatch for %s: %s", c.in, s)
+		}
+	}
+
+	for _, s := range []string{"", "+", "AlphanumericSender", "あ"} {
+		if _, e := p.Parse(s); e == nil {
+			t.Fatalf("invalid address %q is accepted", s)
+		}
+	}
+}
+
+func TestConvertNumberType(t *testing.T) {
+	p := sms.NumberPlan{CountryCode: "81", TrunkPrefix: "0"}
+
+	n, e := p.Parse("09012345678")
+	if e != nil {
+		t.Fatal(e)
+	}
+	i, e := p.ToInternational(n)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := p.Format(i); s != "+819012345678" {
+		t.Fatalf("international number mismatch: %s", s)
+	}
+	n2, e := p.ToNational(i)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !n.Equal(n2) {
+		t.Fatalf("national number mismatch: %s", n2)
+	}
+
+	f, _ := p.Parse("+14155550100")
+	if _, e = p.ToNational(f); e == nil {
+		t.Fatal("foreign number is converted to national")
+	}
+}
+
+func TestAlphanumericAddress(t *testing.T) {
+	for _, s := range []string{
+		"A", "ABCDEFG", "ABCDEFG@", "ABCDEFGH", "ABCDEF@", "ABCDEF€", "ABCDEFGHIJK"} {
+		a, e := sms.ParseAddress(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+		l, b, e := a.Marshal()
+		if e != nil {
+			t.Fatal(e)
+		}
+		t.Logf("\nlen=%d\ndata=% x", l, b)
+		if ocom := sms.UnmarshalAddress(l, b); !a.Equal(ocom) {
+			t.Fatalf("mismatch orig=%s ocom=%s", a, ocom)
+		}
+	}
+
+	g, _ := sms.StringToGSM7bit("ABCDEFGHIJKL")
+	a := sms.Address{TON: sms.TypeAlphanumeric, Addr: g}
+	if _, _, e := a.Marshal(); e == nil {
+		t.Fatal("too long alphanumeric address is encoded")
+	}
+}
+
+func TestAlphanumericDA(t *testing.T) {
+	p := sms.Submit{}
+	p.DA, _ = sms.ParseAddress("Operator")
+	var ve sms.ValidationError
+	if e := p.Validate(); !errors.As(e, &ve) || !hasViolation(ve, "TP-DA") {
+		t.Fatalf("alphanumeric TP-DA is accepted: %v", e)
+	}
+	var ae sms.UnexpectedAddressTypeError
+	if _, e := sms.UnmarshalTPMO(p.MarshalTP()); !errors.As(e, &ae) {
+		t.Fatalf("alphanumeric TP-DA is decoded: %v", e)
+	}
+}
@@ -0,0 +1,109 @@
+package sms_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+func benchSubmit() sms.Submit {
+	p := sms.Submit{
+		SRR: true,
+		TMR: 42,
+		DCS: &sms.GeneralDataCoding{MsgCharset: sms.CharsetGSM7bit},
+		VP:  sms.VPRelative(0xa7),
+		UD:  sms.UserData{Text: "Hello, this is a test message."}}
+	p.TI = 1
+	p.RMR = 10
+	p.SCA, _ = sms.ParseAddress("+819012345678")
+	p.DA, _ = sms.ParseAddress("+819087654321")
+	return p
+}
+
+func benchDeliver() sms.Deliver {
+	p := sms.Deliver{
+		DCS: &sms.GeneralDataCoding{MsgCharset: sms.CharsetUCS2},
+		UD: sms.UserData{
+			Text: "あいうえお",
+			UDH:  []sms.UserDataHdr{sms.ConcatenatedSM{RefNum: 1, MaxNum: 2, SeqNum: 1}}}}
+	p.TI = 1
+	p.RMR = 10
+	p.SCTS = scts(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	p.SCA, _ = sms.ParseAddress("+819012345678")
+	p.OA, _ = sms.ParseAddress("+819087654321")
+	return p
+}
+
+func TestAppend(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		for _, p := range []sms.TPDU{
+			randSubmit(), randDeliver(), randCommand(),
+			randStatusreport(), randSubmitreport(), randDeliverreport()} {
+			h := []byte{0xff}
+			if b := p.AppendTP(h); !bytes.Equal(b[1:], p.MarshalTP()) {
+				t.Fatalf("AppendTP mismatch %T", p)
+			}
+			if b := p.AppendRP(h); !bytes.Equal(b[1:], p.MarshalRP()) {
+				t.Fatalf("AppendRP mismatch %T", p)
+			}
+			if b := p.AppendCP(h); !bytes.Equal(b[1:], p.MarshalCP()) {
+				t.Fatalf("AppendCP mismatch %T", p)
+			}
+		}
+	}
+
+	// septets that leave 7 spare bits in the last octet
+	s := benchSubmit()
+	for _, txt := range []string{"1234567", "123456789012345"} {
+		s.UD.Text = txt
+		if b := s.AppendTP(nil); !bytes.Equal(b, s.MarshalTP()) {
+			t.Fatalf("AppendTP mismatch for %q", txt)
+		}
+	}
+
+	b := make([]byte, 0, 256)
+	s = benchSubmit()
+	if n := testing.AllocsPerRun(100, func() { b = s.AppendCP(b[:0]) }); n != 0 {
+		t.Errorf("Submit AppendCP allocates %f times", n)
+	}
+	d := benchDeliver()
+	if n := testing.AllocsPerRun(100, func() { b = d.AppendCP(b[:0]) }); n != 0 {
+		t.Errorf("Deliver AppendCP allocates %f times", n)
+	}
+}
+
+func BenchmarkMarshalSubmit(b *testing.B) {
+	p := benchSubmit()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.MarshalCP()
+	}
+}
+
+func BenchmarkAppendSubmit(b *testing.B) {
+	p := benchSubmit()
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = p.AppendCP(buf[:0])
+	}
+}
+
+func BenchmarkMarshalDeliver(b *testing.B) {
+	p := benchDeliver()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.MarshalCP()
+	}
+}
+
+func BenchmarkAppendDeliver(b *testing.B) {
+	p := benchDeliver()
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = p.AppendCP(buf[:0])
+	}
+}
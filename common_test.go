@@ -33,6 +33,18 @@ func randDate() time.Time {
 	return d
 }
 
+func randSCTS() sms.SCTimeStamp {
+	return scts(randDate())
+}
+
+func scts(t time.Time) sms.SCTimeStamp {
+	s, e := sms.TimeToSCTimeStamp(t)
+	if e != nil {
+		panic(e)
+	}
+	return s
+}
+
 func randVP() sms.ValidityPeriod {
 	switch rand.Int31n(4) {
 	case 1:
@@ -0,0 +1,463 @@
+package sms_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fkgi/sms"
+)
+
+// conformanceVector is annotated binary data of a PDU.
+// hex is decoded at layer in the direction given by mo,
+// fields in want must be found in JSON form of the decoded PDU,
+// and the decoded PDU must be encoded to the same binary data.
+type conformanceVector struct {
+	name  string
+	layer string
+	mo    bool
+	hex   string
+	want  string
+}
+
+var conformanceVectors = []conformanceVector{
+	// TS 23.040 9.2.2.2 SMS-SUBMIT
+	{
+		name:  "SUBMIT, relative VP, GSM 7bit (widely published PDU mode example)",
+		layer: "TP", mo: true,
+		hex: "11000b916407281553f80000aa0ae8329bfd4697d9ec37",
+		want: `{"tp-rd":false,"tp-srr":false,"tp-rp":false,"tp-mr":0,
+			"tp-da":{"ton":1,"npi":1,"addr":"46708251358"},"tp-pid":0,"tp-dcs":0,
+			"tp-vp":{"format":"relative","duration":345600000000000},
+			"tp-ud":{"text":"hellohello"}}`,
+	},
+	{
+		name:  "SUBMIT, no VP, national number, GSM 7bit",
+		layer: "TP", mo: true,
+		hex: "01050a81901032547600000" + "5c8329bfd06",
+		want: `{"tp-mr":5,"tp-da":{"ton":0,"npi":1,"addr":"0901234567"},
+			"tp-ud":{"text":"Hello"}}`,
+	},
+	{
+		name:  "SUBMIT, RD, SRR, RP, absolute VP, UCS2 with 8bit reference concatenation",
+		layer: "TP", mo: true,
+		hex: "fd2a0b911813325476f80008522113329595630a" +
+			"0500032a020130423044",
+		want: `{"tp-rd":true,"tp-srr":true,"tp-rp":true,"tp-mr":42,
+			"tp-da":{"ton":1,"npi":1,"addr":"81312345678"},"tp-dcs":8,
+			"tp-vp":{"format":"absolute"},
+			"tp-ud":{"text":"あい","hdr":[{"key":0,"value":"2a0201"}]}}`,
+	},
+	{
+		name:  "SUBMIT, enhanced VP in seconds, 8bit data with 16bit reference concatenation",
+		layer: "TP", mo: true,
+		hex: "4900048121430004423c00000000000b06080412340301deadbeef",
+		want: `{"tp-da":{"ton":0,"npi":1,"addr":"1234"},"tp-dcs":4,
+			"tp-vp":{"format":"enhanced","single":true,"duration":60000000000},
+			"tp-ud":{"text":"3q2+7w==","hdr":[{"key":8,"value":"12340301"}]}}`,
+	},
+	{
+		name:  "SUBMIT, GSM 7bit with UDH and fill bit",
+		layer: "TP", mo: true,
+		hex:  "41000b911813325476f80000090500030102019069",
+		want: `{"tp-ud":{"text":"Hi","hdr":[{"key":0,"value":"010201"}]}}`,
+	},
+
+	// TS 23.040 9.2.2.1 SMS-DELIVER
+	{
+		name:  "DELIVER, subscriber number, GSM 7bit (widely published PDU mode example)",
+		layer: "TP", mo: false,
+		hex: "040bc87238880900f10000993092516195800ae8329bfd4697d9ec37",
+		want: `{"tp-mms":false,"tp-lp":false,"tp-sri":false,"tp-rp":false,
+			"tp-oa":{"ton":4,"npi":8,"addr":"27838890001"},"tp-pid":0,"tp-dcs":0,
+			"tp-scts":"2099-03-29T15:16:59+02:00","tp-ud":{"text":"hellohello"}}`,
+	},
+	{
+		name:  "DELIVER, alphanumeric originator",
+		layer: "TP", mo: false,
+		hex: "040dd049b7f93d6d4e0100004210510103000002c834",
+		want: `{"tp-oa":{"ton":5,"npi":0,"addr":"InfoSMS"},
+			"tp-scts":"2024-01-15T10:30:00Z","tp-ud":{"text":"Hi"}}`,
+	},
+	{
+		name:  "DELIVER, SRI, class 0 UCS2, negative time zone",
+		layer: "TP", mo: false,
+		hex: "240b915155214365f70018327040810000" + "0a" + "04004f004b",
+		want: `{"tp-sri":true,"tp-oa":{"ton":1,"npi":1,"addr":"15551234567"},
+			"tp-dcs":24,"tp-scts":"2023-07-04T18:00:00-05:00",
+			"tp-ud":{"text":"OK"}}`,
+	},
+	{
+		name:  "DELIVER, RP, replace short message type 1, voicemail waiting indication",
+		layer: "TP", mo: false,
+		hex: "8404812143" + "41c8" + "42105101030000" + "00",
+		want: `{"tp-rp":true,"tp-oa":{"ton":0,"npi":1,"addr":"1234"},
+			"tp-pid":65,"tp-dcs":200}`,
+	},
+	{
+		name:  "DELIVER, MMS, GSM 7bit extension table",
+		layer: "TP", mo: false,
+		hex:  "000b915155214365f70000" + "42105101030000" + "049bf28607",
+		want: `{"tp-mms":true,"tp-ud":{"text":"€["}}`,
+	},
+
+	// TS 23.040 9.2.2.3 SMS-STATUS-REPORT
+	{
+		name:  "STATUS-REPORT, delivered, no optional parameters",
+		layer: "TP", mo: false,
+		hex: "062a0b911813325476f8" +
+			"42105101030000" + "42105101130000" + "00",
+		want: `{"tp-mms":false,"tp-srq":false,"tp-mr":42,
+			"tp-ra":{"ton":1,"npi":1,"addr":"81312345678"},
+			"tp-scts":"2024-01-15T10:30:00Z","tp-dt":"2024-01-15T10:31:00Z",
+			"tp-st":0}`,
+	},
+	{
+		name:  "STATUS-REPORT, SRQ, validity period expired, with PID, DCS and UD",
+		layer: "TP", mo: false,
+		hex: "262a0b911813325476f8" +
+			"42105101030000" + "42105101130000" + "46" +
+			"07" + "00" + "00" + "05c8329bfd06",
+		want: `{"tp-srq":true,"tp-st":70,"tp-pid":0,"tp-dcs":0,
+			"tp-ud":{"text":"Hello"}}`,
+	},
+
+	// TS 23.040 9.2.2.4 SMS-COMMAND
+	{
+		name:  "COMMAND, enquiry with SRR",
+		layer: "TP", mo: true,
+		hex: "222b00002a0b911813325476f800",
+		want: `{"tp-srr":true,"tp-mr":43,"tp-pid":0,"tp-ct":0,"tp-mn":42,
+			"tp-da":{"ton":1,"npi":1,"addr":"81312345678"}}`,
+	},
+	{
+		name:  "COMMAND, delete with command data",
+		layer: "TP", mo: true,
+		hex: "022c00022a0b911813325476f803010203",
+		want: `{"tp-srr":false,"tp-mr":44,"tp-ct":2,"tp-mn":42,
+			"tp-cd":{"text":"AQID"}}`,
+	},
+
+	// TS 23.040 9.2.2.2a SMS-SUBMIT-REPORT
+	{
+		name:  "SUBMIT-REPORT for RP-ACK",
+		layer: "TP", mo: false,
+		hex:  "01" + "00" + "42105101030000",
+		want: `{"tp-scts":"2024-01-15T10:30:00Z"}`,
+	},
+	{
+		name:  "SUBMIT-REPORT for RP-ERROR, SM rejected-duplicate SM",
+		layer: "TP", mo: false,
+		hex:  "01" + "c5" + "00" + "42105101030000",
+		want: `{"tp-fcs":197,"tp-scts":"2024-01-15T10:30:00Z"}`,
+	},
+	{
+		name:  "SUBMIT-REPORT for RP-ACK, with PID, DCS and UD",
+		layer: "TP", mo: false,
+		hex:  "01" + "07" + "42105101030000" + "00" + "00" + "02c834",
+		want: `{"tp-pid":0,"tp-dcs":0,"tp-ud":{"text":"Hi"}}`,
+	},
+
+	// TS 23.040 9.2.2.1a SMS-DELIVER-REPORT
+	{
+		name:  "DELIVER-REPORT for RP-ACK",
+		layer: "TP", mo: true,
+		hex:  "0000",
+		want: `{}`,
+	},
+	{
+		name:  "DELIVER-REPORT for RP-ERROR, error in MS",
+		layer: "TP", mo: true,
+		hex:  "00d200",
+		want: `{"tp-fcs":210}`,
+	},
+	{
+		name:  "DELIVER-REPORT for RP-ACK, with DCS and UD",
+		layer: "TP", mo: true,
+		hex:  "0006" + "00" + "02c834",
+		want: `{"tp-dcs":0,"tp-ud":{"text":"Hi"}}`,
+	},
+
+	// TS 24.011 7.3 RP messages
+	{
+		name:  "RP-DATA (MS to network) carrying SUBMIT",
+		layer: "RP", mo: true,
+		hex: "0001" + "00" + "0791447758100650" +
+			"17" + "11000b916407281553f80000aa0ae8329bfd4697d9ec37",
+		want: `{"rp-mr":1,"rp-sca":{"ton":1,"npi":1,"addr":"447785016005"},
+			"tp-ud":{"text":"hellohello"}}`,
+	},
+	{
+		name:  "RP-DATA (network to MS) carrying DELIVER",
+		layer: "RP", mo: false,
+		hex: "0102" + "0791447758100650" + "00" +
+			"1c" + "040bc87238880900f10000993092516195800ae8329bfd4697d9ec37",
+		want: `{"rp-mr":2,"rp-sca":{"ton":1,"npi":1,"addr":"447785016005"},
+			"tp-ud":{"text":"hellohello"}}`,
+	},
+	{
+		name:  "RP-ACK (MS to network) without RP-User-Data",
+		layer: "RP", mo: true,
+		hex:  "0202",
+		want: `{"rp-mr":2}`,
+	},
+	{
+		name:  "RP-ACK (MS to network) carrying DELIVER-REPORT",
+		layer: "RP", mo: true,
+		hex:  "0202" + "4102" + "0000",
+		want: `{"rp-mr":2}`,
+	},
+	{
+		name:  "RP-ACK (network to MS) without RP-User-Data",
+		layer: "RP", mo: false,
+		hex:  "0301",
+		want: `{"rp-mr":1}`,
+	},
+	{
+		name:  "RP-ACK (network to MS) carrying SUBMIT-REPORT",
+		layer: "RP", mo: false,
+		hex:  "0301" + "4109" + "010042105101030000",
+		want: `{"rp-mr":1,"tp-scts":"2024-01-15T10:30:00Z"}`,
+	},
+	{
+		name:  "RP-ERROR (MS to network), memory capacity exceeded, carrying DELIVER-REPORT",
+		layer: "RP", mo: true,
+		hex:  "0402" + "0116" + "4103" + "00d200",
+		want: `{"rp-mr":2,"rp-cs":22,"tp-fcs":210}`,
+	},
+	{
+		name:  "RP-ERROR (network to MS), congestion with diagnostic",
+		layer: "RP", mo: false,
+		hex:  "0501" + "022a01",
+		want: `{"rp-mr":1,"rp-cs":42,"diag":1}`,
+	},
+	{
+		name:  "RP-ERROR (network to MS), SM transfer rejected, carrying SUBMIT-REPORT",
+		layer: "RP", mo: false,
+		hex:  "0501" + "0115" + "410a" + "01c50042105101030000",
+		want: `{"rp-mr":1,"rp-cs":21,"tp-fcs":197}`,
+	},
+	{
+		name:  "RP-SMMA",
+		layer: "RP", mo: true,
+		hex:  "0603",
+		want: `{"rp-mr":3}`,
+	},
+
+	// TS 24.011 7.2 CP messages
+	{
+		name:  "CP-DATA (MS to network) carrying RP-DATA and SUBMIT",
+		layer: "CP", mo: true,
+		hex: "0901" + "23" + "0001" + "00" + "0791447758100650" +
+			"17" + "11000b916407281553f80000aa0ae8329bfd4697d9ec37",
+		want: `{"cp-ti":0,"rp-mr":1,"tp-da":{"ton":1,"npi":1,"addr":"46708251358"}}`,
+	},
+	{
+		name:  "CP-DATA (network to MS) carrying RP-DATA and DELIVER",
+		layer: "CP", mo: false,
+		hex: "1901" + "28" + "0102" + "0791447758100650" + "00" +
+			"1c" + "040bc87238880900f10000993092516195800ae8329bfd4697d9ec37",
+		want: `{"cp-ti":1,"rp-mr":2,"tp-oa":{"ton":4,"npi":8,"addr":"27838890001"}}`,
+	},
+	{
+		name:  "CP-DATA (MS to network) carrying RP-ACK",
+		layer: "CP", mo: true,
+		hex:  "9901" + "02" + "0202",
+		want: `{"cp-ti":9,"rp-mr":2}`,
+	},
+	{
+		name:  "CP-ACK",
+		layer: "CP", mo: false,
+		hex:  "8904",
+		want: `{"cp-ti":8}`,
+	},
+	{
+		name:  "CP-ERROR, invalid transaction identifier value",
+		layer: "CP", mo: true,
+		hex:  "891051",
+		want: `{"cp-ti":8,"cp-cs":81}`,
+	},
+}
+
+func decodeVector(layer string, mo bool, b []byte) (sms.CPDU, error) {
+	switch layer {
+	case "TP":
+		if mo {
+			return sms.UnmarshalTPMO(b)
+		}
+		return sms.UnmarshalTPMT(b)
+	case "RP":
+		if mo {
+			return sms.UnmarshalRPMO(b)
+		}
+		return sms.UnmarshalRPMT(b)
+	}
+	if mo {
+		return sms.UnmarshalCPMO(b)
+	}
+	return sms.UnmarshalCPMT(b)
+}
+
+func encodeVector(layer string, p sms.CPDU) (b, a []byte) {
+	pre := []byte{0xff}
+	switch layer {
+	case "TP":
+		tp := p.(sms.TPDU)
+		return tp.MarshalTP(), tp.AppendTP(pre)[1:]
+	case "RP":
+		rp := p.(sms.RPDU)
+		return rp.MarshalRP(), rp.AppendRP(pre)[1:]
+	}
+	return p.MarshalCP(), p.AppendCP(pre)[1:]
+}
+
+func viewVector(layer string, mo bool, b []byte) (e error) {
+	switch {
+	case layer == "TP" && mo:
+		_, e = sms.ViewTPMO(b)
+	case layer == "TP":
+		_, e = sms.ViewTPMT(b)
+	case layer == "RP" && mo:
+		_, e = sms.ViewRPMO(b)
+	case layer == "RP":
+		_, e = sms.ViewRPMT(b)
+	case mo:
+		_, e = sms.ViewCPMO(b)
+	default:
+		_, e = sms.ViewCPMT(b)
+	}
+	return
+}
+
+// jsonContains reports every field in want has same value in got
+func jsonContains(want, got interface{}) bool {
+	w, ok := want.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(want, got)
+	}
+	g, ok := got.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for k, v := range w {
+		if !jsonContains(v, g[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonValue returns JSON form of p without duration of absolute VP,
+// that depends on current time
+func jsonValue(p sms.CPDU) (v map[string]interface{}) {
+	j, _ := json.Marshal(p)
+	json.Unmarshal(j, &v)
+	if vp, ok := v["tp-vp"].(map[string]interface{}); ok && vp["format"] == "absolute" {
+		delete(vp, "duration")
+	}
+	return
+}
+
+func TestConformanceVectors(t *testing.T) {
+	for _, v := range conformanceVectors {
+		t.Run(v.name, func(t *testing.T) {
+			b, e := hex.DecodeString(v.hex)
+			if e != nil {
+				t.Fatalf("invalid vector: %s", e)
+			}
+			p, e := decodeVector(v.layer, v.mo, b)
+			if e != nil {
+				t.Fatalf("decode failed: %s", e)
+			}
+			if e = viewVector(v.layer, v.mo, b); e != nil {
+				t.Errorf("view failed: %s", e)
+			}
+			if val, ok := p.(sms.Validator); ok && v.layer != "TP" {
+				if e = val.Validate(); e != nil {
+					t.Errorf("validate failed: %s", e)
+				}
+			}
+
+			var want, got interface{}
+			if e = json.Unmarshal([]byte(v.want), &want); e != nil {
+				t.Fatalf("invalid expected value: %s", e)
+			}
+			j, e := json.Marshal(p)
+			if e != nil {
+				t.Fatalf("JSON encode failed: %s", e)
+			}
+			json.Unmarshal(j, &got)
+			if !jsonContains(want, got) {
+				t.Errorf("unexpected value\nwant: %s\ngot:  %s",
+					strings.Join(strings.Fields(v.want), ""), j)
+			}
+
+			m, a := encodeVector(v.layer, p)
+			if !bytes.Equal(m, b) {
+				t.Errorf("marshal mismatch\nwant: % x\ngot:  % x", b, m)
+			}
+			if !bytes.Equal(a, b) {
+				t.Errorf("append mismatch\nwant: % x\ngot:  % x", b, a)
+			}
+		})
+	}
+}
+
+// TestConformanceRoundTrip checks decoding and encoding of
+// randomly generated PDUs in every layer are inverse each other
+func TestConformanceRoundTrip(t *testing.T) {
+	type gen struct {
+		name   string
+		mo     bool
+		layers []string
+		rand   func() sms.CPDU
+	}
+	tprpcp := []string{"TP", "RP", "CP"}
+	rpcp := []string{"RP", "CP"}
+	gens := []gen{
+		{"SUBMIT", true, tprpcp, func() sms.CPDU { return randSubmit() }},
+		{"DELIVER", false, tprpcp, func() sms.CPDU { return randDeliver() }},
+		{"COMMAND", true, tprpcp, func() sms.CPDU { return randCommand() }},
+		{"STATUS-REPORT", false, tprpcp, func() sms.CPDU { return randStatusreport() }},
+		{"SUBMIT-REPORT", false, tprpcp, func() sms.CPDU { return randSubmitreport() }},
+		{"DELIVER-REPORT", true, tprpcp, func() sms.CPDU { return randDeliverreport() }},
+		{"RP-ACK MO", true, rpcp, func() sms.CPDU { return randRPAckMO() }},
+		{"RP-ACK MT", false, rpcp, func() sms.CPDU { return randRPAckMT() }},
+		{"RP-ERROR MO", true, rpcp, func() sms.CPDU { return randRPErrorMO() }},
+		{"RP-ERROR MT", false, rpcp, func() sms.CPDU { return randRPErrorMT() }},
+		{"RP-SMMA", true, rpcp, func() sms.CPDU { return randMemoryAvailable() }},
+	}
+
+	for _, g := range gens {
+		for _, l := range g.layers {
+			t.Run(fmt.Sprintf("%s/%s", g.name, l), func(t *testing.T) {
+				for i := 0; i < 200; i++ {
+					orig := g.rand()
+					b, _ := encodeVector(l, orig)
+					p, e := decodeVector(l, g.mo, b)
+					if e != nil {
+						t.Fatalf("decode failed: %s\n% x\n%s", e, b, orig)
+					}
+					if reflect.TypeOf(p) != reflect.TypeOf(orig) {
+						t.Fatalf("unexpected type %T, expected %T", p, orig)
+					}
+					m, a := encodeVector(l, p)
+					if !bytes.Equal(m, b) || !bytes.Equal(a, b) {
+						t.Fatalf("re-encode mismatch\norig: % x\nnext: % x\n%s",
+							b, m, orig)
+					}
+					if l != "CP" {
+						continue
+					}
+					if j1, j2 := jsonValue(orig), jsonValue(p); !reflect.DeepEqual(j1, j2) {
+						t.Fatalf("value mismatch\norig: %v\nnext: %v", j1, j2)
+					}
+				}
+			})
+		}
+	}
+}
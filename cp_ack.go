@@ -3,7 +3,6 @@ package sms
 import (
 	"bytes"
 	"fmt"
-	"io"
 )
 
 // CpAck is CP-ACK CPDU
@@ -13,11 +12,19 @@ type CpAck struct {
 
 // MarshalCP output byte data of this CPDU
 func (d CpAck) MarshalCP() []byte {
-	b := make([]byte, 2)
-	b[0] = (d.TI & 0x0f) << 4
-	b[0] |= 0x09
-	b[1] = 0x04
-	return b
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d CpAck) AppendCP(dst []byte) []byte {
+	return append(dst, (d.TI&0x0f)<<4|0x09, 0x04)
+}
+
+// Validate check consistency of field values and returns ValidationError
+func (d CpAck) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	return e.result()
 }
 
 // UnmarshalAck decode Ack MT from bytes
@@ -27,15 +34,20 @@ func UnmarshalAck(b []byte) (a CpAck, e error) {
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *CpAck) UnmarshalCP(b []byte) (e error) {
-	if len(b) < 2 {
-		e = io.EOF
-	} else if len(b) > 2 {
-		e = ErrInvalidLength
-	} else {
-		d.TI, e = unmarshalCpHeader(0x04, b)
+func (d *CpAck) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *CpAck) unmarshalCP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("CP", b, o)
+	if d.TI, e = unmarshalCpHeader(r, 0x04); e != nil {
+		return
 	}
-	return
+	if r.Len() != 0 && !o.trailingData() {
+		r.next("CP")
+		return r.fail(ErrInvalidLength)
+	}
+	return o.checkTrailing(r)
 }
 
 func (d CpAck) String() string {
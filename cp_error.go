@@ -3,7 +3,6 @@ package sms
 import (
 	"bytes"
 	"fmt"
-	"io"
 )
 
 func cpCauseStat(c byte) string {
@@ -38,12 +37,19 @@ type CpError struct {
 
 // MarshalCP output byte data of this CPDU
 func (d CpError) MarshalCP() []byte {
-	b := make([]byte, 3)
-	b[0] = (d.TI & 0x0f) << 4
-	b[0] |= 0x09
-	b[1] = 0x10
-	b[2] = d.CS
-	return b
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d CpError) AppendCP(dst []byte) []byte {
+	return append(dst, (d.TI&0x0f)<<4|0x09, 0x10, d.CS)
+}
+
+// Validate check consistency of field values and returns ValidationError
+func (d CpError) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	return e.result()
 }
 
 // UnmarshalError decode Ack MT from bytes
@@ -53,16 +59,24 @@ func UnmarshalError(b []byte) (a CpError, e error) {
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *CpError) UnmarshalCP(b []byte) (e error) {
-	if len(b) < 3 {
-		e = io.EOF
-	} else if len(b) > 3 {
-		e = ErrInvalidLength
-	} else {
-		d.TI, e = unmarshalCpHeader(0x10, b)
-		d.CS = b[2]
+func (d *CpError) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *CpError) unmarshalCP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("CP", b, o)
+	if d.TI, e = unmarshalCpHeader(r, 0x10); e != nil {
+		return
 	}
-	return
+	r.next("CP-Cause")
+	if d.CS, e = r.ReadByte(); e != nil {
+		return r.fail(e)
+	}
+	if r.Len() != 0 && !o.trailingData() {
+		r.next("CP")
+		return r.fail(ErrInvalidLength)
+	}
+	return o.checkTrailing(r)
 }
 
 func (d CpError) String() string {
@@ -1,9 +1,7 @@
 package sms
 
 import (
-	"bytes"
 	"fmt"
-	"io"
 	"time"
 )
 
@@ -33,6 +31,7 @@ func cpTIStat(ti byte) string {
 // CPDU represents a SMS CP PDU
 type CPDU interface {
 	MarshalCP() []byte
+	AppendCP([]byte) []byte
 	fmt.Stringer
 }
 
@@ -44,104 +43,143 @@ type UnmarshalerCP interface {
 
 // UnmarshalCPMO parse byte data to CPDU.
 func UnmarshalCPMO(b []byte) (CPDU, error) {
+	return unmarshalCPMO(b, newDecoder(nil))
+}
+
+// UnmarshalCPMOWithOptions is UnmarshalCPMO with lenient decoding by DecodeOptions o,
+// and it returns violations that are ignored by o.
+func UnmarshalCPMOWithOptions(b []byte, o *DecodeOptions) (CPDU, []Violation, error) {
+	d := newDecoder(o)
+	p, e := unmarshalCPMO(b, d)
+	return p, d.warnings, e
+}
+
+func unmarshalCPMO(b []byte, op *decoder) (CPDU, error) {
 	if len(b) < 2 {
-		return nil, io.EOF
+		_, e := unmarshalCpHeader(newFieldReader("CP", b, op), 0x01)
+		return nil, e
 	}
 	switch b[1] {
 	case 0x01:
 		var c cpData
-		var e error
-		if b, e = c.unmarshal(b); e != nil {
+		rp, e := c.unmarshal(b, op)
+		if e != nil {
 			return nil, e
 		}
-		return unmarshalRPMO(b, c)
+		p, e := unmarshalRPMO(rp, c, op)
+		return p, innerError(e, b, rp)
 	case 0x04:
-		return UnmarshalAck(b)
+		var c CpAck
+		e := c.unmarshalCP(b, op)
+		return c, e
 	case 0x10:
-		return UnmarshalError(b)
+		var c CpError
+		e := c.unmarshalCP(b, op)
+		return c, e
 	}
-	return nil, UnknownMessageTypeError{Actual: b[1]}
+	return nil, DecodeError{Layer: "CP", Field: "CP-MTI", Offset: 1,
+		Err: UnknownMessageTypeError{Actual: b[1]}}
 }
 
 // UnmarshalCPMT parse byte data to CPDU.
 func UnmarshalCPMT(b []byte) (CPDU, error) {
+	return unmarshalCPMT(b, newDecoder(nil))
+}
+
+// UnmarshalCPMTWithOptions is UnmarshalCPMT with lenient decoding by DecodeOptions o,
+// and it returns violations that are ignored by o.
+func UnmarshalCPMTWithOptions(b []byte, o *DecodeOptions) (CPDU, []Violation, error) {
+	d := newDecoder(o)
+	p, e := unmarshalCPMT(b, d)
+	return p, d.warnings, e
+}
+
+func unmarshalCPMT(b []byte, op *decoder) (CPDU, error) {
 	if len(b) < 2 {
-		return nil, io.EOF
+		_, e := unmarshalCpHeader(newFieldReader("CP", b, op), 0x01)
+		return nil, e
 	}
 	switch b[1] {
 	case 0x01:
 		var c cpData
-		var e error
-		if b, e = c.unmarshal(b); e != nil {
+		rp, e := c.unmarshal(b, op)
+		if e != nil {
 			return nil, e
 		}
-		return unmarshalRPMT(b, c)
+		p, e := unmarshalRPMT(rp, c, op)
+		return p, innerError(e, b, rp)
 	case 0x04:
-		return UnmarshalAck(b)
+		var c CpAck
+		e := c.unmarshalCP(b, op)
+		return c, e
 	case 0x10:
-		return UnmarshalError(b)
+		var c CpError
+		e := c.unmarshalCP(b, op)
+		return c, e
 	}
-	return nil, UnknownMessageTypeError{Actual: b[1]}
+	return nil, DecodeError{Layer: "CP", Field: "CP-MTI", Offset: 1,
+		Err: UnknownMessageTypeError{Actual: b[1]}}
 }
 
 type cpData struct {
 	TI byte `json:"cp-ti"` // M / Transaction identifier
 }
 
-func (d cpData) marshal(rp []byte) []byte {
-	w := new(bytes.Buffer)
+// transactionID returns CP-TI of this CP-DATA
+func (d cpData) transactionID() byte {
+	return d.TI
+}
 
-	b := (d.TI & 0x0f) << 4
-	b |= 0x09
-	w.WriteByte(b)
-	w.WriteByte(0x01)
-	// w.WriteByte(0x01)
-	w.WriteByte(byte(len(rp)))
-	w.Write(rp)
+// appendCP append CP-DATA header to dst,
+// and returns the position of CP-User-Data
+func (d cpData) appendCP(dst []byte) ([]byte, int) {
+	dst = append(dst, (d.TI&0x0f)<<4|0x09, 0x01, 0)
+	return dst, len(dst)
+}
 
-	return w.Bytes()
+// setLength set length of the data from position p to the octet before p
+func setLength(b []byte, p int) []byte {
+	b[p-1] = byte(len(b) - p)
+	return b
 }
 
-func (d *cpData) unmarshal(b []byte) (rp []byte, e error) {
-	if d.TI, e = unmarshalCpHeader(0x01, b); e != nil {
+func (d *cpData) unmarshal(b []byte, o *decoder) (rp []byte, e error) {
+	r := newFieldReader("CP", b, o)
+	if d.TI, e = unmarshalCpHeader(r, 0x01); e != nil {
 		return
 	}
-	r := bytes.NewReader(b[2:])
-	// r := bytes.NewReader(b[3:])
 
+	r.next("CP-User-Data")
 	var tmp byte
 	if tmp, e = r.ReadByte(); e != nil {
-		return
-	}
-	rp = make([]byte, int(tmp))
-
-	var l int
-	if l, e = r.Read(rp); e != nil {
-		return
+		return nil, r.fail(e)
 	}
-	if l != len(rp) {
-		e = io.EOF
-	} else if r.Len() != 0 {
-		e = ErrExtraData
+	if rp, e = r.userData(int(tmp), o); e == nil {
+		e = o.checkTrailing(r)
 	}
 	return
 }
 
-func unmarshalCpHeader(mti byte, b []byte) (byte, error) {
-	if len(b) < 2 {
-		return 0, io.EOF
+func unmarshalCpHeader(r *fieldReader, mti byte) (byte, error) {
+	r.next("CP-PD")
+	b, e := r.ReadByte()
+	if e != nil {
+		return 0, r.fail(e)
 	}
-
-	if b[0]&0x0f != 0x09 {
-		return 0, UnexpectedMessageTypeError{
-			Expected: 0x09, Actual: b[0] & 0x0f}
+	if b&0x0f != 0x09 {
+		return 0, r.fail(UnexpectedMessageTypeError{
+			Expected: 0x09, Actual: b & 0x0f})
 	}
-	ti := b[0] >> 4
+	ti := b >> 4
 	ti &= 0x0f
 
-	if b[1] != mti {
-		return 0, UnexpectedMessageTypeError{
-			Expected: mti, Actual: b[1]}
+	r.next("CP-MTI")
+	if b, e = r.ReadByte(); e != nil {
+		return 0, r.fail(e)
+	}
+	if b != mti {
+		return 0, r.fail(UnexpectedMessageTypeError{
+			Expected: mti, Actual: b})
 	}
 	return ti, nil
 }
@@ -74,14 +74,19 @@ func UnmarshalDataCoding(b byte) DataCoding {
 	return nil
 }
 
-func readDataCoding(r *bytes.Reader) (DataCoding, error) {
+func readDataCoding(r *bytes.Reader, o *decoder) (DataCoding, error) {
 	p, e := r.ReadByte()
 	if e != nil {
 		return nil, e
 	}
 	d := UnmarshalDataCoding(p)
 	if d == nil {
-		return nil, UnknownDataCodingError{DCS: p}
+		if !o.reservedBits() {
+			return nil, UnknownDataCodingError{DCS: p}
+		}
+		// reserved coding is assumed to be GSM 7bit default alphabet
+		o.warn("TP-DCS", fmt.Sprintf("reserved value 0x%02x", p))
+		d = GeneralDataCoding{}
 	}
 	return d, nil
 }
@@ -0,0 +1,187 @@
+package sms
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DecodeOptions is options for lenient decoding.
+// nil or zero value means strict decoding.
+// Extra data after user data is handled as trailing data
+// if both AllowTrailingData and AllowWrongUDL are set.
+// DecodeOptions is not modified by decoding, and it can be shared.
+type DecodeOptions struct {
+	AllowTrailingData bool // ignore extra data after the PDU
+	AllowTruncatedUD  bool // accept user data that is shorter than its length
+	AllowWrongUDL     bool // accept user data length that is longer or shorter than data
+	AllowReservedBits bool // accept reserved values of DCS and time stamp
+}
+
+// LenientDecoding returns DecodeOptions that allows all violations
+func LenientDecoding() *DecodeOptions {
+	return &DecodeOptions{
+		AllowTrailingData: true,
+		AllowTruncatedUD:  true,
+		AllowWrongUDL:     true,
+		AllowReservedBits: true}
+}
+
+// decoder is state of a decoding with DecodeOptions
+type decoder struct {
+	DecodeOptions
+	warnings []Violation // violations that are ignored in the decoding
+	rec      *recorder
+}
+
+func newDecoder(o *DecodeOptions) *decoder {
+	d := &decoder{}
+	if o != nil {
+		d.DecodeOptions = *o
+	}
+	return d
+}
+
+func (o *decoder) warn(f, r string) {
+	if o != nil {
+		o.warnings = append(o.warnings, Violation{Field: f, Reason: r})
+	}
+}
+
+func (o *decoder) trailingData() bool {
+	return o != nil && o.AllowTrailingData
+}
+
+func (o *decoder) truncatedUD() bool {
+	return o != nil && o.AllowTruncatedUD
+}
+
+func (o *decoder) wrongUDL() bool {
+	return o != nil && o.AllowWrongUDL
+}
+
+func (o *decoder) reservedBits() bool {
+	return o != nil && o.AllowReservedBits
+}
+
+// checkTrailing returns ErrExtraData if the reader r has remaining data
+func (o *decoder) checkTrailing(r *fieldReader) error {
+	if r.Len() == 0 {
+		return nil
+	}
+	r.next(r.layer)
+	if !o.trailingData() {
+		return r.fail(ErrExtraData)
+	}
+	o.warn(r.layer, "trailing data is ignored")
+	return nil
+}
+
+// fieldReader reads PDU data with tracking the decoding field
+type fieldReader struct {
+	*bytes.Reader
+	b      []byte
+	layer  string
+	field  string
+	offset int
+
+	rec  *recorder
+	id   int
+	base int
+}
+
+func newFieldReader(l string, b []byte, o *decoder) *fieldReader {
+	r := &fieldReader{Reader: bytes.NewReader(b), b: b, layer: l}
+	if o != nil && o.rec != nil {
+		r.rec = o.rec
+		r.id = o.rec.readers
+		r.base = offsetIn(o.rec.root, b)
+		o.rec.readers++
+	}
+	return r
+}
+
+// pos returns current read position
+func (r *fieldReader) pos() int {
+	return int(r.Size()) - r.Len()
+}
+
+// next set the name of the field that is decoded from current position
+func (r *fieldReader) next(f string) {
+	r.field = f
+	r.offset = r.pos()
+	if r.rec != nil {
+		r.rec.marks = append(r.rec.marks, mark{
+			layer:  r.layer,
+			name:   f,
+			offset: r.base + r.offset,
+			end:    r.base + len(r.b),
+			reader: r.id})
+	}
+}
+
+// rename the field that is decoded now
+func (r *fieldReader) rename(f string) {
+	r.field = f
+	if r.rec != nil {
+		r.rec.marks[len(r.rec.marks)-1].name = f
+	}
+}
+
+// skip n octets of the data
+func (r *fieldReader) skip(n int) {
+	r.Seek(int64(n), io.SeekCurrent)
+}
+
+// fail wraps e with current layer, field and offset
+func (r *fieldReader) fail(e error) error {
+	if e == nil {
+		return nil
+	}
+	if _, ok := e.(DecodeError); ok {
+		return e
+	}
+	return DecodeError{Layer: r.layer, Field: r.field, Offset: r.offset, Err: e}
+}
+
+// userData reads l octets of the RP or CP user data as sub slice
+func (r *fieldReader) userData(l int, o *decoder) ([]byte, error) {
+	p := r.pos()
+	if r.Len() < l {
+		if !o.truncatedUD() {
+			return nil, r.fail(io.EOF)
+		}
+		o.warn(r.field, fmt.Sprintf("truncated %d of %d octets", r.Len(), l))
+		l = r.Len()
+	}
+	r.skip(l)
+	return r.b[p : p+l], nil
+}
+
+// offsetIn returns the position of the sub slice s in b
+func offsetIn(b, s []byte) int {
+	return cap(b) - cap(s)
+}
+
+// innerError shift offset of DecodeError e
+// that occurred in the sub slice s of b
+func innerError(e error, b, s []byte) error {
+	if de, ok := e.(DecodeError); ok {
+		de.Offset += offsetIn(b, s)
+		return de
+	}
+	return e
+}
+
+// recorder records position of the decoded fields for Dissect
+type recorder struct {
+	root    []byte
+	marks   []mark
+	readers int
+}
+
+type mark struct {
+	layer, name string
+	offset, end int
+	reader      int
+}
@@ -0,0 +1,274 @@
+package sms_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fkgi/sms"
+)
+
+// Submit with 8bit reference concatenated SM header and UCS2 text
+var lenientSubmit = []byte{
+	0x41, 0x40, 0x0b, 0x81, 0x90, 0x10, 0x32, 0x54,
+	0x76, 0xf8, 0x00, 0x08, 0x10, 0x05, 0x00, 0x03,
+	0x84, 0x0a, 0x01, 0x30, 0x42, 0x30, 0x44, 0x30,
+	0x46, 0x30, 0x48, 0x30, 0x4a}
+
+func TestDecodeTrailingData(t *testing.T) {
+	b := append(append([]byte{}, lenientSubmit...), 0xff)
+	if _, e := sms.UnmarshalTPMO(b); !errors.Is(e, sms.ErrExtraData) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+
+	o := &sms.DecodeOptions{AllowTrailingData: true}
+	p, w, e := sms.UnmarshalTPMOWithOptions(b, o)
+	if e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	if txt := p.(sms.Submit).UD.Text; txt != "あいうえお" {
+		t.Errorf("unexpected text: %s", txt)
+	}
+	if len(w) != 1 || w[0].Field != "TP" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	t.Log(w)
+}
+
+func TestDecodeSharedOptions(t *testing.T) {
+	b := append(append([]byte{}, lenientSubmit...), 0xff)
+	o := sms.LenientDecoding()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, w, e := sms.UnmarshalTPMOWithOptions(b, o); e != nil || len(w) != 1 {
+				t.Errorf("unexpected result %v %v", w, e)
+			}
+		}()
+	}
+	wg.Wait()
+	if *o != *sms.LenientDecoding() {
+		t.Errorf("options are modified %+v", o)
+	}
+}
+
+func TestDecodeTruncatedUD(t *testing.T) {
+	b := lenientSubmit[:len(lenientSubmit)-2]
+	if _, e := sms.UnmarshalTPMO(b); !errors.Is(e, io.EOF) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+
+	o := &sms.DecodeOptions{AllowTruncatedUD: true}
+	p, w, e := sms.UnmarshalTPMOWithOptions(b, o)
+	if e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	if txt := p.(sms.Submit).UD.Text; txt != "あいうえ" {
+		t.Errorf("unexpected text: %s", txt)
+	}
+	if len(w) != 1 || w[0].Field != "TP-UD" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	t.Log(w)
+
+	// truncated user data header
+	b = append([]byte{}, lenientSubmit[:16]...)
+	b[12] = 3
+	if _, e := sms.UnmarshalTPMO(b); !errors.Is(e, sms.ErrInvalidLength) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	if _, _, e := sms.UnmarshalTPMOWithOptions(b, sms.LenientDecoding()); e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+}
+
+func TestDecodeWrongUDL(t *testing.T) {
+	b := append([]byte{}, lenientSubmit...)
+	b[12] = 0x0e
+	if _, e := sms.UnmarshalTPMO(b); !errors.Is(e, sms.ErrExtraData) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+
+	o := &sms.DecodeOptions{AllowWrongUDL: true}
+	p, w, e := sms.UnmarshalTPMOWithOptions(b, o)
+	if e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	if txt := p.(sms.Submit).UD.Text; txt != "あいうえお" {
+		t.Errorf("unexpected text: %s", txt)
+	}
+	if len(w) != 1 || w[0].Field != "TP-UD" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	t.Log(w)
+
+	// user data length that is longer than data
+	b[12] = 0x12
+	if _, e := sms.UnmarshalTPMO(b); !errors.Is(e, io.EOF) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	if p, w, e = sms.UnmarshalTPMOWithOptions(b, o); e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	if txt := p.(sms.Submit).UD.Text; txt != "あいうえお" {
+		t.Errorf("unexpected text: %s", txt)
+	}
+	if len(w) != 1 || w[0].Field != "TP-UD" ||
+		!strings.Contains(w[0].Reason, "mismatch") {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	t.Log(w)
+}
+
+func TestDecodeReservedBits(t *testing.T) {
+	// Submit with reserved DCS 0x0c and GSM 7bit text "hello"
+	b := []byte{
+		0x01, 0x00, 0x04, 0x81, 0x21, 0x43, 0x00, 0x0c,
+		0x05, 0xe8, 0x32, 0x9b, 0xfd, 0x06}
+	var de sms.UnknownDataCodingError
+	if _, e := sms.UnmarshalTPMO(b); !errors.As(e, &de) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+
+	o := &sms.DecodeOptions{AllowReservedBits: true}
+	p, w, e := sms.UnmarshalTPMOWithOptions(b, o)
+	if e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	if txt := p.(sms.Submit).UD.Text; txt != "hello" {
+		t.Errorf("unexpected text: %s", txt)
+	}
+	if len(w) != 1 || w[0].Field != "TP-DCS" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	t.Log(w)
+
+	// Deliver with invalid month in TP-SCTS
+	b = []byte{
+		0x00, 0x04, 0x81, 0x21, 0x43, 0x00, 0x00,
+		0x12, 0x31, 0x01, 0x00, 0x00, 0x00, 0x00,
+		0x05, 0xe8, 0x32, 0x9b, 0xfd, 0x06}
+	var te sms.InvalidTimeStampError
+	if _, e := sms.UnmarshalTPMT(b); !errors.As(e, &te) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	o = &sms.DecodeOptions{AllowReservedBits: true}
+	if _, w, e = sms.UnmarshalTPMTWithOptions(b, o); e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	if len(w) != 1 || w[0].Field != "TP-SCTS" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	t.Log(w)
+}
+
+func TestDecodeLenientLayers(t *testing.T) {
+	p, e := sms.UnmarshalTPMO(lenientSubmit)
+	if e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	s := p.(sms.Submit)
+	s.SCA, _ = sms.ParseAddress("+819012345678")
+
+	b := append(s.MarshalRP(), 0xff)
+	if _, e := sms.UnmarshalRPMO(b); !errors.Is(e, sms.ErrExtraData) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	o := sms.LenientDecoding()
+	_, w, e := sms.UnmarshalRPMOWithOptions(b, o)
+	if e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	if len(w) != 1 || w[0].Field != "RP" {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+
+	b = s.MarshalCP()
+	b = b[:len(b)-2]
+	if _, e := sms.UnmarshalCPMO(b); !errors.Is(e, io.EOF) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	if _, w, e = sms.UnmarshalCPMOWithOptions(b, o); e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	t.Log(w)
+	if len(w) != 3 {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+
+	b = append(sms.CpAck{TI: 1}.MarshalCP(), 0x00)
+	if _, e := sms.UnmarshalCPMT(b); !errors.Is(e, sms.ErrInvalidLength) {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	if _, _, e := sms.UnmarshalCPMTWithOptions(b, sms.LenientDecoding()); e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	testDecodeError := func(e error, l, f string, o int, c error) {
+		t.Log(e)
+		var de sms.DecodeError
+		if !errors.As(e, &de) {
+			t.Fatalf("unexpected error: %v", e)
+		}
+		if de.Layer != l || de.Field != f || de.Offset != o {
+			t.Errorf("unexpected position %s/%s/%d", de.Layer, de.Field, de.Offset)
+		}
+		if !errors.Is(e, c) {
+			t.Errorf("unexpected cause: %v", de.Err)
+		}
+	}
+
+	// TP-DA with too long address length
+	b := append([]byte{}, lenientSubmit...)
+	b[2] = 0x20
+	_, e := sms.UnmarshalTPMO(b)
+	testDecodeError(e, "TP", "TP-DA", 2, sms.ErrInvalidLength)
+
+	// TP-UDL over 140 octets
+	b = append([]byte{}, lenientSubmit...)
+	b[12] = 0xff
+	_, e = sms.UnmarshalTPMO(b)
+	testDecodeError(e, "TP", "TP-UDL", 12, sms.ErrInvalidLength)
+
+	// user data header length over user data
+	b = append([]byte{}, lenientSubmit...)
+	b[13] = 0x20
+	_, e = sms.UnmarshalTPMO(b)
+	testDecodeError(e, "UDH", "UDHL", 13, sms.ErrInvalidLength)
+
+	// offset in CP-DATA is counted from the head of CPDU
+	p, _ := sms.UnmarshalTPMO(lenientSubmit)
+	s := p.(sms.Submit)
+	s.SCA, _ = sms.ParseAddress("+819012345678")
+	b = s.MarshalCP()
+	rp := len(b) - len(s.MarshalRP())
+	tp := len(b) - len(lenientSubmit)
+	b[tp+12] = 0xff
+	_, e = sms.UnmarshalCPMO(b)
+	testDecodeError(e, "TP", "TP-UDL", tp+12, sms.ErrInvalidLength)
+
+	// RP-OA must be empty in MO
+	b = s.MarshalCP()
+	b[rp+2] = 0x01
+	_, e = sms.UnmarshalCPMO(b)
+	testDecodeError(e, "RP", "RP-OA", rp+2, sms.ErrInvalidLength)
+
+	// truncated CP-User-Data
+	b = s.MarshalCP()
+	_, e = sms.UnmarshalCPMO(b[:len(b)-1])
+	testDecodeError(e, "CP", "CP-User-Data", 2, io.EOF)
+
+	// unknown CP message type
+	_, e = sms.UnmarshalCPMT([]byte{0x09, 0x02})
+	var ue sms.UnknownMessageTypeError
+	testDecodeError(e, "CP", "CP-MTI", 1, e)
+	if !errors.As(e, &ue) {
+		t.Errorf("unexpected cause: %v", e)
+	}
+}
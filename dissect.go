@@ -0,0 +1,504 @@
+package sms
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Field is a dissected field of PDU data
+type Field struct {
+	Layer  string  `json:"layer"`          // CP, RP, TP or UDH
+	Name   string  `json:"name"`           // name of the field, e.g. TP-DA
+	Offset int     `json:"offset"`         // byte offset from the head of the data
+	Length int     `json:"length"`         // byte length of the field
+	Mask   byte    `json:"mask,omitempty"` // bit mask in the octet, 0 means whole octets
+	Raw    []byte  `json:"raw"`            // raw octets of the field
+	Value  string  `json:"value"`          // meaning of the field
+	Fields []Field `json:"fields,omitempty"`
+}
+
+// Dissect decode CP, RP or TP data b and returns the tree of fields.
+// Layer l is "CP", "RP" or "TP", and mo is direction of the data.
+// The data is decoded leniently, and the fields decoded before
+// the failure are returned with the error.
+func Dissect(b []byte, l string, mo bool) (f Field, e error) {
+	o := newDecoder(LenientDecoding())
+	o.rec = &recorder{root: b}
+
+	var p interface{}
+	switch l {
+	case "CP":
+		if mo {
+			p, e = unmarshalCPMO(b, o)
+		} else {
+			p, e = unmarshalCPMT(b, o)
+		}
+	case "RP":
+		if mo {
+			p, e = unmarshalRPMO(b, cpData{}, o)
+		} else {
+			p, e = unmarshalRPMT(b, cpData{}, o)
+		}
+	case "TP":
+		if mo {
+			p, e = unmarshalTPMO(b, o)
+		} else {
+			p, e = unmarshalTPMT(b, o)
+		}
+	default:
+		return Field{}, ErrUnknownLayer
+	}
+
+	d := dissector{mo: mo, pdu: p}
+	f = Field{Layer: l, Name: l, Length: len(b), Raw: b}
+	if len(b) != 0 {
+		switch l {
+		case "CP":
+			if len(b) > 1 {
+				f.Value = cpMTIStat(b[1])
+			}
+		case "RP":
+			f.Value = rpMTIStat(b[0])
+		case "TP":
+			f.Value = tpMTIStat(b[0], mo)
+		}
+	}
+	f.Fields = d.decorate(buildFields(o.rec))
+	for _, w := range o.warnings {
+		if !f.annotate(w) {
+			f.Value += fmt.Sprintf(" [%s: %s]", w.Field, w.Reason)
+		}
+	}
+	return
+}
+
+// buildFields make field tree from the recorded marks
+func buildFields(rec *recorder) (top []Field) {
+	for id := 0; id < rec.readers; id++ {
+		var ms []mark
+		for _, m := range rec.marks {
+			if m.reader == id {
+				ms = append(ms, m)
+			}
+		}
+		if len(ms) == 0 {
+			continue
+		}
+
+		var fs []Field
+		for i, m := range ms {
+			end := m.end
+			if i+1 < len(ms) {
+				end = ms[i+1].offset
+				if end <= m.offset {
+					continue
+				}
+			}
+			fs = append(fs, Field{
+				Layer:  m.layer,
+				Name:   m.name,
+				Offset: m.offset,
+				Length: end - m.offset,
+				Raw:    rec.root[m.offset:end]})
+		}
+
+		if top == nil {
+			top = fs
+		} else if p := findParent(top, ms[0].offset); p != nil {
+			p.Fields = fs
+		}
+	}
+	return
+}
+
+// findParent returns the deepest field that contains offset o
+func findParent(fs []Field, o int) *Field {
+	for i := range fs {
+		f := &fs[i]
+		if o < f.Offset || o >= f.Offset+f.Length {
+			continue
+		}
+		if c := findParent(f.Fields, o); c != nil {
+			return c
+		}
+		return f
+	}
+	return nil
+}
+
+// annotate add the warning w to the field
+func (f *Field) annotate(w Violation) bool {
+	for i := range f.Fields {
+		c := &f.Fields[i]
+		if c.Name == w.Field && c.Mask == 0 {
+			c.Value += fmt.Sprintf(" [%s]", w.Reason)
+			return true
+		}
+		if c.annotate(w) {
+			return true
+		}
+	}
+	return false
+}
+
+type dissector struct {
+	mo   bool
+	pdu  interface{}
+	rp   byte
+	vpf  byte
+	udhi bool
+}
+
+func (d *dissector) decorate(fs []Field) (r []Field) {
+	for _, f := range fs {
+		switch f.Name {
+		case "CP-PD":
+			if len(f.Raw) == 0 {
+				break
+			}
+			r = append(r,
+				bitField(f, "CP-TI", 0xf0, cpTIStat(f.Raw[0]>>4)),
+				bitField(f, "CP-PD", 0x0f, pdStat(f.Raw[0]&0x0f)))
+			continue
+		case "TP-MTI":
+			r = append(r, d.firstOctet(f)...)
+			continue
+		}
+		d.describe(&f)
+		if f.Name == "TP-UD" || f.Name == "TP-CD" {
+			f.Fields = d.userData(f)
+		} else {
+			f.Fields = d.decorate(f.Fields)
+		}
+		r = append(r, f)
+	}
+	return
+}
+
+func bitField(f Field, n string, m byte, v string) Field {
+	f.Name = n
+	f.Mask = m
+	f.Value = v
+	f.Fields = nil
+	return f
+}
+
+func (d *dissector) describe(f *Field) {
+	if len(f.Raw) == 0 {
+		f.Value = "<missing>"
+		return
+	}
+	switch f.Name {
+	case "CP-MTI":
+		f.Value = cpMTIStat(f.Raw[0])
+	case "CP-Cause":
+		f.Value = cpCauseStat(f.Raw[0])
+	case "CP-User-Data":
+		f.Value = fmt.Sprintf("length %d", f.Raw[0])
+	case "RP-MTI":
+		d.rp = f.Raw[0] & 0x07
+		f.Value = rpMTIStat(f.Raw[0])
+	case "RP-MR", "TP-MR", "TP-MN", "TP-UDL", "TP-CDL":
+		f.Value = fmt.Sprintf("%d", f.Raw[0])
+	case "RP-OA", "RP-DA":
+		if f.Raw[0] == 0 {
+			f.Value = "<nil>"
+		} else if a, e := readRPAddr(bytes.NewReader(f.Raw), f.Name); e == nil {
+			f.Value = a.String()
+		} else {
+			f.Value = e.Error()
+		}
+	case "RP-Cause":
+		if len(f.Raw) > 1 {
+			f.Value = "cause=" + rpCauseStat(f.Raw[1])
+		}
+		if f.Raw[0] == 2 && len(f.Raw) > 2 {
+			f.Value += fmt.Sprintf(", diagnostic=%d", f.Raw[2])
+		}
+	case "RP-User-Data":
+		if d.rp > 1 && len(f.Raw) > 1 {
+			f.Value = fmt.Sprintf("IEI 0x%02x, length %d", f.Raw[0], f.Raw[1])
+		} else {
+			f.Value = fmt.Sprintf("length %d", f.Raw[0])
+		}
+	case "TP-DA", "TP-OA", "TP-RA":
+		if a, e := readTPAddr(bytes.NewReader(f.Raw)); e == nil {
+			f.Value = a.String()
+		} else {
+			f.Value = e.Error()
+		}
+	case "TP-PID":
+		f.Value = pidStat(f.Raw[0])
+	case "TP-CT":
+		f.Value = CommandType(f.Raw[0]).String()
+	case "TP-DCS":
+		if c := UnmarshalDataCoding(f.Raw[0]); c != nil {
+			f.Value = c.String()
+		} else {
+			f.Value = fmt.Sprintf("reserved 0x%02x", f.Raw[0])
+		}
+	case "TP-VP":
+		switch d.vpf {
+		case 0x10:
+			f.Value = VPRelative(f.Raw[0]).String()
+		case 0x08, 0x18:
+			if len(f.Raw) != 7 {
+				f.Value = "<truncated>"
+			} else if d.vpf == 0x08 {
+				f.Value = VPEnhanced(*(*[7]byte)(f.Raw)).String()
+			} else {
+				f.Value = VPAbsolute(*(*[7]byte)(f.Raw)).String()
+			}
+		}
+	case "TP-SCTS", "TP-DT":
+		if len(f.Raw) != 7 {
+			f.Value = "<truncated>"
+		} else {
+			f.Value = SCTimeStamp(*(*[7]byte)(f.Raw)).String()
+		}
+	case "TP-ST":
+		f.Value = stStat(f.Raw[0])
+	case "TP-FCS":
+		f.Value = fcsStat(f.Raw[0])
+	case "TP-PI":
+		var s []string
+		for i, n := range []string{"TP-PID", "TP-DCS", "TP-UDL"} {
+			if f.Raw[0]&(1<<i) != 0 {
+				s = append(s, n)
+			}
+		}
+		if len(s) == 0 {
+			f.Value = "no optional parameter"
+		} else {
+			f.Value = strings.Join(s, ", ") + " present"
+		}
+	case "TP-UD", "TP-CD":
+		var u UserData
+		switch p := d.pdu.(type) {
+		case Submit:
+			u = p.UD
+		case Deliver:
+			u = p.UD
+		case StatusReport:
+			u = p.UD
+		case SubmitReport:
+			u = p.UD
+		case DeliverReport:
+			u = p.UD
+		case Command:
+			u = p.CD
+		}
+		f.Value = u.Text
+	case f.Layer:
+		f.Value = "trailing data"
+	}
+}
+
+// firstOctet split the first octet of TPDU to bit fields
+func (d *dissector) firstOctet(f Field) (r []Field) {
+	if len(f.Raw) == 0 {
+		f.Value = "<missing>"
+		return []Field{f}
+	}
+	b := f.Raw[0]
+	d.vpf = b & 0x18
+	d.udhi = b&0x40 == 0x40
+	r = append(r, bitField(f, "TP-MTI", 0x03, tpMTIStat(b, d.mo)))
+
+	switch {
+	case b&0x03 == 0x01 && d.mo:
+		r = append(r,
+			bitField(f, "TP-RD", 0x04, rdStat(b&0x04 == 0x04)),
+			bitField(f, "TP-VPF", 0x18, VPFormat(d.vpf).String()),
+			bitField(f, "TP-SRR", 0x20, srrStat(b&0x20 == 0x20)))
+	case b&0x03 == 0x00 && !d.mo:
+		r = append(r,
+			bitField(f, "TP-MMS", 0x04, mmsStat(b&0x04 != 0x04)),
+			bitField(f, "TP-LP", 0x08, lpStat(b&0x08 == 0x08)),
+			bitField(f, "TP-SRI", 0x20, sriStat(b&0x20 == 0x20)))
+	case b&0x03 == 0x02 && !d.mo:
+		r = append(r,
+			bitField(f, "TP-MMS", 0x04, mmsStat(b&0x04 != 0x04)),
+			bitField(f, "TP-LP", 0x08, lpStat(b&0x08 == 0x08)),
+			bitField(f, "TP-SRQ", 0x20, srqStat(b&0x20 == 0x20)))
+	case b&0x03 == 0x02 && d.mo:
+		r = append(r,
+			bitField(f, "TP-SRR", 0x20, srrStat(b&0x20 == 0x20)))
+	}
+	r = append(r, bitField(f, "TP-UDHI", 0x40, udhiStat(d.udhi)))
+	if b&0x03 == 0x01 && d.mo || b&0x03 == 0x00 && !d.mo {
+		r = append(r, bitField(f, "TP-RP", 0x80, rpStat(b&0x80 == 0x80)))
+	}
+	return
+}
+
+// userData split the user data header to information elements
+func (d *dissector) userData(f Field) (r []Field) {
+	if !d.udhi || len(f.Raw) == 0 {
+		return
+	}
+	l := int(f.Raw[0]) + 1
+	if l > len(f.Raw) {
+		l = len(f.Raw)
+	}
+	r = append(r, Field{
+		Layer:  "UDH",
+		Name:   "UDHL",
+		Offset: f.Offset,
+		Length: 1,
+		Raw:    f.Raw[:1],
+		Value:  fmt.Sprintf("%d", f.Raw[0])})
+
+	for i := 1; i < l; {
+		n := 2
+		if i+1 < l {
+			n += int(f.Raw[i+1])
+		}
+		if i+n > l {
+			n = l - i
+		}
+		ie := Field{
+			Layer:  "UDH",
+			Name:   fmt.Sprintf("IEI 0x%02x", f.Raw[i]),
+			Offset: f.Offset + i,
+			Length: n,
+			Raw:    f.Raw[i : i+n]}
+		if h := UnmarshalUDHs(append([]byte{byte(n)}, ie.Raw...)); len(h) != 0 && n > 1 {
+			ie.Value = h[0].String()
+		} else {
+			ie.Value = "<truncated>"
+		}
+		r = append(r, ie)
+		i += n
+	}
+
+	if l < len(f.Raw) {
+		r = append(r, Field{
+			Layer:  f.Layer,
+			Name:   "SM",
+			Offset: f.Offset + l,
+			Length: len(f.Raw) - l,
+			Raw:    f.Raw[l:],
+			Value:  f.Value})
+	}
+	return
+}
+
+// HexDump returns annotated hex dump of the field tree
+func (f Field) HexDump() string {
+	w := new(bytes.Buffer)
+	fmt.Fprintf(w, "%s: %s (%d octets)\n", f.Name, f.Value, f.Length)
+	for _, c := range f.Fields {
+		c.dump(w, "")
+	}
+	return w.String()
+}
+
+func (f Field) dump(w *bytes.Buffer, indent string) {
+	raw := f.Raw
+	if len(f.Fields) != 0 {
+		raw = raw[:f.Fields[0].Offset-f.Offset]
+	}
+
+	bits := ""
+	if f.Mask != 0 && len(raw) != 0 {
+		for i := 7; i >= 0; i-- {
+			switch {
+			case f.Mask&(1<<i) == 0:
+				bits += "."
+			case raw[0]&(1<<i) == 0:
+				bits += "0"
+			default:
+				bits += "1"
+			}
+			if i == 4 {
+				bits += " "
+			}
+		}
+	}
+
+	for i := 0; i < len(raw) || i == 0; i += 8 {
+		n := i + 8
+		if n > len(raw) {
+			n = len(raw)
+		}
+		h := fmt.Sprintf("% x", raw[i:n])
+		if i == 0 {
+			fmt.Fprintf(w, "%04x  %-23s  %-9s  %s%s: %s\n",
+				f.Offset, h, bits, indent, f.Name, f.Value)
+		} else {
+			fmt.Fprintf(w, "%04x  %s\n", f.Offset+i, h)
+		}
+	}
+	for _, c := range f.Fields {
+		c.dump(w, indent+Indent)
+	}
+}
+
+func pdStat(b byte) string {
+	if b == 0x09 {
+		return "SMS messages"
+	}
+	return fmt.Sprintf("unknown protocol discriminator %d", b)
+}
+
+func cpMTIStat(b byte) string {
+	switch b {
+	case 0x01:
+		return "CP-DATA"
+	case 0x04:
+		return "CP-ACK"
+	case 0x10:
+		return "CP-ERROR"
+	}
+	return fmt.Sprintf("unknown message type 0x%02x", b)
+}
+
+func rpMTIStat(b byte) string {
+	switch b & 0x07 {
+	case 0x00:
+		return "RP-DATA (MS to network)"
+	case 0x01:
+		return "RP-DATA (network to MS)"
+	case 0x02:
+		return "RP-ACK (MS to network)"
+	case 0x03:
+		return "RP-ACK (network to MS)"
+	case 0x04:
+		return "RP-ERROR (MS to network)"
+	case 0x05:
+		return "RP-ERROR (network to MS)"
+	case 0x06:
+		return "RP-SMMA (MS to network)"
+	}
+	return fmt.Sprintf("unknown message type 0x%02x", b)
+}
+
+func tpMTIStat(b byte, mo bool) string {
+	switch b & 0x03 {
+	case 0x00:
+		if mo {
+			return "SMS-DELIVER-REPORT"
+		}
+		return "SMS-DELIVER"
+	case 0x01:
+		if mo {
+			return "SMS-SUBMIT"
+		}
+		return "SMS-SUBMIT-REPORT"
+	case 0x02:
+		if mo {
+			return "SMS-COMMAND"
+		}
+		return "SMS-STATUS-REPORT"
+	}
+	return "reserved"
+}
+
+func udhiStat(b bool) string {
+	if b {
+		return "User data header is present"
+	}
+	return "No user data header"
+}
@@ -0,0 +1,132 @@
+package sms_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+func findField(f sms.Field, n string) (sms.Field, bool) {
+	for _, c := range f.Fields {
+		if c.Name == n {
+			return c, true
+		}
+		if r, ok := findField(c, n); ok {
+			return r, true
+		}
+	}
+	return sms.Field{}, false
+}
+
+func TestDissectCP(t *testing.T) {
+	p, e := sms.UnmarshalTPMO(lenientSubmit)
+	if e != nil {
+		t.Fatalf("decode failed: %s", e)
+	}
+	s := p.(sms.Submit)
+	s.TI = 3
+	s.RMR = 42
+	s.SCA, _ = sms.ParseAddress("+819012345678")
+	b := s.MarshalCP()
+
+	f, e := sms.Dissect(b, "CP", true)
+	if e != nil {
+		t.Fatalf("dissect failed: %s", e)
+	}
+	t.Log("\n" + f.HexDump())
+
+	tp := len(b) - len(lenientSubmit)
+	for _, c := range []struct {
+		name   string
+		offset int
+		length int
+		mask   byte
+	}{
+		{"CP-TI", 0, 1, 0xf0},
+		{"CP-MTI", 1, 1, 0},
+		{"RP-MR", 4, 1, 0},
+		{"RP-DA", 6, 8, 0},
+		{"TP-UDHI", tp, 1, 0x40},
+		{"TP-DA", tp + 2, 8, 0},
+		{"TP-DCS", tp + 11, 1, 0},
+		{"TP-UDL", tp + 12, 1, 0},
+		{"IEI 0x00", tp + 14, 5, 0},
+		{"SM", tp + 19, 10, 0},
+	} {
+		r, ok := findField(f, c.name)
+		if !ok {
+			t.Errorf("%s not found", c.name)
+			continue
+		}
+		if r.Offset != c.offset || r.Length != c.length || r.Mask != c.mask {
+			t.Errorf("%s: unexpected position %d/%d/%02x",
+				c.name, r.Offset, r.Length, r.Mask)
+		}
+	}
+	if r, _ := findField(f, "SM"); r.Value != "あいうえお" {
+		t.Errorf("unexpected text %s", r.Value)
+	}
+	if r, _ := findField(f, "RP-MR"); r.Value != "42" {
+		t.Errorf("unexpected RP-MR %s", r.Value)
+	}
+}
+
+func TestDissectStatusReport(t *testing.T) {
+	p := sms.StatusReport{
+		TMR:  10,
+		SCTS: scts(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)),
+		DT:   scts(time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC)),
+		ST:   0x00}
+	p.RA, _ = sms.ParseAddress("+819012345678")
+	b := p.MarshalTP()
+
+	f, e := sms.Dissect(b, "TP", false)
+	if e != nil {
+		t.Fatalf("dissect failed: %s", e)
+	}
+	t.Log("\n" + f.HexDump())
+	if f.Value != "SMS-STATUS-REPORT" {
+		t.Errorf("unexpected PDU %s", f.Value)
+	}
+	if r, ok := findField(f, "TP-DT"); !ok || r.Offset != 17 || r.Length != 7 {
+		t.Errorf("unexpected TP-DT %v", r)
+	}
+	if r, ok := findField(f, "TP-ST"); !ok || r.Offset != 24 {
+		t.Errorf("unexpected TP-ST %v", r)
+	}
+}
+
+func TestDissectBroken(t *testing.T) {
+	// trailing data is shown with warning
+	b := append(append([]byte{}, lenientSubmit...), 0xff)
+	f, e := sms.Dissect(b, "TP", true)
+	if e != nil {
+		t.Fatalf("dissect failed: %s", e)
+	}
+	t.Log("\n" + f.HexDump())
+	if r, ok := findField(f, "TP"); !ok || r.Offset != len(lenientSubmit) {
+		t.Errorf("unexpected trailing data %v", r)
+	}
+
+	// fields before the broken one are returned
+	b = append([]byte{}, lenientSubmit...)
+	b[2] = 0x20
+	f, e = sms.Dissect(b, "TP", true)
+	var de sms.DecodeError
+	if !errors.As(e, &de) || de.Field != "TP-DA" {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	t.Log("\n" + f.HexDump())
+	if _, ok := findField(f, "TP-MR"); !ok {
+		t.Errorf("TP-MR not found")
+	}
+	if r, ok := findField(f, "TP-DA"); !ok || r.Offset != 2 {
+		t.Errorf("unexpected TP-DA %v", r)
+	}
+
+	if _, e = sms.Dissect(b, "XX", true); e != sms.ErrUnknownLayer {
+		t.Errorf("unexpected error: %v", e)
+	}
+}
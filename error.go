@@ -51,10 +51,94 @@ func (e UnexpectedInformationElementError) Error() string {
 	return fmt.Sprintf("unexpected IE %x is not %x", e.Actual, e.Expected)
 }
 
+// InvalidAddressError show invalid text for SMS address
+type InvalidAddressError struct {
+	Addr string
+}
+
+func (e InvalidAddressError) Error() string {
+	return fmt.Sprintf("invalid address %q", e.Addr)
+}
+
+// UnexpectedAddressTypeError show the type of number is not allowed
+// for the address field
+type UnexpectedAddressTypeError struct {
+	Field string
+	TON   byte
+}
+
+func (e UnexpectedAddressTypeError) Error() string {
+	return fmt.Sprintf("unexpected type of number %d for %s", e.TON, e.Field)
+}
+
+// InvalidTimeStampError show invalid field value of time stamp
+type InvalidTimeStampError struct {
+	Field string
+	Value int
+}
+
+func (e InvalidTimeStampError) Error() string {
+	return fmt.Sprintf("invalid %s %d in time stamp", e.Field, e.Value)
+}
+
+// DecodeError show the position where the decoding failed
+type DecodeError struct {
+	Layer  string // CP, RP, TP or UDH
+	Field  string // name of the field, e.g. TP-DA
+	Offset int    // byte offset of the field from the head of the data
+	Err    error  // underlying cause
+}
+
+func (e DecodeError) Error() string {
+	return fmt.Sprintf("%s decode failed at %s (offset %d): %s",
+		e.Layer, e.Field, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying cause
+func (e DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// FailureCauseError show TP-FCS of the SubmitReport or DeliverReport
+type FailureCauseError struct {
+	FCS byte
+}
+
+func (e FailureCauseError) Error() string {
+	return fmt.Sprintf("TP-FCS %x: %s", e.FCS, fcsStat(e.FCS))
+}
+
 var (
 	// ErrInvalidLength show invalid length for SMS PDU data
 	ErrInvalidLength = errors.New("invalid data length")
 
 	// ErrExtraData show extra data for SMS PDU
 	ErrExtraData = errors.New("extra data")
+
+	// ErrInvalidValidityPeriod show the VP value can't be represented
+	ErrInvalidValidityPeriod = errors.New("invalid validity period")
+
+	// ErrUnknownLayer show the layer name is not CP, RP or TP
+	ErrUnknownLayer = errors.New("unknown layer")
+
+	// ErrNumberConversion show the address can't be converted to requested type
+	ErrNumberConversion = errors.New("address conversion failed")
+
+	// ErrNoReference show all reference numbers are outstanding
+	ErrNoReference = errors.New("no reference number available")
+
+	// ErrTooManySegments show the message needs more than 255 segments
+	ErrTooManySegments = errors.New("too many segments for concatenated SM")
+
+	// ErrTC1MExpired show CP-ACK is not received after retransmissions
+	ErrTC1MExpired = errors.New("TC1M expired")
+
+	// ErrTR1MExpired show RP-ACK is not received until TR1M expiry
+	ErrTR1MExpired = errors.New("TR1M expired")
+
+	// ErrLinkClosed show the link to peer is closed
+	ErrLinkClosed = errors.New("link closed")
+
+	// ErrUnsupportedData show the data to send is not string or []byte
+	ErrUnsupportedData = errors.New("unsupported data type")
 )
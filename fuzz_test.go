@@ -0,0 +1,236 @@
+package sms_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+// seedPDUs returns fixed PDU of each type, that make seed corpus
+// same on every run
+func seedPDUs() []sms.CPDU {
+	pid, diag := byte(0x7f), byte(0x01)
+	ts := scts(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	c := sms.Command{SRR: true, TMR: 42, CT: 0x01, MN: 42,
+		CD: sms.UserData{Text: "AQI="}}
+	c.DA, _ = sms.ParseAddress("+819087654321")
+	c.SCA, _ = sms.ParseAddress("+819012345678")
+	r := sms.StatusReport{TMR: 42, SCTS: ts, DT: ts, ST: 0x41, PID: &pid,
+		DCS: sms.GeneralDataCoding{MsgCharset: sms.CharsetGSM7bit},
+		UD:  sms.UserData{Text: "report"}}
+	r.RA, _ = sms.ParseAddress("+819087654321")
+	r.SCA, _ = sms.ParseAddress("+819012345678")
+	e := sms.RpErrorMO{CS: 41, DIAG: &diag}
+	e.RMR = 10
+	a := sms.RpAckMT{}
+	a.RMR = 10
+	m := sms.MemoryAvailable{}
+	m.RMR = 10
+	m.TI = 2
+
+	return []sms.CPDU{
+		benchSubmit(), benchDeliver(), c, r,
+		sms.SubmitReport{FCS: 0xc0, CS: 41, DIAG: &diag, SCTS: ts},
+		sms.DeliverReport{FCS: 0xd3, CS: 22, PID: &pid},
+		e, a, m}
+}
+
+func fuzzSeeds(f *testing.F) {
+	f.Add(lenientSubmit)
+	for _, p := range seedPDUs() {
+		f.Add(p.MarshalCP())
+	}
+	f.Add(sms.CpError{TI: 1, CS: 17}.MarshalCP())
+	f.Add([]byte{})
+	f.Add([]byte{0x41, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+}
+
+// reencode checks decoded PDU can be encoded without panic
+func reencode(p sms.CPDU) {
+	if p == nil {
+		return
+	}
+	p.MarshalCP()
+	_ = p.String()
+	if b, e := json.Marshal(p); e == nil {
+		json.Unmarshal(b, &map[string]interface{}{})
+	}
+	if v, ok := p.(sms.Validator); ok {
+		v.Validate()
+	}
+}
+
+func FuzzTP(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, mo := range []bool{true, false} {
+			var p sms.TPDU
+			if mo {
+				p, _ = sms.UnmarshalTPMO(b)
+			} else {
+				p, _ = sms.UnmarshalTPMT(b)
+			}
+			reencode(p)
+			if mo {
+				p, _, _ = sms.UnmarshalTPMOWithOptions(b, sms.LenientDecoding())
+			} else {
+				p, _, _ = sms.UnmarshalTPMTWithOptions(b, sms.LenientDecoding())
+			}
+			reencode(p)
+
+			var v sms.TPView
+			var e error
+			if mo {
+				v, e = sms.ViewTPMO(b)
+			} else {
+				v, e = sms.ViewTPMT(b)
+			}
+			if e == nil {
+				if a, ok := v.Addr(); ok {
+					_ = a.String()
+				}
+				v.MR()
+				v.UD()
+			}
+			if f, _ := sms.Dissect(b, "TP", mo); len(b) != 0 {
+				f.HexDump()
+			}
+		}
+	})
+}
+
+func FuzzRP(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, mo := range []bool{true, false} {
+			var p sms.RPDU
+			if mo {
+				p, _ = sms.UnmarshalRPMO(b)
+			} else {
+				p, _ = sms.UnmarshalRPMT(b)
+			}
+			reencode(p)
+			if mo {
+				p, _, _ = sms.UnmarshalRPMOWithOptions(b, sms.LenientDecoding())
+			} else {
+				p, _, _ = sms.UnmarshalRPMTWithOptions(b, sms.LenientDecoding())
+			}
+			reencode(p)
+
+			var v sms.RPView
+			var e error
+			if mo {
+				v, e = sms.ViewRPMO(b)
+			} else {
+				v, e = sms.ViewRPMT(b)
+			}
+			if e == nil {
+				if a, ok := v.SCA(); ok {
+					_ = a.String()
+				}
+				v.TP()
+			}
+			sms.Dissect(b, "RP", mo)
+		}
+	})
+}
+
+func FuzzCP(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, mo := range []bool{true, false} {
+			var p sms.CPDU
+			if mo {
+				p, _ = sms.UnmarshalCPMO(b)
+			} else {
+				p, _ = sms.UnmarshalCPMT(b)
+			}
+			reencode(p)
+			if mo {
+				p, _, _ = sms.UnmarshalCPMOWithOptions(b, sms.LenientDecoding())
+			} else {
+				p, _, _ = sms.UnmarshalCPMTWithOptions(b, sms.LenientDecoding())
+			}
+			reencode(p)
+
+			var v sms.CPView
+			var e error
+			if mo {
+				v, e = sms.ViewCPMO(b)
+			} else {
+				v, e = sms.ViewCPMT(b)
+			}
+			if e == nil {
+				v.RP()
+			}
+			sms.Dissect(b, "CP", mo)
+		}
+	})
+}
+
+func FuzzUDH(f *testing.F) {
+	f.Add([]byte{0x00, 0x03, 0x01, 0x02, 0x01})
+	f.Add([]byte{0x08, 0x04, 0x01, 0x02, 0x03, 0x01})
+	f.Add([]byte{0x00, 0x00})
+	f.Add([]byte{0x24, 0x01})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		h := sms.UnmarshalUDHs(b)
+		for _, u := range h {
+			_ = u.String()
+			u.Marshal()
+		}
+		sms.MarshalUDHs(h)
+		sms.UnmarshalGeneric(b)
+		sms.UnmarshalConcatenatedSM(b)
+		sms.UnmarshalConcatenatedSM16bit(b)
+		for o := 0; o < 8; o++ {
+			sms.UnmarshalGSM7bitString(o, len(b)*8/7, b)
+		}
+		if len(b) != 0 {
+			a := sms.UnmarshalAddress(b[0], b[1:])
+			_ = a.String()
+		}
+		sms.UnmarshalAddress(0, nil)
+	})
+}
+
+func FuzzDCS(f *testing.F) {
+	f.Add(byte(0x00), []byte(`{"charset":"UCS2"}`))
+	f.Add(byte(0xf4), []byte(`{}`))
+	f.Fuzz(func(t *testing.T, d byte, b []byte) {
+		if c := sms.UnmarshalDataCoding(d); c != nil {
+			_ = c.String()
+			c.Marshal()
+			c.Charset()
+		}
+		// user data of each coding
+		tp := []byte{0x01, 0x00, 0x00, 0x80, 0x00, d, byte(len(b))}
+		sms.UnmarshalTPMO(append(tp, b...))
+		tp[0] |= 0x40
+		sms.UnmarshalTPMO(append(tp, b...))
+	})
+}
+
+func FuzzJSON(f *testing.F) {
+	for _, p := range seedPDUs()[:6] {
+		b, _ := json.Marshal(p)
+		f.Add(b)
+	}
+	f.Add([]byte(`{"tp-ud":{"text":"a","udh":[{"key":0,"value":""}]}}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, p := range []interface{}{
+			&sms.Submit{}, &sms.Deliver{}, &sms.Command{},
+			&sms.StatusReport{}, &sms.SubmitReport{}, &sms.DeliverReport{},
+			&sms.Address{}, &sms.UserData{}, &sms.SCTimeStamp{}} {
+			if json.Unmarshal(b, p) != nil {
+				continue
+			}
+			if c, ok := p.(sms.CPDU); ok {
+				reencode(c)
+			}
+			json.Marshal(p)
+		}
+	})
+}
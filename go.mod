@@ -1,5 +1,5 @@
 module github.com/fkgi/sms
 
-go 1.17
+go 1.18
 
 require github.com/fkgi/teldata v1.0.0
\ No newline at end of file
@@ -198,6 +198,52 @@ func (s GSM7bitString) Marshal(o int) []byte {
 	return b
 }
 
+// appendGSM7bit append packed data of text s with o fill bits to dst.
+// Text is trimmed to l septets, and nothing is appended if s has
+// character that is not in GSM 7bit default alphabet.
+// It returns the number of septets with the data.
+func appendGSM7bit(dst []byte, s string, o, l int) ([]byte, int) {
+	n, end := 0, len(s)
+	for i, r := range s {
+		esc, c := getCode(r)
+		if c == 0xff {
+			return dst, 0
+		}
+		w := 1
+		if esc {
+			w = 2
+		}
+		if end != len(s) {
+			continue
+		} else if n+w > l {
+			end = i
+		} else {
+			n += w
+		}
+	}
+
+	p := len(dst)
+	for i := (o + n*7 + 7) / 8; i > 0; i-- {
+		dst = append(dst, 0)
+	}
+	b := dst[p:]
+	put := func(c byte) {
+		b[o/8] |= c << uint(o%8)
+		if o%8 > 1 {
+			b[o/8+1] = c >> uint(8-o%8)
+		}
+		o += 7
+	}
+	for _, r := range s[:end] {
+		esc, c := getCode(r)
+		if esc {
+			put(0x1b)
+		}
+		put(c)
+	}
+	return dst, n
+}
+
 func (s GSM7bitString) trim(l int) GSM7bitString {
 	r := make([]rune, 0, len(s))
 	i := 0
@@ -213,3 +259,33 @@ func (s GSM7bitString) trim(l int) GSM7bitString {
 	}
 	return GSM7bitString(r)
 }
+
+// Unpacked return unpacked byte data that has a septet in each octet,
+// as used in SMPP short_message
+func (s GSM7bitString) Unpacked() []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		esc, c := getCode(r)
+		if esc {
+			b = append(b, 0x1b)
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// UnmarshalUnpackedGSM7bitString generate GSM7bitString from
+// unpacked byte data that has a septet in each octet
+func UnmarshalUnpackedGSM7bitString(b []byte) GSM7bitString {
+	s := GSM7bitString(make([]rune, 0, len(b)))
+	for i := 0; i < len(b); i++ {
+		c := b[i] & 0x7f
+		if c == 0x1b && i+1 < len(b) {
+			i++
+			s = append(s, code[b[i]&0x0f|0x80])
+		} else {
+			s = append(s, code[c])
+		}
+	}
+	return s
+}
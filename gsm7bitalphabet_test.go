@@ -74,6 +74,28 @@ func TestGSM7bitStringByteConv(t *testing.T) {
 	}
 }
 
+func TestGSM7bitStringUnpacked(t *testing.T) {
+	s, _ := sms.StringToGSM7bit("Hi@{€}")
+	if b := s.Unpacked(); string(b) != "Hi\x00\x1b\x28\x1b\x65\x1b\x29" {
+		t.Errorf("unexpected data % x", b)
+	}
+
+	for i := 0; i < 1000; i++ {
+		org := randText(rand.Int() % 200)
+		s, e := sms.StringToGSM7bit(org)
+		if e != nil {
+			t.Fatalf("conversion failure: %s", e)
+		}
+		b := s.Unpacked()
+		if len(b) != s.Length() {
+			t.Fatalf("unpacked length %d is not %d", len(b), s.Length())
+		}
+		if r := sms.UnmarshalUnpackedGSM7bitString(b).String(); r != org {
+			t.Fatalf("\ndetect=%s", strconv.QuoteToGraphic(r))
+		}
+	}
+}
+
 var code = [128 + 16]rune{
 	'@', '£', '$', '¥', 'è', 'é', 'ù', 'ì', 'ò', 'Ç',
 	'\n', 'Ø', 'ø', '\r', 'Å', 'å', 'Δ', '_', 'Φ', 'Γ',
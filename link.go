@@ -0,0 +1,315 @@
+package sms
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// CtrlLink is lower layer link of SMC that transfers CPDU to peer
+type CtrlLink interface {
+	CtrlReq(CPDU)
+}
+
+// CtrlUser is upper layer of CtrlLink that receives CPDU from peer
+type CtrlUser interface {
+	CtrlInd(CPDU)
+}
+
+// RelayLink is lower layer link of SMR that transfers RPDU to peer
+type RelayLink interface {
+	RelayReq(RPDU) error
+}
+
+// RelayUser is upper layer of RelayLink that receives RPDU from peer
+// and returns the answer
+type RelayUser interface {
+	RelayInd(RPDU) (RPDU, error)
+}
+
+// SetLink set l as lower layer link of smc
+func (smc *SMC) SetLink(l CtrlLink) {
+	smc.CtrlReq = l.CtrlReq
+}
+
+// SetUser set u as upper layer of smc,
+// RPDU in CP-DATA is passed to u instead of SM-TL
+func (smc *SMC) SetUser(u RelayUser) {
+	smc.RelayInd = u.RelayInd
+}
+
+// RelayAborter is RelayLink that can release the connection of
+// the aborted transaction
+type RelayAborter interface {
+	AbortReq(mr byte)
+}
+
+// SetLink set l as lower layer link of smr,
+// AbortReq is also set if l is RelayAborter
+func (smr *SMR) SetLink(l RelayLink) {
+	smr.RelayReq = l.RelayReq
+	if a, ok := l.(RelayAborter); ok {
+		smr.AbortReq = a.AbortReq
+	}
+}
+
+// Impairment is fault that is injected to a direction of loopback.
+// Probability is from 0 to 1.
+type Impairment struct {
+	Loss      float64 // probability to drop the message
+	Duplicate float64 // probability to send the message twice
+	Reorder   float64 // probability to send the message after next one
+	// ReorderWait is maximum period to hold the reordered message,
+	// zero means it is held until next message
+	ReorderWait time.Duration
+	// Delay is transfer delay of each message
+	Delay time.Duration
+	// Rand is source of the probability, global source is used if nil.
+	// Seeded source makes injection deterministic, and each direction
+	// should have own source because the draws of both directions
+	// are interleaved when the source is shared.
+	Rand *rand.Rand
+}
+
+// randMutex guards Rand of Impairment that may be shared by pipes
+var randMutex sync.Mutex
+
+type pipeData struct {
+	b  []byte
+	at time.Time
+}
+
+// pipe transfers data in a direction of loopback with impairment
+type pipe struct {
+	imp     Impairment
+	deliver func([]byte)
+	done    chan struct{}
+	queue   chan pipeData
+
+	mutex sync.Mutex
+	held  [][]byte // data held for reorder
+	seq   int      // sequence number of held data
+}
+
+func newPipe(imp Impairment, done chan struct{}, deliver func([]byte)) *pipe {
+	p := &pipe{
+		imp:     imp,
+		deliver: deliver,
+		done:    done,
+		queue:   make(chan pipeData, 1024)}
+	go p.run()
+	return p
+}
+
+func (p *pipe) chance(f float64) bool {
+	if f <= 0 {
+		return false
+	}
+	if p.imp.Rand != nil {
+		randMutex.Lock()
+		defer randMutex.Unlock()
+		return p.imp.Rand.Float64() < f
+	}
+	return rand.Float64() < f
+}
+
+func (p *pipe) send(b []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.chance(p.imp.Loss) {
+		return
+	}
+	out := [][]byte{b}
+	if p.chance(p.imp.Duplicate) {
+		out = append(out, b)
+	}
+	if p.held == nil && p.chance(p.imp.Reorder) {
+		p.held = out
+		p.seq++
+		if p.imp.ReorderWait != 0 {
+			seq := p.seq
+			time.AfterFunc(p.imp.ReorderWait, func() { p.flush(seq) })
+		}
+		return
+	}
+	if p.held != nil {
+		out = append(out, p.held...)
+		p.held = nil
+		p.seq++
+	}
+	for _, b := range out {
+		p.enqueue(b)
+	}
+}
+
+// flush send held data if it is not sent yet
+func (p *pipe) flush(seq int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.seq == seq && p.held != nil {
+		for _, b := range p.held {
+			p.enqueue(b)
+		}
+		p.held = nil
+	}
+}
+
+// enqueue data b, mutex must be locked
+func (p *pipe) enqueue(b []byte) {
+	select {
+	case p.queue <- pipeData{b: b, at: time.Now()}:
+	case <-p.done:
+	}
+}
+
+func (p *pipe) run() {
+	for {
+		var d pipeData
+		select {
+		case d = <-p.queue:
+		case <-p.done:
+			return
+		}
+		if w := time.Until(d.at.Add(p.imp.Delay)); w > 0 {
+			select {
+			case <-time.After(w):
+			case <-p.done:
+				return
+			}
+		}
+		p.deliver(d.b)
+	}
+}
+
+// CtrlLoopback is in-memory link that connects MS side and
+// network side SM-CM entity. CPDU is encoded and decoded on the way.
+type CtrlLoopback struct {
+	MO Impairment // impairment from MS to network
+	MT Impairment // impairment from network to MS
+	// Drop is called for each CPDU before impairment,
+	// and the CPDU is dropped if it returns true
+	Drop func(mo bool, p CPDU) bool
+
+	mo, mt *pipe
+	done   chan struct{}
+	once   sync.Once
+}
+
+type ctrlEnd struct {
+	l  *CtrlLoopback
+	mo bool
+}
+
+func (e ctrlEnd) CtrlReq(p CPDU) {
+	if e.l.Drop != nil && e.l.Drop(e.mo, p) {
+		return
+	}
+	if e.mo {
+		e.l.mo.send(p.MarshalCP())
+	} else {
+		e.l.mt.send(p.MarshalCP())
+	}
+}
+
+// Open starts transfer between ms and nw,
+// and returns CtrlLink of MS side and network side.
+func (l *CtrlLoopback) Open(ms, nw CtrlUser) (msLink, nwLink CtrlLink) {
+	l.done = make(chan struct{})
+	l.mo = newPipe(l.MO, l.done, func(b []byte) {
+		if p, e := UnmarshalCPMO(b); e == nil {
+			nw.CtrlInd(p)
+		}
+	})
+	l.mt = newPipe(l.MT, l.done, func(b []byte) {
+		if p, e := UnmarshalCPMT(b); e == nil {
+			ms.CtrlInd(p)
+		}
+	})
+	return ctrlEnd{l: l, mo: true}, ctrlEnd{l: l, mo: false}
+}
+
+// Connect MS side ms and network side nw
+func (l *CtrlLoopback) Connect(ms, nw *SMC) {
+	m, n := l.Open(ms, nw)
+	ms.SetLink(m)
+	nw.SetLink(n)
+}
+
+// Close stops transfer, messages in transfer are discarded
+func (l *CtrlLoopback) Close() {
+	l.once.Do(func() { close(l.done) })
+}
+
+// RelayLoopback is in-memory link that connects MS side and
+// network side SM-RL entity. RPDU is encoded and decoded on the way,
+// and the answer of RelayInd is sent back to the sender.
+// RPDU is indicated one by one in sent order of each direction,
+// unless Reorder of the Impairment changes the order.
+type RelayLoopback struct {
+	MO Impairment // impairment from MS to network
+	MT Impairment // impairment from network to MS
+	// Drop is called for each RPDU before impairment,
+	// and the RPDU is dropped if it returns true
+	Drop func(mo bool, p RPDU) bool
+
+	mo, mt *pipe
+	done   chan struct{}
+	once   sync.Once
+}
+
+type relayEnd struct {
+	l  *RelayLoopback
+	mo bool
+}
+
+func (e relayEnd) RelayReq(p RPDU) error {
+	select {
+	case <-e.l.done:
+		return ErrLinkClosed
+	default:
+	}
+	if e.l.Drop != nil && e.l.Drop(e.mo, p) {
+		return nil
+	}
+	if e.mo {
+		e.l.mo.send(p.MarshalRP())
+	} else {
+		e.l.mt.send(p.MarshalRP())
+	}
+	return nil
+}
+
+// Open starts transfer between ms and nw,
+// and returns RelayLink of MS side and network side.
+func (l *RelayLoopback) Open(ms, nw RelayUser) (msLink, nwLink RelayLink) {
+	l.done = make(chan struct{})
+	msLink, nwLink = relayEnd{l: l, mo: true}, relayEnd{l: l, mo: false}
+	l.mo = newPipe(l.MO, l.done, func(b []byte) {
+		if p, e := UnmarshalRPMO(b); e == nil {
+			if a, _ := nw.RelayInd(p); a != nil {
+				nwLink.RelayReq(a)
+			}
+		}
+	})
+	l.mt = newPipe(l.MT, l.done, func(b []byte) {
+		if p, e := UnmarshalRPMT(b); e == nil {
+			if a, _ := ms.RelayInd(p); a != nil {
+				msLink.RelayReq(a)
+			}
+		}
+	})
+	return
+}
+
+// Connect MS side ms and network side nw
+func (l *RelayLoopback) Connect(ms, nw *SMR) {
+	m, n := l.Open(ms, nw)
+	ms.SetLink(m)
+	nw.SetLink(n)
+}
+
+// Close stops transfer, messages in transfer are discarded
+func (l *RelayLoopback) Close() {
+	l.once.Do(func() { close(l.done) })
+}
@@ -0,0 +1,267 @@
+package sms_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+type ctrlRecorder chan sms.CPDU
+
+func (r ctrlRecorder) CtrlInd(p sms.CPDU) {
+	r <- p
+}
+
+// transfer send n CP-ERROR that has cause 0 to n-1 from MS side,
+// and returns causes of the received CP-ERROR
+func transfer(imp sms.Impairment, n int) (cs []byte) {
+	l := &sms.CtrlLoopback{MO: imp}
+	r := make(ctrlRecorder, n*2)
+	ms, _ := l.Open(nil, r)
+	defer l.Close()
+
+	for i := 0; i < n; i++ {
+		ms.CtrlReq(sms.CpError{CS: byte(i)})
+	}
+	for {
+		select {
+		case p := <-r:
+			cs = append(cs, p.(sms.CpError).CS)
+		case <-time.After(imp.Delay + imp.ReorderWait + time.Millisecond*50):
+			return
+		}
+	}
+}
+
+func TestLoopbackImpairment(t *testing.T) {
+	// no impairment
+	cs := transfer(sms.Impairment{}, 100)
+	for i, c := range cs {
+		if c != byte(i) {
+			t.Fatalf("unexpected order %v", cs)
+		}
+	}
+	if len(cs) != 100 {
+		t.Errorf("%d messages are received", len(cs))
+	}
+
+	// loss is deterministic with seeded source
+	cs = transfer(sms.Impairment{
+		Loss: 0.3, Rand: rand.New(rand.NewSource(1))}, 100)
+	if len(cs) < 50 || len(cs) > 90 {
+		t.Errorf("%d messages are received", len(cs))
+	}
+	if c2 := transfer(sms.Impairment{
+		Loss: 0.3, Rand: rand.New(rand.NewSource(1))}, 100); !reflect.DeepEqual(cs, c2) {
+		t.Errorf("loss is not deterministic %v %v", cs, c2)
+	}
+
+	// duplication
+	cs = transfer(sms.Impairment{Duplicate: 1}, 10)
+	if !reflect.DeepEqual(cs, []byte{0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9}) {
+		t.Errorf("unexpected duplication %v", cs)
+	}
+
+	// reorder, last message is sent after ReorderWait
+	cs = transfer(sms.Impairment{
+		Reorder: 1, ReorderWait: time.Millisecond * 20}, 5)
+	if !reflect.DeepEqual(cs, []byte{1, 0, 3, 2, 4}) {
+		t.Errorf("unexpected reorder %v", cs)
+	}
+
+	// delay
+	l := &sms.CtrlLoopback{MT: sms.Impairment{Delay: time.Millisecond * 50}}
+	r := make(ctrlRecorder, 1)
+	_, nw := l.Open(r, nil)
+	defer l.Close()
+	start := time.Now()
+	nw.CtrlReq(sms.CpAck{})
+	<-r
+	if d := time.Since(start); d < time.Millisecond*50 {
+		t.Errorf("received after %s", d)
+	}
+}
+
+func TestSMCLossyLink(t *testing.T) {
+	ms := newSMC()
+	sc := newSMC()
+	for _, smc := range []*sms.SMC{ms, sc} {
+		smc.TC1M = time.Millisecond * 20
+		smc.MaxRetrans = 10
+	}
+	imp := func(seed int64) sms.Impairment {
+		return sms.Impairment{
+			Loss:        0.2,
+			Duplicate:   0.2,
+			Reorder:     0.2,
+			ReorderWait: time.Millisecond * 5,
+			Delay:       time.Millisecond,
+			Rand:        rand.New(rand.NewSource(seed))}
+	}
+	l := &sms.CtrlLoopback{MO: imp(1), MT: imp(2)}
+	l.Connect(ms, sc)
+	defer l.Close()
+
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		return sms.SubmitReport{SCTS: scts(time.Now())}, nil
+	}
+	for i := 0; i < 20; i++ {
+		a, e := ms.TranspReq(randSubmit())
+		if e != nil {
+			t.Fatalf("submit %d failed: %s", i, e)
+		}
+		if _, ok := a.(sms.SubmitReport); !ok {
+			t.Errorf("unexpected answer %T", a)
+		}
+	}
+	waitIdle(t, ms)
+	waitIdle(t, sc)
+}
+
+func TestRelayLoopbackClose(t *testing.T) {
+	ms := newSMR()
+	sc := newSMR()
+	l := &sms.RelayLoopback{}
+	l.Connect(ms, sc)
+	l.Close()
+
+	if e := ms.RelayReq(sms.MemoryAvailable{}); e != sms.ErrLinkClosed {
+		t.Errorf("unexpected error %v", e)
+	}
+	if _, e := ms.TranspReq(randSubmit()); e == nil {
+		t.Errorf("unexpected success")
+	}
+}
+
+type relayRecorder chan sms.RPDU
+
+func (r relayRecorder) RelayInd(p sms.RPDU) (sms.RPDU, error) {
+	r <- p
+	return nil, nil
+}
+
+func TestRelayLoopbackOrder(t *testing.T) {
+	l := &sms.RelayLoopback{}
+	r := make(relayRecorder, 100)
+	ms, _ := l.Open(nil, r)
+	defer l.Close()
+
+	for i := 0; i < 100; i++ {
+		p := sms.RpErrorMO{CS: 41}
+		p.RMR = byte(i)
+		ms.RelayReq(p)
+	}
+	for i := 0; i < 100; i++ {
+		select {
+		case p := <-r:
+			if mr := p.(sms.RpErrorMO).RMR; mr != byte(i) {
+				t.Fatalf("RP-MR %d is received as %d-th", mr, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%d messages are received", i)
+		}
+	}
+}
+
+func TestLoopbackSharedRand(t *testing.T) {
+	src := rand.New(rand.NewSource(1))
+	l := &sms.CtrlLoopback{
+		MO: sms.Impairment{Loss: 0.5, Rand: src},
+		MT: sms.Impairment{Loss: 0.5, Rand: src}}
+	r := make(ctrlRecorder, 200)
+	ms, nw := l.Open(r, r)
+	defer l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			ms.CtrlReq(sms.CpError{CS: byte(i)})
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		nw.CtrlReq(sms.CpError{CS: byte(i)})
+	}
+	<-done
+}
+
+func TestSMROverSMC(t *testing.T) {
+	msCM, scCM := newSMC(), newSMC()
+	msRL, scRL := newSMR(), newSMR()
+	msRL.TR1M, scRL.TR1M = time.Second, time.Second
+	msRL.SetLink(msCM)
+	msCM.SetUser(msRL)
+	scRL.SetLink(scCM)
+	scCM.SetUser(scRL)
+	mo := make(chan sms.CPDU, 100)
+	connectSMC(t, msCM, scCM, func(m bool, p sms.CPDU) bool {
+		if m {
+			mo <- p
+		}
+		return false
+	})
+
+	// MO RP-DATA and the answer
+	scRL.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		if _, ok := p.(sms.Submit); !ok {
+			t.Errorf("unexpected TPDU %T", p)
+		}
+		return sms.SubmitReport{FCS: 0xc0, CS: 41, SCTS: scts(time.Now())}, nil
+	}
+	a, e := msRL.TranspReq(randSubmit())
+	if r, ok := a.(sms.SubmitReport); e != nil || !ok || r.FCS != 0xc0 {
+		t.Fatalf("unexpected answer %v %v", a, e)
+	}
+
+	// MT RP-DATA is answered with RP-ERROR of SM-TL
+	msRL.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		return nil, sms.RpError{CS: 22}
+	}
+	if _, e = scRL.TranspReq(randDeliver()); e == nil || e.(sms.RpError).CS != 22 {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// RP-SMMA
+	scRL.MemAvailInd = func() error {
+		return nil
+	}
+	if e = msRL.MemAvailReq(); e != nil {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// cancel of SMR sends CP-ERROR
+	block := make(chan struct{})
+	defer close(block)
+	scRL.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		<-block
+		return nil, nil
+	}
+	for len(mo) != 0 {
+		<-mo
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, e = msRL.TranspReqContext(ctx, randSubmit()); !errors.Is(e, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", e)
+	}
+	for sent := false; !sent; {
+		select {
+		case p := <-mo:
+			if v, ok := p.(sms.CpError); ok {
+				if v.CS != 111 {
+					t.Errorf("unexpected CP-ERROR %v", v)
+				}
+				sent = true
+			}
+		case <-time.After(time.Second):
+			t.Fatal("CP-ERROR is not sent")
+		}
+	}
+	waitIdle(t, msCM)
+	waitIdle(t, scCM)
+}
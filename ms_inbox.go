@@ -0,0 +1,127 @@
+package sms
+
+import "sync"
+
+// ReplaceType returns replace short message type 1 to 7 of TP-PID p,
+// or 0 if p is not replace short message type.
+func ReplaceType(p byte) int {
+	if p >= 0x41 && p <= 0x47 {
+		return int(p - 0x40)
+	}
+	return 0
+}
+
+// IsReturnCall returns true if TP-PID p is return call message
+func IsReturnCall(p byte) bool {
+	return p == 0x5f
+}
+
+// StoredSM is short message that is stored in MS
+type StoredSM struct {
+	ID int
+	Deliver
+}
+
+// InboxStore is storage of received messages in MS
+type InboxStore interface {
+	// Add store new message d and returns its ID
+	Add(d Deliver) int
+	// Replace the message that has ID id with d
+	Replace(id int, d Deliver)
+	// Find returns the message that has same TP-PID,
+	// TP-OA and SC address with d
+	Find(d Deliver) (StoredSM, bool)
+	// Delete the message that has ID id
+	Delete(id int)
+	// List returns all messages in received order
+	List() []StoredSM
+}
+
+// MemoryInboxStore is in-memory InboxStore
+type MemoryInboxStore struct {
+	mutex sync.Mutex
+	msgs  []StoredSM
+	next  int
+}
+
+// Add store new message d and returns its ID
+func (s *MemoryInboxStore) Add(d Deliver) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.next++
+	s.msgs = append(s.msgs, StoredSM{ID: s.next, Deliver: d})
+	return s.next
+}
+
+// Replace the message that has ID id with d
+func (s *MemoryInboxStore) Replace(id int, d Deliver) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.msgs {
+		if s.msgs[i].ID == id {
+			s.msgs[i].Deliver = d
+		}
+	}
+}
+
+// Find returns the message that has same TP-PID,
+// TP-OA and SC address with d
+func (s *MemoryInboxStore) Find(d Deliver) (StoredSM, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, m := range s.msgs {
+		if m.PID == d.PID && m.OA.Equal(d.OA) && m.SCA.Equal(d.SCA) {
+			return m, true
+		}
+	}
+	return StoredSM{}, false
+}
+
+// Delete the message that has ID id
+func (s *MemoryInboxStore) Delete(id int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.msgs {
+		if s.msgs[i].ID == id {
+			s.msgs = append(s.msgs[:i], s.msgs[i+1:]...)
+			return
+		}
+	}
+}
+
+// List returns all messages in received order
+func (s *MemoryInboxStore) List() []StoredSM {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]StoredSM{}, s.msgs...)
+}
+
+// Inbox stores received Deliver in MS.
+// Message with replace short message type or return call PID
+// replaces the stored message that has same TP-PID, TP-OA and
+// SC address, as described in 3GPP TS23.040 9.2.3.9.
+type Inbox struct {
+	Store InboxStore
+
+	// ReturnCall is called when return call message is received
+	ReturnCall func(StoredSM)
+}
+
+// Receive store Deliver d and returns ID of the stored message.
+// replaced is true if d replaced the stored message.
+func (i Inbox) Receive(d Deliver) (id int, replaced bool) {
+	if ReplaceType(d.PID) != 0 || IsReturnCall(d.PID) {
+		if m, ok := i.Store.Find(d); ok {
+			id = m.ID
+			replaced = true
+			i.Store.Replace(id, d)
+		}
+	}
+	if !replaced {
+		id = i.Store.Add(d)
+	}
+	if IsReturnCall(d.PID) && i.ReturnCall != nil {
+		i.ReturnCall(StoredSM{ID: id, Deliver: d})
+	}
+	return
+}
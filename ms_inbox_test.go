@@ -0,0 +1,68 @@
+package sms_test
+
+import (
+	"testing"
+
+	"github.com/fkgi/sms"
+)
+
+func TestInbox(t *testing.T) {
+	oa1, _ := sms.ParseAddress("+819012345678")
+	oa2, _ := sms.ParseAddress("+819087654321")
+	sca, _ := sms.ParseAddress("+8190000000")
+	var call []sms.StoredSM
+	i := sms.Inbox{
+		Store:      &sms.MemoryInboxStore{},
+		ReturnCall: func(m sms.StoredSM) { call = append(call, m) }}
+	deliver := func(oa sms.Address, pid byte, txt string) sms.Deliver {
+		d := sms.Deliver{OA: oa, PID: pid, UD: sms.UserData{Text: txt}}
+		d.SCA = sca
+		return d
+	}
+
+	// normal messages are not replaced
+	id1, r := i.Receive(deliver(oa1, 0x00, "a"))
+	if r {
+		t.Errorf("normal message replaced")
+	}
+	if id, r := i.Receive(deliver(oa1, 0x00, "b")); r || id == id1 {
+		t.Errorf("normal message replaced")
+	}
+
+	// replace type 1
+	id2, r := i.Receive(deliver(oa1, 0x41, "c"))
+	if r {
+		t.Errorf("no message should be replaced")
+	}
+	if id, r := i.Receive(deliver(oa1, 0x41, "d")); !r || id != id2 {
+		t.Errorf("replace type 1 message is not replaced")
+	}
+	// different replace type or OA
+	if _, r := i.Receive(deliver(oa1, 0x42, "e")); r {
+		t.Errorf("different replace type replaced")
+	}
+	if _, r := i.Receive(deliver(oa2, 0x41, "f")); r {
+		t.Errorf("different OA replaced")
+	}
+
+	// return call
+	id3, _ := i.Receive(deliver(oa2, 0x5f, "g"))
+	if id, r := i.Receive(deliver(oa2, 0x5f, "h")); !r || id != id3 {
+		t.Errorf("return call message is not replaced")
+	}
+	if len(call) != 2 || call[1].UD.Text != "h" {
+		t.Errorf("unexpected return call %v", call)
+	}
+
+	var txt string
+	for _, m := range i.Store.List() {
+		txt += m.UD.Text
+	}
+	if txt != "abdefh" {
+		t.Errorf("unexpected stored messages %s", txt)
+	}
+
+	if sms.ReplaceType(0x47) != 7 || sms.ReplaceType(0x48) != 0 {
+		t.Errorf("unexpected replace type")
+	}
+}
@@ -0,0 +1,257 @@
+package sms
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeliveryState is delivery state of tracked message
+type DeliveryState int
+
+const (
+	// DeliveryPending is waiting for accept of SC
+	DeliveryPending DeliveryState = iota
+	// DeliveryAccepted is accepted by SC
+	DeliveryAccepted
+	// DeliveryDelivered is delivered to the SME
+	DeliveryDelivered
+	// DeliveryFailed is rejected by SC or failed to deliver
+	DeliveryFailed
+	// DeliveryExpired is no status report received before timeout
+	DeliveryExpired
+)
+
+func (s DeliveryState) String() string {
+	switch s {
+	case DeliveryPending:
+		return "pending"
+	case DeliveryAccepted:
+		return "accepted"
+	case DeliveryDelivered:
+		return "delivered"
+	case DeliveryFailed:
+		return "failed"
+	case DeliveryExpired:
+		return "expired"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// TrackedPart is tracked Submit that is a segment of the message
+type TrackedPart struct {
+	Submit
+	State DeliveryState
+	FCS   byte // TP-FCS of the SubmitReport
+	ST    byte // TP-ST of the last StatusReport
+}
+
+func (p TrackedPart) done() bool {
+	switch p.State {
+	case DeliveryAccepted:
+		return !p.SRR
+	case DeliveryPending:
+		return false
+	}
+	return true
+}
+
+// TrackedSM is tracked message that consists of one or more Submit
+type TrackedSM struct {
+	ID    int
+	State DeliveryState
+	Parts []TrackedPart
+}
+
+// Final returns true if no more report is expected for the message
+func (m TrackedSM) Final() bool {
+	for _, p := range m.Parts {
+		if !p.done() {
+			return m.State == DeliveryFailed || m.State == DeliveryExpired
+		}
+	}
+	return true
+}
+
+func (m *TrackedSM) aggregate() {
+	var pending, accepted, expired bool
+	for _, p := range m.Parts {
+		switch p.State {
+		case DeliveryFailed:
+			m.State = DeliveryFailed
+			return
+		case DeliveryExpired:
+			expired = true
+		case DeliveryPending:
+			pending = true
+		case DeliveryAccepted:
+			accepted = true
+		}
+	}
+	switch {
+	case expired:
+		m.State = DeliveryExpired
+	case pending:
+		m.State = DeliveryPending
+	case accepted:
+		m.State = DeliveryAccepted
+	default:
+		m.State = DeliveryDelivered
+	}
+}
+
+// Tracker correlates SubmitReport and StatusReport to the submitted
+// messages, and aggregates delivery state of concatenated segments.
+type Tracker struct {
+	// Timeout is period to wait for the final report, zero means no timeout
+	Timeout time.Duration
+	// Update is called when the state of the message is updated
+	Update func(TrackedSM)
+
+	mutex sync.Mutex
+	next  int
+	msgs  map[int]*TrackedSM
+	refs  map[string]int
+}
+
+func trackKey(mr byte, da Address) string {
+	return fmt.Sprintf("%d:%d:%d:%s", mr, da.TON, da.NPI, da.Addr)
+}
+
+// Track starts tracking the message that consists of Submit s,
+// and returns ID of the message
+func (t *Tracker) Track(s ...Submit) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.msgs == nil {
+		t.msgs = make(map[int]*TrackedSM)
+		t.refs = make(map[string]int)
+	}
+	t.next++
+	m := &TrackedSM{ID: t.next}
+	for _, p := range s {
+		m.Parts = append(m.Parts, TrackedPart{Submit: p})
+		t.refs[trackKey(p.TMR, p.DA)] = m.ID
+	}
+	t.msgs[m.ID] = m
+
+	if t.Timeout != 0 {
+		id := m.ID
+		time.AfterFunc(t.Timeout, func() { t.expire(id) })
+	}
+	return m.ID
+}
+
+// Get returns the tracked message that has ID id
+func (t *Tracker) Get(id int) (TrackedSM, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if m, ok := t.msgs[id]; ok {
+		return m.copy(), true
+	}
+	return TrackedSM{}, false
+}
+
+func (m *TrackedSM) copy() TrackedSM {
+	c := *m
+	c.Parts = append([]TrackedPart{}, m.Parts...)
+	return c
+}
+
+// Submitted apply result of the submission of s, that is
+// the answer and error of SMR.TranspReq.
+// It returns false if s is not tracked.
+func (t *Tracker) Submitted(s Submit, a TPDU, e error) bool {
+	return t.apply(s.TMR, s.DA, func(p *TrackedPart) {
+		switch {
+		case e != nil:
+			p.State = DeliveryFailed
+		case a == nil:
+			p.State = DeliveryAccepted
+		default:
+			r, ok := a.(SubmitReport)
+			if !ok {
+				p.State = DeliveryFailed
+			} else if p.FCS = r.FCS; r.FCS&0x80 == 0x80 {
+				p.State = DeliveryFailed
+			} else {
+				p.State = DeliveryAccepted
+			}
+		}
+	})
+}
+
+// Reported apply StatusReport r to the message that has same
+// TP-MR and TP-RA. It returns false if no message is matched.
+func (t *Tracker) Reported(r StatusReport) bool {
+	return t.apply(r.TMR, r.RA, func(p *TrackedPart) {
+		p.ST = r.ST
+		switch {
+		case r.ST < 0x20:
+			p.State = DeliveryDelivered
+		case r.ST < 0x40:
+			p.State = DeliveryAccepted
+		default:
+			p.State = DeliveryFailed
+		}
+	})
+}
+
+func (t *Tracker) apply(mr byte, da Address, f func(*TrackedPart)) bool {
+	t.mutex.Lock()
+	k := trackKey(mr, da)
+	m, ok := t.msgs[t.refs[k]]
+	if !ok {
+		t.mutex.Unlock()
+		return false
+	}
+	for i := range m.Parts {
+		if m.Parts[i].TMR == mr && m.Parts[i].DA.Equal(da) {
+			f(&m.Parts[i])
+		}
+	}
+	m.aggregate()
+	c := t.update(m)
+	t.mutex.Unlock()
+
+	if t.Update != nil {
+		t.Update(c)
+	}
+	return true
+}
+
+// update returns copy of m, and stop tracking m if it is final
+func (t *Tracker) update(m *TrackedSM) TrackedSM {
+	c := m.copy()
+	if c.Final() {
+		delete(t.msgs, m.ID)
+		for _, p := range m.Parts {
+			k := trackKey(p.TMR, p.DA)
+			if t.refs[k] == m.ID {
+				delete(t.refs, k)
+			}
+		}
+	}
+	return c
+}
+
+func (t *Tracker) expire(id int) {
+	t.mutex.Lock()
+	m, ok := t.msgs[id]
+	if !ok {
+		t.mutex.Unlock()
+		return
+	}
+	for i := range m.Parts {
+		if !m.Parts[i].done() {
+			m.Parts[i].State = DeliveryExpired
+		}
+	}
+	m.aggregate()
+	c := t.update(m)
+	t.mutex.Unlock()
+
+	if t.Update != nil {
+		t.Update(c)
+	}
+}
@@ -0,0 +1,76 @@
+package sms_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+func TestTracker(t *testing.T) {
+	da, _ := sms.ParseAddress("+819012345678")
+	var updates []sms.TrackedSM
+	tr := &sms.Tracker{Update: func(m sms.TrackedSM) { updates = append(updates, m) }}
+
+	ud, _ := sms.MakeSeparatedText(randText(300), 0x12)
+	var ss []sms.Submit
+	for i, u := range ud {
+		ss = append(ss, sms.Submit{SRR: true, TMR: byte(10 + i), DA: da, UD: u})
+	}
+	id := tr.Track(ss...)
+
+	for _, s := range ss {
+		if !tr.Submitted(s, sms.SubmitReport{}, nil) {
+			t.Fatalf("submit %d is not tracked", s.TMR)
+		}
+	}
+	if m, _ := tr.Get(id); m.State != sms.DeliveryAccepted {
+		t.Errorf("unexpected state %s", m.State)
+	}
+
+	// temporary error keeps waiting
+	tr.Reported(sms.StatusReport{TMR: 10, RA: da, ST: 0x21})
+	for _, s := range ss {
+		if !tr.Reported(sms.StatusReport{TMR: s.TMR, RA: da, ST: 0x00}) {
+			t.Fatalf("report %d is not matched", s.TMR)
+		}
+	}
+	m := updates[len(updates)-1]
+	if m.ID != id || m.State != sms.DeliveryDelivered || !m.Final() {
+		t.Errorf("unexpected state %v", m)
+	}
+	if _, ok := tr.Get(id); ok {
+		t.Errorf("delivered message is still tracked")
+	}
+	if tr.Reported(sms.StatusReport{TMR: 10, RA: da}) {
+		t.Errorf("unknown report is matched")
+	}
+
+	// rejected segment fails the message
+	id = tr.Track(ss...)
+	tr.Submitted(ss[0], sms.SubmitReport{FCS: 0xC5}, nil)
+	if m := updates[len(updates)-1]; m.ID != id || m.State != sms.DeliveryFailed {
+		t.Errorf("unexpected state %v", m)
+	}
+}
+
+func TestTrackerTimeout(t *testing.T) {
+	da, _ := sms.ParseAddress("+819012345678")
+	ch := make(chan sms.TrackedSM, 1)
+	tr := &sms.Tracker{
+		Timeout: 10 * time.Millisecond,
+		Update:  func(m sms.TrackedSM) { ch <- m }}
+
+	s := sms.Submit{SRR: true, TMR: 1, DA: da}
+	tr.Track(s)
+	tr.Submitted(s, nil, nil)
+	<-ch
+	select {
+	case m := <-ch:
+		if m.State != sms.DeliveryExpired {
+			t.Errorf("unexpected state %s", m.State)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timeout is not fired")
+	}
+}
@@ -0,0 +1,214 @@
+package sms
+
+import (
+	"strings"
+
+	"github.com/fkgi/teldata"
+)
+
+// NumberPlan is numbering parameters of the local network
+// for conversion between human readable text and Address
+type NumberPlan struct {
+	CountryCode  string // country code without "+" (e.g. "81")
+	TrunkPrefix  string // national trunk prefix (e.g. "0")
+	IntlPrefix   string // international call prefix (e.g. "010")
+	MaxShortCode int    // max digit length of short code
+}
+
+// DefaultNumberPlan is NumberPlan for ParseAddress and FormatAddress
+var DefaultNumberPlan = NumberPlan{MaxShortCode: 6}
+
+// ParseAddress make Address from text with DefaultNumberPlan
+func ParseAddress(s string) (Address, error) {
+	return DefaultNumberPlan.Parse(s)
+}
+
+// FormatAddress make text from Address with DefaultNumberPlan
+func FormatAddress(a Address) string {
+	return DefaultNumberPlan.Format(a)
+}
+
+// Parse make Address from text.
+// "+" prefixed or IntlPrefix prefixed digits are international number,
+// TrunkPrefix prefixed digits are national number,
+// digits not longer than MaxShortCode are abbreviated number,
+// other digits are unknown type number,
+// and text that is not digits is alphanumeric address.
+func (p NumberPlan) Parse(s string) (a Address, e error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		e = InvalidAddressError{Addr: s}
+		return
+	}
+
+	d, intl := stripDialString(s)
+	if len(d) == 0 && intl {
+		e = InvalidAddressError{Addr: s}
+		return
+	} else if len(d) == 0 {
+		var g GSM7bitString
+		if g, e = StringToGSM7bit(s); e != nil {
+			return
+		}
+		if g.Length() > 11 {
+			e = InvalidAddressError{Addr: s}
+			return
+		}
+		a.TON = TypeAlphanumeric
+		a.NPI = PlanUnknown
+		a.Addr = g
+		return
+	}
+
+	switch {
+	case intl:
+		a.TON = TypeInternational
+		a.NPI = PlanISDNTelephone
+	case len(p.IntlPrefix) != 0 && strings.HasPrefix(d, p.IntlPrefix):
+		d = d[len(p.IntlPrefix):]
+		a.TON = TypeInternational
+		a.NPI = PlanISDNTelephone
+	case len(p.TrunkPrefix) != 0 && strings.HasPrefix(d, p.TrunkPrefix) &&
+		len(d) > len(p.TrunkPrefix):
+		d = d[len(p.TrunkPrefix):]
+		a.TON = TypeNational
+		a.NPI = PlanISDNTelephone
+	case len(d) <= p.MaxShortCode:
+		a.TON = TypeAbbreviated
+		a.NPI = PlanUnknown
+	default:
+		a.TON = TypeUnknown
+		a.NPI = PlanISDNTelephone
+	}
+	if len(d) == 0 || len(d) > 20 {
+		e = InvalidAddressError{Addr: s}
+		return
+	}
+	a.Addr, e = teldata.ParseTBCD(d)
+	return
+}
+
+// stripDialString returns digits of s without visual separators,
+// and s is "+" prefixed or not.
+// Empty digits is returned when s is not a dial string.
+func stripDialString(s string) (string, bool) {
+	intl := strings.HasPrefix(s, "+")
+	if intl {
+		s = s[1:]
+	}
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9', c == '*', c == '#':
+			b.WriteRune(c)
+		case c == ' ', c == '-', c == '.', c == '(', c == ')':
+		default:
+			return "", false
+		}
+	}
+	return b.String(), intl
+}
+
+// Format make text from Address.
+// International number is "+" prefixed and
+// national number is TrunkPrefix prefixed.
+func (p NumberPlan) Format(a Address) string {
+	if a.Addr == nil {
+		return ""
+	}
+	switch a.TON {
+	case TypeInternational:
+		return "+" + a.Addr.String()
+	case TypeNational:
+		return p.TrunkPrefix + a.Addr.String()
+	}
+	return a.Addr.String()
+}
+
+// ToInternational convert the Address to international number
+func (p NumberPlan) ToInternational(a Address) (Address, error) {
+	if a.Addr == nil {
+		return a, ErrNumberConversion
+	}
+	if _, ok := a.Addr.(teldata.TBCD); !ok {
+		return a, ErrNumberConversion
+	}
+	d := a.Addr.String()
+
+	switch a.TON {
+	case TypeInternational:
+		return a, nil
+	case TypeNational:
+	case TypeUnknown:
+		switch {
+		case len(p.IntlPrefix) != 0 && strings.HasPrefix(d, p.IntlPrefix):
+			d = d[len(p.IntlPrefix):]
+			return makeE164Address(TypeInternational, d)
+		case len(p.TrunkPrefix) != 0 && strings.HasPrefix(d, p.TrunkPrefix):
+			d = d[len(p.TrunkPrefix):]
+		default:
+			return a, ErrNumberConversion
+		}
+	default:
+		return a, ErrNumberConversion
+	}
+	if len(p.CountryCode) == 0 {
+		return a, ErrNumberConversion
+	}
+	return makeE164Address(TypeInternational, p.CountryCode+d)
+}
+
+// ToNational convert the Address to national number
+func (p NumberPlan) ToNational(a Address) (Address, error) {
+	if a.Addr == nil {
+		return a, ErrNumberConversion
+	}
+	if _, ok := a.Addr.(teldata.TBCD); !ok {
+		return a, ErrNumberConversion
+	}
+	d := a.Addr.String()
+
+	switch a.TON {
+	case TypeNational:
+		return a, nil
+	case TypeInternational:
+	case TypeUnknown:
+		switch {
+		case len(p.TrunkPrefix) != 0 && strings.HasPrefix(d, p.TrunkPrefix):
+			return makeE164Address(TypeNational, d[len(p.TrunkPrefix):])
+		case len(p.IntlPrefix) != 0 && strings.HasPrefix(d, p.IntlPrefix):
+			d = d[len(p.IntlPrefix):]
+		default:
+			return a, ErrNumberConversion
+		}
+	default:
+		return a, ErrNumberConversion
+	}
+	if len(p.CountryCode) == 0 || !strings.HasPrefix(d, p.CountryCode) {
+		return a, ErrNumberConversion
+	}
+	return makeE164Address(TypeNational, d[len(p.CountryCode):])
+}
+
+func makeE164Address(ton byte, d string) (a Address, e error) {
+	if len(d) == 0 || len(d) > 20 {
+		e = ErrNumberConversion
+		return
+	}
+	a.TON = ton
+	a.NPI = PlanISDNTelephone
+	a.Addr, e = teldata.ParseTBCD(d)
+	return
+}
+
+// ToInternational convert the Address to international number
+// with DefaultNumberPlan
+func (a Address) ToInternational() (Address, error) {
+	return DefaultNumberPlan.ToInternational(a)
+}
+
+// ToNational convert the Address to national number
+// with DefaultNumberPlan
+func (a Address) ToNational() (Address, error) {
+	return DefaultNumberPlan.ToNational(a)
+}
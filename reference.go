@@ -0,0 +1,237 @@
+package sms
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RefStore persists counter of RefAllocator
+type RefStore interface {
+	// LoadRef returns saved counter of key
+	LoadRef(key string) (next uint16, ok bool, e error)
+	// SaveRef saves counter of key
+	SaveRef(key string, next uint16) error
+}
+
+// RefAllocator allocates reference number from 0 to max,
+// without reusing the number that is still outstanding.
+type RefAllocator struct {
+	max   uint16
+	store RefStore
+	key   string
+
+	mutex sync.Mutex
+	next  uint16
+	used  map[uint16]bool
+}
+
+// NewMsgRefAllocator make RefAllocator for TP-MR.
+// Counter is persisted to s with key if s is not nil.
+// SaveRef of s is called in every Next under the lock of the allocator,
+// so slow store like FileRefStore limits the rate of allocation.
+func NewMsgRefAllocator(s RefStore, key string) (*RefAllocator, error) {
+	return newRefAllocator(0xff, s, key)
+}
+
+// NewConcatRefAllocator make RefAllocator for
+// 8bit reference number of concatenated SM.
+// Counter is persisted to s as NewMsgRefAllocator.
+func NewConcatRefAllocator(s RefStore, key string) (*RefAllocator, error) {
+	return newRefAllocator(0xff, s, key)
+}
+
+// NewConcatRef16Allocator make RefAllocator for
+// 16bit reference number of concatenated SM.
+// Counter is persisted to s as NewMsgRefAllocator.
+func NewConcatRef16Allocator(s RefStore, key string) (*RefAllocator, error) {
+	return newRefAllocator(0xffff, s, key)
+}
+
+func newRefAllocator(max uint16, s RefStore, key string) (*RefAllocator, error) {
+	a := &RefAllocator{
+		max:   max,
+		store: s,
+		key:   key,
+		next:  uint16(time.Now().Nanosecond()),
+		used:  make(map[uint16]bool)}
+	if s != nil {
+		n, ok, e := s.LoadRef(key)
+		if e != nil {
+			return nil, e
+		}
+		if ok {
+			a.next = n
+		}
+	}
+	a.next = a.wrap(int(a.next))
+	return a, nil
+}
+
+func (a *RefAllocator) wrap(i int) uint16 {
+	return uint16(i % (int(a.max) + 1))
+}
+
+// Next allocates reference number.
+// ErrNoReference is returned if all numbers are outstanding.
+func (a *RefAllocator) Next() (uint16, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i := 0; i <= int(a.max); i++ {
+		r := a.wrap(int(a.next) + i)
+		if a.used[r] {
+			continue
+		}
+		a.used[r] = true
+		a.next = a.wrap(int(r) + 1)
+		if a.store != nil {
+			if e := a.store.SaveRef(a.key, a.next); e != nil {
+				delete(a.used, r)
+				return 0, e
+			}
+		}
+		return r, nil
+	}
+	return 0, ErrNoReference
+}
+
+// Release the reference number r, that is no longer outstanding
+func (a *RefAllocator) Release(r uint16) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.used, r)
+}
+
+// MakeSeparatedTextRef generate splited data with
+// concatenation reference number allocated by a.
+// Reference is allocated only if the text is separated, and
+// ErrTooManySegments is returned if it needs more than 255 segments.
+func MakeSeparatedTextRef(s string, a *RefAllocator) (
+	ud []UserData, cs Charset, ref uint16, e error) {
+	if ud, cs, e = separateText(s, a.hdrLen()); e != nil {
+		return
+	}
+	if ref, e = a.concatenate(ud); e != nil {
+		ud = nil
+	}
+	return
+}
+
+// MakeSeparatedDataRef generate splited 8bit data with
+// concatenation reference number allocated by a.
+// Reference is allocated only if the data is separated, and
+// ErrTooManySegments is returned if it needs more than 255 segments.
+func MakeSeparatedDataRef(d []byte, a *RefAllocator) (
+	ud []UserData, ref uint16, e error) {
+	if ud, e = separateData(d, a.hdrLen()); e != nil {
+		return
+	}
+	if ref, e = a.concatenate(ud); e != nil {
+		ud = nil
+	}
+	return
+}
+
+// hdrLen returns length of UDH for concatenation with the reference
+func (a *RefAllocator) hdrLen() int {
+	if a.max > 0xff {
+		return 7
+	}
+	return 6
+}
+
+// concatenate allocates reference and append concatenation UDH
+// to each segment of ud, if ud is separated.
+// Reference is not allocated if ud has more than 255 segments.
+func (a *RefAllocator) concatenate(ud []UserData) (ref uint16, e error) {
+	if len(ud) < 2 {
+		return
+	}
+	if len(ud) > 0xff {
+		e = ErrTooManySegments
+		return
+	}
+	if ref, e = a.Next(); e != nil {
+		return
+	}
+	for i := range ud {
+		if a.max > 0xff {
+			ud[i].UDH = append(ud[i].UDH, ConcatenatedSM16bit{
+				RefNum: ref, MaxNum: byte(len(ud)), SeqNum: byte(i + 1)})
+		} else {
+			ud[i].UDH = append(ud[i].UDH, ConcatenatedSM{
+				RefNum: byte(ref), MaxNum: byte(len(ud)), SeqNum: byte(i + 1)})
+		}
+	}
+	return
+}
+
+// FileRefStore is RefStore that saves counters to JSON file.
+// The file is replaced atomically by rename of temporary file.
+// Outstanding references are not persisted, only the next counter is saved.
+// Each SaveRef reads the whole file, then writes, syncs and renames
+// temporary file, that costs some disk I/O for every allocated reference.
+type FileRefStore struct {
+	Path string
+
+	mutex sync.Mutex
+}
+
+func (s *FileRefStore) read() (map[string]uint16, error) {
+	m := map[string]uint16{}
+	b, e := os.ReadFile(s.Path)
+	if os.IsNotExist(e) {
+		return m, nil
+	} else if e != nil {
+		return nil, e
+	}
+	if len(b) != 0 {
+		e = json.Unmarshal(b, &m)
+	}
+	return m, e
+}
+
+// LoadRef returns saved counter of key
+func (s *FileRefStore) LoadRef(key string) (next uint16, ok bool, e error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, e := s.read()
+	if e == nil {
+		next, ok = m[key]
+	}
+	return
+}
+
+// SaveRef saves counter of key
+func (s *FileRefStore) SaveRef(key string, next uint16) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, e := s.read()
+	if e != nil {
+		return e
+	}
+	m[key] = next
+	b, e := json.Marshal(m)
+	if e != nil {
+		return e
+	}
+
+	f, e := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp*")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(f.Name())
+	if _, e = f.Write(b); e == nil {
+		e = f.Sync()
+	}
+	if ce := f.Close(); e == nil {
+		e = ce
+	}
+	if e != nil {
+		return e
+	}
+	return os.Rename(f.Name(), s.Path)
+}
@@ -0,0 +1,138 @@
+package sms_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fkgi/sms"
+)
+
+func TestRefAllocator(t *testing.T) {
+	a, e := sms.NewMsgRefAllocator(nil, "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	used := map[uint16]bool{}
+	for i := 0; i < 256; i++ {
+		r, e := a.Next()
+		if e != nil {
+			t.Fatalf("allocation %d failed: %s", i, e)
+		}
+		if r > 0xff || used[r] {
+			t.Fatalf("invalid reference %d", r)
+		}
+		used[r] = true
+	}
+	if _, e = a.Next(); e != sms.ErrNoReference {
+		t.Errorf("unexpected error %v", e)
+	}
+	a.Release(42)
+	if r, e := a.Next(); e != nil || r != 42 {
+		t.Errorf("released reference is not reused: %d %v", r, e)
+	}
+}
+
+func TestRefAllocatorPersist(t *testing.T) {
+	dir := t.TempDir()
+	s := &sms.FileRefStore{Path: filepath.Join(dir, "ref.json")}
+	a, e := sms.NewConcatRef16Allocator(s, "sme1")
+	if e != nil {
+		t.Fatal(e)
+	}
+	r1, _ := a.Next()
+	if f, _ := os.ReadDir(dir); len(f) != 1 {
+		t.Errorf("temporary file is left %v", f)
+	}
+
+	// restarted allocator continues from the saved counter
+	a, e = sms.NewConcatRef16Allocator(s, "sme1")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if r2, _ := a.Next(); r2 != r1+1 {
+		t.Errorf("unexpected reference %d after %d", r2, r1)
+	}
+
+	ud, _, ref, e := sms.MakeSeparatedTextRef(randText(500), a)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ref != r1+2 {
+		t.Errorf("unexpected reference %d", ref)
+	}
+	for _, u := range ud {
+		if h, ok := u.UDH[0].(sms.ConcatenatedSM16bit); !ok || h.RefNum != ref {
+			t.Errorf("unexpected header %v", u.UDH)
+		}
+	}
+
+	// message that can't be concatenated doesn't use saved reference
+	n, _, _ := s.LoadRef("sme1")
+	if _, _, e = sms.MakeSeparatedDataRef(make([]byte, 133*255+1), a); e != sms.ErrTooManySegments {
+		t.Errorf("unexpected error %v", e)
+	}
+	if m, _, _ := s.LoadRef("sme1"); m != n {
+		t.Errorf("saved counter is changed to %d from %d", m, n)
+	}
+	if r, _ := a.Next(); r != ref+1 {
+		t.Errorf("unexpected reference %d after %d", r, ref)
+	}
+}
+
+func TestMakeSeparatedTextRefLength(t *testing.T) {
+	a8, _ := sms.NewConcatRefAllocator(nil, "")
+	a16, _ := sms.NewConcatRef16Allocator(nil, "")
+	for _, s := range []string{
+		strings.Repeat("a", 500), strings.Repeat("[", 500),
+		strings.Repeat("あ", 300)} {
+		for _, a := range []*sms.RefAllocator{a8, a16} {
+			ud, cs, _, e := sms.MakeSeparatedTextRef(s, a)
+			if e != nil {
+				t.Fatal(e)
+			}
+			for _, u := range ud {
+				p := sms.Submit{
+					DCS: &sms.GeneralDataCoding{MsgCharset: cs}, UD: u}
+				p.DA, _ = sms.ParseAddress("+819087654321")
+				q, e := sms.UnmarshalTPMO(p.MarshalTP())
+				if e != nil {
+					t.Fatal(e)
+				}
+				if r := q.(sms.Submit).UD.Text; r != u.Text {
+					t.Errorf("text is truncated to %d from %d",
+						len([]rune(r)), len([]rune(u.Text)))
+				}
+			}
+		}
+	}
+}
+
+func TestMakeSeparatedTooManySegments(t *testing.T) {
+	a, _ := sms.NewConcatRefAllocator(nil, "")
+
+	// 134 octets and 153 characters in each segment with 8bit reference
+	ud, _, e := sms.MakeSeparatedDataRef(make([]byte, 134*255), a)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if h := ud[254].UDH[0].(sms.ConcatenatedSM); len(ud) != 255 ||
+		h.MaxNum != 255 || h.SeqNum != 255 {
+		t.Errorf("unexpected last segment %v of %d", h, len(ud))
+	}
+	if _, _, e = sms.MakeSeparatedDataRef(make([]byte, 134*255+1), a); e != sms.ErrTooManySegments {
+		t.Errorf("unexpected error %v", e)
+	}
+
+	s := strings.Repeat("a", 153*255)
+	if ud, _, _, e = sms.MakeSeparatedTextRef(s, a); e != nil || len(ud) != 255 {
+		t.Errorf("unexpected result %d %v", len(ud), e)
+	}
+	if _, _, _, e = sms.MakeSeparatedTextRef(s+"a", a); e != sms.ErrTooManySegments {
+		t.Errorf("unexpected error %v", e)
+	}
+	if ud, _ = sms.MakeSeparatedText(s+"a", 1); ud != nil {
+		t.Errorf("unexpected %d segments", len(ud))
+	}
+}
@@ -3,7 +3,6 @@ package sms
 import (
 	"bytes"
 	"fmt"
-	"io"
 )
 
 // RpAck is RP-ACK RPDU
@@ -21,42 +20,74 @@ type RpAckMT RpAck
 
 // MarshalRP output byte data of this RPDU
 func (d RpAckMO) MarshalRP() []byte {
-	return RpAck(d).marshalRP(true, nil)
+	return d.AppendRP(nil)
 }
 
 // MarshalRP output byte data of this RPDU
 func (d RpAckMT) MarshalRP() []byte {
-	return RpAck(d).marshalRP(false, nil)
+	return d.AppendRP(nil)
 }
 
-func (d RpAck) marshalRP(mo bool, tp []byte) []byte {
-	// func (d rpAnswer) marshalAck(mo bool, tp []byte) []byte {
-	w := new(bytes.Buffer)
+// AppendRP append byte data of this RPDU to dst
+func (d RpAckMO) AppendRP(dst []byte) []byte {
+	dst, _ = RpAck(d).appendRP(dst, true, false)
+	return dst
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d RpAckMT) AppendRP(dst []byte) []byte {
+	dst, _ = RpAck(d).appendRP(dst, false, false)
+	return dst
+}
 
+// appendRP append RP-ACK to dst. If tp is true, RP-User-Data
+// header is appended and its position is returned.
+func (d RpAck) appendRP(dst []byte, mo, tp bool) ([]byte, int) {
 	if mo {
-		w.WriteByte(2)
+		dst = append(dst, 2, d.RMR)
 	} else {
-		w.WriteByte(3)
+		dst = append(dst, 3, d.RMR)
 	}
-	w.WriteByte(d.RMR)
-
-	if tp != nil {
-		w.WriteByte(0x41)
-		w.WriteByte(byte(len(tp)))
-		w.Write(tp)
+	if tp {
+		dst = append(dst, 0x41, 0)
 	}
+	return dst, len(dst)
+}
 
-	return w.Bytes()
+// Validate check consistency of field values and returns ValidationError
+func (d RpAckMO) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	return e.result()
+}
+
+// Validate check consistency of field values and returns ValidationError
+func (d RpAckMT) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	return e.result()
 }
 
 // MarshalCP output byte data of this CPDU
 func (d RpAckMO) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d RpAckMT) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d RpAckMO) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d RpAckMT) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalRpAckMO decode Ack MO from bytes
@@ -66,8 +97,12 @@ func UnmarshalRpAckMO(b []byte) (a RpAckMO, e error) {
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *RpAckMO) UnmarshalRP(b []byte) (e error) {
-	if b, e = (*RpAck)(d).unmarshalRP(true, b); e != nil && b != nil {
+func (d *RpAckMO) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *RpAckMO) unmarshalRP(b []byte, o *decoder) (e error) {
+	if b, e = (*RpAck)(d).unmarshalRP(true, b, o); e != nil && b != nil {
 		e = ErrExtraData
 	}
 	return
@@ -80,62 +115,69 @@ func UnmarshalRpAckMT(b []byte) (a RpAckMT, e error) {
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *RpAckMT) UnmarshalRP(b []byte) (e error) {
-	if b, e = (*RpAck)(d).unmarshalRP(false, b); e != nil && b != nil {
+func (d *RpAckMT) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *RpAckMT) unmarshalRP(b []byte, o *decoder) (e error) {
+	if b, e = (*RpAck)(d).unmarshalRP(false, b, o); e != nil && b != nil {
 		e = ErrExtraData
 	}
 	return
 }
 
-func (d *RpAck) unmarshalRP(mo bool, b []byte) (tp []byte, e error) {
-	// func (d *rpAnswer) unmarshalAck(mo bool, b []byte) (tp []byte, e error) {
+func (d *RpAck) unmarshalRP(
+	mo bool, b []byte, o *decoder) (tp []byte, e error) {
+	r := newFieldReader("RP", b, o)
 	if mo {
-		d.RMR, e = unmarshalRpHeader(2, b)
+		d.RMR, e = unmarshalRpHeader(r, 2)
 	} else {
-		d.RMR, e = unmarshalRpHeader(3, b)
+		d.RMR, e = unmarshalRpHeader(r, 3)
 	}
-	if e != nil || len(b) == 2 {
+	if e != nil || r.Len() == 0 {
 		return
 	}
 
-	r := bytes.NewReader(b[2:])
+	r.next("RP-User-Data")
 	var tmp byte
 	if tmp, e = r.ReadByte(); e != nil {
-		return
+		return nil, r.fail(e)
 	}
 	if tmp != 0x41 {
-		e = UnexpectedInformationElementError{
-			Expected: 0x41, Actual: tmp}
-		return
+		return nil, r.fail(UnexpectedInformationElementError{
+			Expected: 0x41, Actual: tmp})
 	}
 	if tmp, e = r.ReadByte(); e != nil {
-		return
+		return nil, r.fail(e)
 	}
-	tp = make([]byte, int(tmp))
-	var n int
-	if n, e = r.Read(tp); e != nil {
-		return
-	}
-	if n != len(tp) {
-		e = io.EOF
-	} else if r.Len() != 0 {
-		e = ErrExtraData
+	if tp, e = r.userData(int(tmp), o); e == nil {
+		e = o.checkTrailing(r)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *RpAckMO) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *RpAckMO) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *RpAckMO) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *RpAckMT) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *RpAckMT) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *RpAckMT) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
@@ -77,51 +77,82 @@ type RpErrorMT RpError
 
 // MarshalRP output byte data of this RPDU
 func (d RpErrorMO) MarshalRP() []byte {
-	return RpError(d).marshalRP(true, nil)
+	return d.AppendRP(nil)
 }
 
 // MarshalRP output byte data of this RPDU
 func (d RpErrorMT) MarshalRP() []byte {
-	return RpError(d).marshalRP(false, nil)
+	return d.AppendRP(nil)
 }
 
-func (d RpError) marshalRP(mo bool, tp []byte) []byte {
-	// func (d rpAnswer) marshalErr(mo bool, tp []byte) []byte {
-	w := new(bytes.Buffer)
+// AppendRP append byte data of this RPDU to dst
+func (d RpErrorMO) AppendRP(dst []byte) []byte {
+	dst, _ = RpError(d).appendRP(dst, true, false)
+	return dst
+}
 
+// AppendRP append byte data of this RPDU to dst
+func (d RpErrorMT) AppendRP(dst []byte) []byte {
+	dst, _ = RpError(d).appendRP(dst, false, false)
+	return dst
+}
+
+// appendRP append RP-ERROR to dst. If tp is true, RP-User-Data
+// header is appended and its position is returned.
+func (d RpError) appendRP(dst []byte, mo, tp bool) ([]byte, int) {
 	if mo {
-		w.WriteByte(4)
+		dst = append(dst, 4, d.RMR)
 	} else {
-		w.WriteByte(5)
+		dst = append(dst, 5, d.RMR)
 	}
-	w.WriteByte(d.RMR)
-
 	if d.DIAG != nil {
-		w.WriteByte(2)
-		w.WriteByte(d.CS)
-		w.WriteByte(*d.DIAG)
+		dst = append(dst, 2, d.CS, *d.DIAG)
 	} else {
-		w.WriteByte(1)
-		w.WriteByte(d.CS)
+		dst = append(dst, 1, d.CS)
 	}
-
-	if tp != nil {
-		w.WriteByte(0x41)
-		w.WriteByte(byte(len(tp)))
-		w.Write(tp)
+	if tp {
+		dst = append(dst, 0x41, 0)
 	}
+	return dst, len(dst)
+}
+
+// Validate check consistency of field values and returns ValidationError
+func (d RpErrorMO) Validate() error {
+	return RpError(d).validate()
+}
 
-	return w.Bytes()
+// Validate check consistency of field values and returns ValidationError
+func (d RpErrorMT) Validate() error {
+	return RpError(d).validate()
+}
+
+func (d RpError) validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	e.checkRPCause(d.CS)
+	return e.result()
 }
 
 // MarshalCP output byte data of this CPDU
 func (d RpErrorMO) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d RpErrorMT) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d RpErrorMO) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d RpErrorMT) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalRpErrorMO decode Error MO from bytes
@@ -131,8 +162,12 @@ func UnmarshalRpErrorMO(b []byte) (a RpErrorMO, e error) {
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *RpErrorMO) UnmarshalRP(b []byte) (e error) {
-	if b, e = (*RpError)(d).unmarshalRP(true, b); e != nil && b != nil {
+func (d *RpErrorMO) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *RpErrorMO) unmarshalRP(b []byte, o *decoder) (e error) {
+	if b, e = (*RpError)(d).unmarshalRP(true, b, o); e != nil && b != nil {
 		e = ErrExtraData
 	}
 	return
@@ -145,44 +180,47 @@ func UnmarshalRpErrorMT(b []byte) (a RpErrorMT, e error) {
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *RpErrorMT) UnmarshalRP(b []byte) (e error) {
-	if b, e = (*RpError)(d).unmarshalRP(false, b); e != nil && b != nil {
+func (d *RpErrorMT) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *RpErrorMT) unmarshalRP(b []byte, o *decoder) (e error) {
+	if b, e = (*RpError)(d).unmarshalRP(false, b, o); e != nil && b != nil {
 		e = ErrExtraData
 	}
 	return
 }
 
-func (d *RpError) unmarshalRP(mo bool, b []byte) (tp []byte, e error) {
-	// func (d *rpAnswer) unmarshalErr(mo bool, b []byte) (tp []byte, e error) {
+func (d *RpError) unmarshalRP(
+	mo bool, b []byte, o *decoder) (tp []byte, e error) {
+	r := newFieldReader("RP", b, o)
 	if mo {
-		d.RMR, e = unmarshalRpHeader(4, b)
+		d.RMR, e = unmarshalRpHeader(r, 4)
 	} else {
-		d.RMR, e = unmarshalRpHeader(5, b)
+		d.RMR, e = unmarshalRpHeader(r, 5)
 	}
 	if e != nil {
 		return
 	}
 
-	r := bytes.NewReader(b[2:])
+	r.next("RP-Cause")
 	var tmp byte
 	if tmp, e = r.ReadByte(); e != nil {
-		return
+		return nil, r.fail(e)
 	}
 	if tmp == 0 {
-		e = io.EOF
-		return
+		return nil, r.fail(io.EOF)
 	}
 	if tmp > 2 {
-		e = ErrExtraData
-		return
+		return nil, r.fail(ErrExtraData)
 	}
 	if d.CS, e = r.ReadByte(); e != nil {
-		return
+		return nil, r.fail(e)
 	}
 	if tmp == 2 {
 		var diag byte
 		if diag, e = r.ReadByte(); e != nil {
-			return
+			return nil, r.fail(e)
 		}
 		d.DIAG = &diag
 	}
@@ -191,42 +229,45 @@ func (d *RpError) unmarshalRP(mo bool, b []byte) (tp []byte, e error) {
 		return
 	}
 
+	r.next("RP-User-Data")
 	if tmp, e = r.ReadByte(); e != nil {
-		return
+		return nil, r.fail(e)
 	}
 	if tmp != 0x41 {
-		e = UnexpectedInformationElementError{
-			Expected: 0x41, Actual: tmp}
-		return
+		return nil, r.fail(UnexpectedInformationElementError{
+			Expected: 0x41, Actual: tmp})
 	}
 	if tmp, e = r.ReadByte(); e != nil {
-		return
-	}
-	tp = make([]byte, int(tmp))
-	var n int
-	if n, e = r.Read(tp); e != nil {
-		return
+		return nil, r.fail(e)
 	}
-	if n != len(tp) {
-		e = io.EOF
-	} else if r.Len() != 0 {
-		e = ErrExtraData
+	if tp, e = r.userData(int(tmp), o); e == nil {
+		e = o.checkTrailing(r)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *RpErrorMO) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *RpErrorMO) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *RpErrorMO) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *RpErrorMT) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *RpErrorMT) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *RpErrorMT) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
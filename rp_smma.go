@@ -14,12 +14,30 @@ type MemoryAvailable struct {
 
 // MarshalRP output byte data of this RPDU
 func (d MemoryAvailable) MarshalRP() []byte {
-	return []byte{6, d.RMR}
+	return d.AppendRP(nil)
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d MemoryAvailable) AppendRP(dst []byte) []byte {
+	return append(dst, 6, d.RMR)
+}
+
+// Validate check consistency of field values and returns ValidationError
+func (d MemoryAvailable) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	return e.result()
 }
 
 // MarshalCP output byte data of this CPDU
 func (d MemoryAvailable) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d MemoryAvailable) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalMemoryAvailable decode MemoryAvailable MO from bytes
@@ -29,29 +47,27 @@ func UnmarshalMemoryAvailable(b []byte) (a MemoryAvailable, e error) {
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *MemoryAvailable) UnmarshalRP(b []byte) (e error) {
-	r := bytes.NewReader(b)
-	var tmp byte
+func (d *MemoryAvailable) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
 
-	if tmp, e = r.ReadByte(); e != nil {
-		return
-	}
-	if tmp != 6 {
-		e = UnexpectedMessageTypeError{
-			Expected: 6, Actual: b[0]}
-		return
-	}
-	if d.RMR, e = r.ReadByte(); e == nil && r.Len() != 0 {
-		e = ErrExtraData
+func (d *MemoryAvailable) unmarshalRP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("RP", b, o)
+	if d.RMR, e = unmarshalRpHeader(r, 6); e == nil {
+		e = o.checkTrailing(r)
 	}
-
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *MemoryAvailable) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *MemoryAvailable) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *MemoryAvailable) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
@@ -1,7 +1,6 @@
 package sms
 
 import (
-	"bytes"
 	"io"
 )
 
@@ -9,6 +8,7 @@ import (
 type RPDU interface {
 	CPDU
 	MarshalRP() []byte
+	AppendRP([]byte) []byte
 }
 
 // UnmarshalerRP is the interface implemented by types
@@ -19,38 +19,54 @@ type UnmarshalerRP interface {
 
 // UnmarshalRPMO parse byte data to TPDU as SC.
 func UnmarshalRPMO(b []byte) (RPDU, error) {
-	return unmarshalRPMO(b, cpData{})
+	return unmarshalRPMO(b, cpData{}, newDecoder(nil))
 }
 
-func unmarshalRPMO(b []byte, c cpData) (RPDU, error) {
+// UnmarshalRPMOWithOptions is UnmarshalRPMO with lenient decoding by DecodeOptions o,
+// and it returns violations that are ignored by o.
+func UnmarshalRPMOWithOptions(b []byte, o *DecodeOptions) (RPDU, []Violation, error) {
+	d := newDecoder(o)
+	p, e := unmarshalRPMO(b, cpData{}, d)
+	return p, d.warnings, e
+}
+
+func unmarshalRPMO(b []byte, c cpData, o *decoder) (RPDU, error) {
 	if len(b) == 0 {
-		return nil, io.EOF
+		return nil, DecodeError{Layer: "RP", Field: "RP-MTI", Err: io.EOF}
 	}
+	rb := b
 	switch b[0] & 0x07 {
 	case 0x00:
 		var rp rpData
 		var e error
-		if b, e = rp.unmarshal(true, b); e != nil {
+		if b, e = rp.unmarshal(true, b, o); e != nil {
 			return nil, e
 		}
+		if len(b) == 0 {
+			return nil, DecodeError{Layer: "TP", Field: "TP-MTI",
+				Offset: offsetIn(rb, b), Err: io.EOF}
+		}
 		rp.cpData = c
 
 		switch b[0] & 0x03 {
 		case 0x01:
 			var tp Submit
-			e = tp.UnmarshalTP(b)
+			e = innerError(tp.unmarshalTP(b, o), rb, b)
 			tp.rpData = rp
 			return tp, e
 		case 0x02:
 			var tp Command
-			e = tp.UnmarshalTP(b)
+			e = innerError(tp.unmarshalTP(b, o), rb, b)
 			tp.rpData = rp
 			return tp, e
 		}
+		return nil, DecodeError{Layer: "TP", Field: "TP-MTI",
+			Offset: offsetIn(rb, b),
+			Err:    UnknownMessageTypeError{Actual: b[0] & 0x03}}
 	case 0x02:
 		var rp RpAck
 		var e error
-		if b, e = rp.unmarshalRP(true, b); e != nil {
+		if b, e = rp.unmarshalRP(true, b, o); e != nil {
 			return nil, e
 		}
 		rp.cpData = c
@@ -59,14 +75,14 @@ func unmarshalRPMO(b []byte, c cpData) (RPDU, error) {
 		}
 
 		var tp DeliverReport
-		e = tp.UnmarshalTP(b)
+		e = innerError(tp.unmarshalTP(b, o), rb, b)
 		tp.cpData = rp.cpData
 		tp.RMR = rp.RMR
 		return tp, e
 	case 0x04:
 		var rp RpError
 		var e error
-		if b, e = rp.unmarshalRP(true, b); e != nil {
+		if b, e = rp.unmarshalRP(true, b, o); e != nil {
 			return nil, e
 		}
 		rp.cpData = c
@@ -75,54 +91,72 @@ func unmarshalRPMO(b []byte, c cpData) (RPDU, error) {
 		}
 
 		var tp DeliverReport
-		e = tp.UnmarshalTP(b)
+		e = innerError(tp.unmarshalTP(b, o), rb, b)
 		tp.cpData = rp.cpData
 		tp.RMR = rp.RMR
 		tp.CS = rp.CS
 		tp.DIAG = rp.DIAG
 		return tp, e
 	case 0x06:
-		rp, e := UnmarshalMemoryAvailable(b)
+		var rp MemoryAvailable
+		e := rp.unmarshalRP(b, o)
 		rp.cpData = c
 		return rp, e
 	}
-	return nil, UnknownMessageTypeError{Actual: b[0]}
+	return nil, DecodeError{Layer: "RP", Field: "RP-MTI",
+		Err: UnknownMessageTypeError{Actual: b[0]}}
 }
 
 // UnmarshalRPMT parse byte data to TPDU as MS.
 func UnmarshalRPMT(b []byte) (RPDU, error) {
-	return unmarshalRPMT(b, cpData{})
+	return unmarshalRPMT(b, cpData{}, newDecoder(nil))
+}
+
+// UnmarshalRPMTWithOptions is UnmarshalRPMT with lenient decoding by DecodeOptions o,
+// and it returns violations that are ignored by o.
+func UnmarshalRPMTWithOptions(b []byte, o *DecodeOptions) (RPDU, []Violation, error) {
+	d := newDecoder(o)
+	p, e := unmarshalRPMT(b, cpData{}, d)
+	return p, d.warnings, e
 }
 
-func unmarshalRPMT(b []byte, c cpData) (RPDU, error) {
+func unmarshalRPMT(b []byte, c cpData, o *decoder) (RPDU, error) {
 	if len(b) == 0 {
-		return nil, io.EOF
+		return nil, DecodeError{Layer: "RP", Field: "RP-MTI", Err: io.EOF}
 	}
+	rb := b
 	switch b[0] & 0x07 {
 	case 0x01:
 		var rp rpData
 		var e error
-		if b, e = rp.unmarshal(false, b); e != nil {
+		if b, e = rp.unmarshal(false, b, o); e != nil {
 			return nil, e
 		}
+		if len(b) == 0 {
+			return nil, DecodeError{Layer: "TP", Field: "TP-MTI",
+				Offset: offsetIn(rb, b), Err: io.EOF}
+		}
 		rp.cpData = c
 
 		switch b[0] & 0x03 {
 		case 0x00:
 			var tp Deliver
-			e = tp.UnmarshalTP(b)
+			e = innerError(tp.unmarshalTP(b, o), rb, b)
 			tp.rpData = rp
 			return tp, e
 		case 0x02:
 			var tp StatusReport
-			e = tp.UnmarshalTP(b)
+			e = innerError(tp.unmarshalTP(b, o), rb, b)
 			tp.rpData = rp
 			return tp, e
 		}
+		return nil, DecodeError{Layer: "TP", Field: "TP-MTI",
+			Offset: offsetIn(rb, b),
+			Err:    UnknownMessageTypeError{Actual: b[0] & 0x03}}
 	case 0x03:
 		var rp RpAck
 		var e error
-		if b, e = rp.unmarshalRP(false, b); e != nil {
+		if b, e = rp.unmarshalRP(false, b, o); e != nil {
 			return nil, e
 		}
 		rp.cpData = c
@@ -131,14 +165,14 @@ func unmarshalRPMT(b []byte, c cpData) (RPDU, error) {
 		}
 
 		var tp SubmitReport
-		e = tp.UnmarshalTP(b)
+		e = innerError(tp.unmarshalTP(b, o), rb, b)
 		tp.cpData = rp.cpData
 		tp.RMR = rp.RMR
 		return tp, e
 	case 0x05:
 		var rp RpError
 		var e error
-		if b, e = rp.unmarshalRP(false, b); e != nil {
+		if b, e = rp.unmarshalRP(false, b, o); e != nil {
 			return nil, e
 		}
 		rp.cpData = c
@@ -147,26 +181,32 @@ func unmarshalRPMT(b []byte, c cpData) (RPDU, error) {
 		}
 
 		var tp SubmitReport
-		e = tp.UnmarshalTP(b)
+		e = innerError(tp.unmarshalTP(b, o), rb, b)
 		tp.cpData = rp.cpData
 		tp.RMR = rp.RMR
 		tp.CS = rp.CS
 		tp.DIAG = rp.DIAG
 		return tp, e
 	}
-	return nil, UnknownMessageTypeError{Actual: b[0]}
+	return nil, DecodeError{Layer: "RP", Field: "RP-MTI",
+		Err: UnknownMessageTypeError{Actual: b[0]}}
 }
 
-func unmarshalRpHeader(mti byte, b []byte) (byte, error) {
-	if len(b) < 2 {
-		return 0, io.EOF
+func unmarshalRpHeader(r *fieldReader, mti byte) (rmr byte, e error) {
+	r.next("RP-MTI")
+	var tmp byte
+	if tmp, e = r.ReadByte(); e != nil {
+		return 0, r.fail(e)
 	}
-
-	if b[0] != mti {
-		return 0, UnexpectedMessageTypeError{
-			Expected: mti, Actual: b[0]}
+	if tmp != mti {
+		return 0, r.fail(UnexpectedMessageTypeError{
+			Expected: mti, Actual: tmp})
+	}
+	r.next("RP-MR")
+	if rmr, e = r.ReadByte(); e != nil {
+		return 0, r.fail(e)
 	}
-	return b[1], nil
+	return
 }
 
 type rpData struct {
@@ -176,74 +216,65 @@ type rpData struct {
 	SCA Address `json:"rp-sca"` // M / SC Address
 }
 
-func (d rpData) marshal(mo bool, tp []byte) []byte {
-	w := new(bytes.Buffer)
-
+// appendRP append RP-DATA header to dst,
+// and returns the position of RP-User-Data
+func (d rpData) appendRP(mo bool, dst []byte) ([]byte, int) {
 	if mo {
-		w.WriteByte(0) // MTI
-		w.WriteByte(d.RMR)
-		w.WriteByte(0) // OA
-		_, a := d.SCA.Marshal()
-		w.WriteByte(byte(len(a)))
-		w.Write(a)
+		dst = append(dst, 0, d.RMR, 0) // MTI, MR, OA
+		dst = d.SCA.appendRP(dst)
 	} else {
-		w.WriteByte(1) // MTI
-		w.WriteByte(d.RMR)
-		_, a := d.SCA.Marshal()
-		w.WriteByte(byte(len(a)))
-		w.Write(a)
-		w.WriteByte(0) // DA
+		dst = append(dst, 1, d.RMR) // MTI, MR
+		dst = d.SCA.appendRP(dst)
+		dst = append(dst, 0) // DA
 	}
-	w.WriteByte(byte(len(tp)))
-	w.Write(tp)
-
-	return w.Bytes()
+	dst = append(dst, 0)
+	return dst, len(dst)
 }
 
-func (d *rpData) unmarshal(mo bool, b []byte) (tp []byte, e error) {
+func (d *rpData) unmarshal(
+	mo bool, b []byte, o *decoder) (tp []byte, e error) {
+	r := newFieldReader("RP", b, o)
 	if mo {
-		d.RMR, e = unmarshalRpHeader(0, b)
+		d.RMR, e = unmarshalRpHeader(r, 0)
 	} else {
-		d.RMR, e = unmarshalRpHeader(1, b)
+		d.RMR, e = unmarshalRpHeader(r, 1)
 	}
 	if e != nil {
 		return
 	}
 
-	r := bytes.NewReader(b[2:])
 	var tmp byte
 	if mo {
+		r.next("RP-OA")
 		if tmp, e = r.ReadByte(); e != nil {
-			return
+			return nil, r.fail(e)
 		} else if tmp != 0 {
-			e = ErrInvalidLength
-			return
+			return nil, r.fail(ErrInvalidLength)
 		}
 	}
-	if d.SCA, e = readRPAddr(r); e != nil {
-		return
+	f := "RP-OA"
+	if mo {
+		f = "RP-DA"
+	}
+	r.next(f)
+	if d.SCA, e = readRPAddr(r.Reader, f); e != nil {
+		return nil, r.fail(e)
 	}
 	if !mo {
+		r.next("RP-DA")
 		if tmp, e = r.ReadByte(); e != nil {
-			return
+			return nil, r.fail(e)
 		} else if tmp != 0 {
-			e = ErrInvalidLength
-			return
+			return nil, r.fail(ErrInvalidLength)
 		}
 	}
 
+	r.next("RP-User-Data")
 	if tmp, e = r.ReadByte(); e != nil {
-		return
-	}
-	tp = make([]byte, int(tmp))
-	var n int
-	if n, e = r.Read(tp); e != nil {
-		return
+		return nil, r.fail(e)
 	}
-	if n != len(tp) {
-		e = io.EOF
-	} else if r.Len() != 0 {
-		e = ErrExtraData
+	if tp, e = r.userData(int(tmp), o); e == nil {
+		e = o.checkTrailing(r)
 	}
 	return
 }
@@ -0,0 +1,141 @@
+package sms
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SubmittedSM is short message that is submitted to SC
+// and is subject of Command
+type SubmittedSM struct {
+	Submit
+	SCTS SCTimeStamp // time stamp that SC received the message
+	ST   byte        // current TP-ST of the message
+}
+
+// SubmittedStore is storage of submitted messages in SC.
+// The message is keyed by TP-MR and TP-DA of the Submit.
+type SubmittedStore interface {
+	Load(mr byte, da Address) (SubmittedSM, bool)
+	Store(m SubmittedSM)
+	Delete(mr byte, da Address)
+}
+
+// MemorySubmittedStore is in-memory SubmittedStore
+type MemorySubmittedStore struct {
+	mutex sync.Mutex
+	msgs  map[string]SubmittedSM
+}
+
+func submittedKey(mr byte, da Address) string {
+	return fmt.Sprintf("%d:%d:%d:%s", mr, da.TON, da.NPI, da.Addr)
+}
+
+// Load returns the message that has TP-MR mr and TP-DA da
+func (s *MemorySubmittedStore) Load(mr byte, da Address) (m SubmittedSM, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok = s.msgs[submittedKey(mr, da)]
+	return
+}
+
+// Store the message m
+func (s *MemorySubmittedStore) Store(m SubmittedSM) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.msgs == nil {
+		s.msgs = make(map[string]SubmittedSM)
+	}
+	s.msgs[submittedKey(m.TMR, m.DA)] = m
+}
+
+// Delete the message that has TP-MR mr and TP-DA da
+func (s *MemorySubmittedStore) Delete(mr byte, da Address) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.msgs, submittedKey(mr, da))
+}
+
+// CommandHandler applies Command to the submitted messages in SC
+type CommandHandler struct {
+	Store SubmittedStore
+}
+
+// Handle applies Command c at current time.
+func (h CommandHandler) Handle(c Command) (SubmitReport, []StatusReport) {
+	return h.HandleAt(c, time.Now())
+}
+
+// HandleAt applies Command c at time now, and returns SubmitReport
+// for c and StatusReports that are sent to the originator.
+// Unsupported command type is rejected with TP-FCS 0xA1, and
+// command for unknown or completed message is rejected with TP-FCS 0xA0.
+func (h CommandHandler) HandleAt(c Command, now time.Time) (
+	r SubmitReport, s []StatusReport) {
+	ts := stampAt(now)
+	r.TI = c.TI
+	r.RMR = c.RMR
+	r.SCTS = ts
+
+	switch c.CT {
+	case CommandEnquiry, CommandCancelSRR, CommandDelete, CommandEnableSRR:
+	default:
+		r.reject(0xA1)
+		return
+	}
+
+	m, ok := h.Store.Load(c.MN, c.DA)
+	if !ok {
+		if c.CT == CommandEnquiry {
+			// SM does not exist
+			s = append(s, StatusReport{
+				rpData: rpData{SCA: c.SCA},
+				SRQ:    true, TMR: c.TMR, RA: c.DA,
+				SCTS: ts, DT: ts, ST: 0x49})
+		} else {
+			r.reject(0xA0)
+		}
+		return
+	}
+	final := m.ST&0x60 != 0x20
+
+	switch c.CT {
+	case CommandEnquiry:
+	case CommandCancelSRR, CommandEnableSRR:
+		if final {
+			r.reject(0xA0)
+			return
+		}
+		m.SRR = c.CT == CommandEnableSRR
+		h.Store.Store(m)
+	case CommandDelete:
+		if final {
+			r.reject(0xA0)
+			return
+		}
+		h.Store.Delete(m.TMR, m.DA)
+		// SM deleted by originating SME
+		m.ST = 0x47
+		if m.SRR {
+			s = append(s, m.report(c.SCA, m.TMR, false, ts))
+		}
+	}
+
+	if c.SRR || c.CT == CommandEnquiry {
+		s = append(s, m.report(c.SCA, c.TMR, true, ts))
+	}
+	return
+}
+
+func (d *SubmitReport) reject(fcs byte) {
+	d.FCS = fcs
+	d.CS = 0x6f // Protocol error, unspecified
+}
+
+func (m SubmittedSM) report(sca Address, mr byte, srq bool, dt SCTimeStamp) StatusReport {
+	return StatusReport{
+		rpData: rpData{SCA: sca},
+		SRQ:    srq, TMR: mr, RA: m.DA,
+		SCTS: m.SCTS, DT: dt, ST: m.ST}
+}
@@ -0,0 +1,73 @@
+package sms_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+func TestCommandHandler(t *testing.T) {
+	da, _ := sms.ParseAddress("+819012345678")
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	st := &sms.MemorySubmittedStore{}
+	st.Store(sms.SubmittedSM{
+		Submit: sms.Submit{SRR: true, TMR: 10, DA: da},
+		SCTS:   scts(now.Add(-time.Minute)),
+		ST:     0x20})
+	h := sms.CommandHandler{Store: st}
+
+	// enquiry for the message in progress
+	r, s := h.HandleAt(sms.EnquiryCommand(11, 10, da), now)
+	t.Log(r, s)
+	if r.FCS != 0 || len(s) != 1 || s[0].ST != 0x20 || s[0].TMR != 11 || !s[0].SRQ {
+		t.Errorf("unexpected enquiry result %v %v", r, s)
+	}
+
+	// enquiry for unknown message
+	r, s = h.HandleAt(sms.EnquiryCommand(12, 20, da), now)
+	if r.FCS != 0 || len(s) != 1 || s[0].ST != 0x49 {
+		t.Errorf("unexpected enquiry result %v %v", r, s)
+	}
+
+	// cancel status report request
+	r, s = h.HandleAt(sms.CancelSRRCommand(13, 10, da), now)
+	if r.FCS != 0 || len(s) != 0 {
+		t.Errorf("unexpected cancel result %v %v", r, s)
+	}
+	if m, _ := st.Load(10, da); m.SRR {
+		t.Errorf("status report request is not cancelled")
+	}
+	r, _ = h.HandleAt(sms.EnableSRRCommand(14, 10, da), now)
+	if m, _ := st.Load(10, da); r.FCS != 0 || !m.SRR {
+		t.Errorf("status report request is not enabled")
+	}
+
+	// delete the message
+	r, s = h.HandleAt(sms.DeleteCommand(15, 10, da), now)
+	t.Log(r, s)
+	if r.FCS != 0 || len(s) != 1 || s[0].ST != 0x47 || s[0].TMR != 10 || s[0].SRQ {
+		t.Errorf("unexpected delete result %v %v", r, s)
+	}
+	if _, ok := st.Load(10, da); ok {
+		t.Errorf("message is not deleted")
+	}
+	r, _ = h.HandleAt(sms.DeleteCommand(16, 10, da), now)
+	if r.FCS != 0xA0 {
+		t.Errorf("unexpected FCS 0x%02x", r.FCS)
+	}
+
+	// completed message can not be deleted
+	st.Store(sms.SubmittedSM{Submit: sms.Submit{TMR: 30, DA: da}, ST: 0x00})
+	r, _ = h.HandleAt(sms.DeleteCommand(17, 30, da), now)
+	if r.FCS != 0xA0 {
+		t.Errorf("unexpected FCS 0x%02x", r.FCS)
+	}
+
+	// reserved command type
+	c := sms.DeleteCommand(18, 30, da)
+	c.CT = 0x10
+	if r, _ = h.HandleAt(c, now); r.FCS != 0xA1 {
+		t.Errorf("unexpected FCS 0x%02x", r.FCS)
+	}
+}
@@ -0,0 +1,113 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DuplicateWindow is default period that submitted message
+// is checked for duplication
+var DuplicateWindow = time.Duration(24 * time.Hour)
+
+// DuplicateDetector detects Submit that has same originating address,
+// TP-MR and TP-DA as the message accepted within the window.
+type DuplicateDetector struct {
+	// Window is period that the accepted message is remembered,
+	// DuplicateWindow is used if zero
+	Window time.Duration
+
+	// Duplicated is called when duplicated Submit with TP-RD=false
+	// is accepted
+	Duplicated func(oa Address, s Submit)
+
+	mutex sync.Mutex
+	seen  map[string]time.Time
+	queue []seenSM // accepted messages in time order
+}
+
+type seenSM struct {
+	key string
+	at  time.Time
+}
+
+func duplicateKey(oa Address, s Submit) string {
+	return fmt.Sprintf("%d:%d:%s/%d/%d:%d:%s",
+		oa.TON, oa.NPI, oa.Addr, s.TMR, s.DA.TON, s.DA.NPI, s.DA.Addr)
+}
+
+func (d *DuplicateDetector) window() time.Duration {
+	if d.Window != 0 {
+		return d.Window
+	}
+	return DuplicateWindow
+}
+
+// expire forgets messages that are older than the window at now
+func (d *DuplicateDetector) expire(now time.Time) {
+	w := d.window()
+	i := 0
+	for ; i < len(d.queue) && now.Sub(d.queue[i].at) >= w; i++ {
+		k := d.queue[i].key
+		if d.seen[k].Equal(d.queue[i].at) {
+			delete(d.seen, k)
+		}
+	}
+	d.queue = d.queue[i:]
+}
+
+// Check returns true if Submit s from oa is same as
+// the message accepted within the window at time now.
+func (d *DuplicateDetector) Check(oa Address, s Submit, now time.Time) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.expire(now)
+	t, ok := d.seen[duplicateKey(oa, s)]
+	return ok && now.Sub(t) < d.window()
+}
+
+// Record remembers Submit s from oa that is accepted at time now.
+// now must not be before the time of the previous record.
+func (d *DuplicateDetector) Record(oa Address, s Submit, now time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[string]time.Time)
+	}
+	d.expire(now)
+	k := duplicateKey(oa, s)
+	d.seen[k] = now
+	d.queue = append(d.queue, seenSM{key: k, at: now})
+}
+
+// TranspInd returns handler for SMR.TranspIndContext that checks Submit
+// before next. Originating address is taken from ctx by OriginatorFrom.
+// Duplicated Submit with TP-RD=true is rejected with TP-FCS 0xC5,
+// and other is passed to next. Submit is recorded only if next accepts it.
+func (d *DuplicateDetector) TranspInd(next func(context.Context, TPDU) (TPDU, error)) func(context.Context, TPDU) (TPDU, error) {
+	return func(ctx context.Context, p TPDU) (TPDU, error) {
+		s, ok := p.(Submit)
+		if !ok {
+			return next(ctx, p)
+		}
+		oa, _ := OriginatorFrom(ctx)
+		if d.Check(oa, s, time.Now()) {
+			if s.RD {
+				r := SubmitReport{RMR: s.RMR, SCTS: stampAt(time.Now())}
+				r.TI = s.TI
+				r.reject(0xC5)
+				return r, nil
+			}
+			if d.Duplicated != nil {
+				d.Duplicated(oa, s)
+			}
+		}
+
+		a, e := next(ctx, p)
+		if r, ok := a.(SubmitReport); e == nil && (!ok || r.FCS&0x80 == 0) {
+			d.Record(oa, s, time.Now())
+		}
+		return a, e
+	}
+}
@@ -0,0 +1,90 @@
+package sms_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+func TestDuplicateDetector(t *testing.T) {
+	oa, _ := sms.ParseAddress("+819012345678")
+	da, _ := sms.ParseAddress("+819087654321")
+	now := time.Now()
+	d := &sms.DuplicateDetector{Window: time.Minute}
+
+	s := sms.Submit{TMR: 1, DA: da}
+	if d.Check(oa, s, now) {
+		t.Errorf("first message is duplicated")
+	}
+	if d.Check(oa, s, now.Add(time.Second)) {
+		t.Errorf("message that is not recorded is duplicated")
+	}
+	d.Record(oa, s, now.Add(time.Second))
+	if !d.Check(oa, s, now.Add(2*time.Second)) {
+		t.Errorf("duplication is not detected")
+	}
+	s.TMR = 2
+	if d.Check(oa, s, now.Add(2*time.Second)) {
+		t.Errorf("different TP-MR is duplicated")
+	}
+	d.Record(oa, s, now.Add(30*time.Second))
+	if !d.Check(oa, s, now.Add(80*time.Second)) {
+		t.Errorf("message in the window is expired")
+	}
+	s.TMR = 1
+	if d.Check(oa, s, now.Add(80*time.Second)) {
+		t.Errorf("expired message is duplicated")
+	}
+}
+
+func TestDuplicateTranspInd(t *testing.T) {
+	oa, _ := sms.ParseAddress("+819012345678")
+	oa2, _ := sms.ParseAddress("+819011112222")
+	da, _ := sms.ParseAddress("+819087654321")
+	var dup, passed int
+	var fcs byte
+	d := &sms.DuplicateDetector{
+		Duplicated: func(sms.Address, sms.Submit) { dup++ }}
+	f := d.TranspInd(func(context.Context, sms.TPDU) (sms.TPDU, error) {
+		passed++
+		if fcs != 0 {
+			return sms.SubmitReport{FCS: fcs}, nil
+		}
+		return nil, nil
+	})
+	ctx := sms.WithOriginator(context.Background(), oa)
+
+	s := sms.Submit{TMR: 1, DA: da}
+	s.RMR = 5
+	s.RD = true
+
+	// rejected message is not recorded
+	fcs = 0xd0
+	f(ctx, s)
+	fcs = 0
+	if a, _ := f(ctx, s); a != nil || passed != 2 {
+		t.Errorf("retry of rejected message is duplicated %v", a)
+	}
+
+	a, e := f(ctx, s)
+	if e != nil {
+		t.Fatal(e)
+	}
+	r, ok := a.(sms.SubmitReport)
+	if !ok || r.FCS != 0xC5 || r.RMR != 5 || passed != 2 {
+		t.Errorf("unexpected answer %v", a)
+	}
+
+	// same TP-MR from other originator
+	if a, _ = f(sms.WithOriginator(context.Background(), oa2), s); a != nil {
+		t.Errorf("message from other originator is duplicated %v", a)
+	}
+
+	s.RD = false
+	f(ctx, s)
+	if dup != 1 || passed != 4 {
+		t.Errorf("unexpected result %d/%d", dup, passed)
+	}
+}
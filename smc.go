@@ -1,120 +1,301 @@
 package sms
 
-/*
-type state byte
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// TC1M timer waiting time for CP-ACK
+	TC1M = time.Duration(20 * time.Second)
+	// CPRetransmission is maximum count of CP-DATA retransmission
+	CPRetransmission = 2
+)
+
+// CMState is state of SM-CM transaction
+type CMState byte
 
 const (
-	idle       state = iota
-	connecting state = iota
-	waitAck    state = iota
-	waitData   state = iota
+	// CMIdle is MO-Idle/MT-Idle state
+	CMIdle CMState = iota
+	// CMWaitForCPAck is MO-Wait for CP-ACK/MT-Wait for CP-ACK state
+	CMWaitForCPAck
+	// CMConnectionEstablished is MO-MM-connection established/
+	// MT-MM-connection established state
+	CMConnectionEstablished
 )
-*/
 
-// SMC is SM-CP handler
+func (s CMState) String() string {
+	switch s {
+	case CMIdle:
+		return "Idle"
+	case CMWaitForCPAck:
+		return "Wait for CP-ACK"
+	case CMConnectionEstablished:
+		return "MM connection established"
+	}
+	return fmt.Sprintf("Unknown(%d)", byte(s))
+}
+
+// SMC is SM-CM entity of MS or SC
 type SMC struct {
-	rxStack   [7]chan CPDU
-	txStack   [7]chan CPDU
 	SCAddress Address
+	// TC1M overrides TC1M timer value if it is not zero
+	TC1M time.Duration
+	// MaxRetrans overrides CPRetransmission if it is not zero,
+	// negative value disables retransmission
+	MaxRetrans int
+
+	CtrlReq   func(CPDU)
+	TranspInd func(TPDU) (TPDU, error)
+	// TranspIndContext is used instead of TranspInd if it is not nil,
+	// the context is canceled when the transaction is released by peer
+	TranspIndContext func(context.Context, TPDU) (TPDU, error)
+	MemAvailInd      func() error
+	// RelayInd is used instead of TranspInd and MemAvailInd if it is
+	// not nil, RPDU in CP-DATA is passed to upper SMR as is.
+	// CpError returned from it is sent to peer.
+	RelayInd func(RPDU) (RPDU, error)
+
+	mutex  sync.Mutex
+	tx     [7]*cmTransaction // transactions originated by this entity
+	rx     [7]*cmTransaction // transactions originated by peer
+	next   byte              // next TI candidate
+	relays map[byte]*relayReq
+}
+
+// relayReq is RPDU of upper SMR that is sent by RelayReq
+type relayReq struct {
+	cancel func()
+}
+
+type cmTransaction struct {
+	state  CMState
+	data   CPDU // CP-DATA for retransmission
+	retry  int
+	seq    int // sequence number of TC1M, it invalidates stopped timer
+	timer  *time.Timer
+	answer chan CPDU // CP-DATA or CP-ERROR from peer, nil for TC1M expiry
+	cancel func()    // cancel indication to upper layer
+}
+
+func (smc *SMC) tc1m() time.Duration {
+	if smc.TC1M != 0 {
+		return smc.TC1M
+	}
+	return TC1M
+}
+
+func (smc *SMC) maxRetrans() int {
+	if smc.MaxRetrans != 0 {
+		return smc.MaxRetrans
+	}
+	return CPRetransmission
+}
+
+func (smc *SMC) ctrlReq(pdu CPDU) {
+	if smc.CtrlReq != nil {
+		smc.CtrlReq(pdu)
+	}
+}
+
+// State returns state of the transaction ti.
+// TI flag of ti is same as the CPDU that is sent by this entity,
+// so ti without TI flag shows the transaction originated by this entity.
+func (smc *SMC) State(ti byte) CMState {
+	smc.mutex.Lock()
+	defer smc.mutex.Unlock()
+
+	s := &smc.tx
+	if ti&0x08 == 0x08 {
+		s = &smc.rx
+	}
+	if ti&0x07 == 0x07 || s[ti&0x07] == nil {
+		return CMIdle
+	}
+	return s[ti&0x07].state
+}
+
+// startTC1M start TC1M of the transaction t, mutex must be locked
+func (smc *SMC) startTC1M(s *[7]*cmTransaction, ti byte, t *cmTransaction) {
+	t.seq++
+	seq := t.seq
+	t.timer = time.AfterFunc(smc.tc1m(), func() {
+		smc.expireTC1M(s, ti, t, seq)
+	})
+}
+
+// stopTC1M stop TC1M of the transaction t, mutex must be locked
+func stopTC1M(t *cmTransaction) {
+	t.seq++
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+func (smc *SMC) expireTC1M(s *[7]*cmTransaction, ti byte, t *cmTransaction, seq int) {
+	smc.mutex.Lock()
+	if s[ti] != t || t.seq != seq || t.state != CMWaitForCPAck {
+		smc.mutex.Unlock()
+		return
+	}
+	if t.retry < smc.maxRetrans() {
+		t.retry++
+		smc.startTC1M(s, ti, t)
+		d := t.data
+		smc.mutex.Unlock()
+		smc.ctrlReq(d)
+		return
+	}
+	s[ti] = nil
+	smc.mutex.Unlock()
 
-	CtrlReq     func(CPDU)
-	TranspInd   func(TPDU) (TPDU, error)
-	MemAvailInd func() error
+	if t.answer != nil {
+		t.answer <- nil
+	}
 }
 
 // CtrlInd handle CP-DATA/CP-ACK/CP-ERROR
-func (smc SMC) CtrlInd(pdu CPDU) {
+func (smc *SMC) CtrlInd(pdu CPDU) {
 	var ti byte
-	var isTx bool
-
 	switch v := pdu.(type) {
-	case Submit:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-		if !isTx && smc.rxStack[ti] == nil {
-			c := make(chan CPDU)
-			smc.rxStack[ti] = c
-			go smc.rxHandler(c, ti)
-		}
-	case Command:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-		if !isTx && smc.rxStack[ti] == nil {
-			c := make(chan CPDU)
-			smc.rxStack[ti] = c
-			go smc.rxHandler(c, ti)
-		}
-	case Deliver:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-		if !isTx && smc.rxStack[ti] == nil {
-			c := make(chan CPDU)
-			smc.rxStack[ti] = c
-			go smc.rxHandler(c, ti)
-		}
-	case StatusReport:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-		if !isTx && smc.rxStack[ti] == nil {
-			c := make(chan CPDU)
-			smc.rxStack[ti] = c
-			go smc.rxHandler(c, ti)
-		}
-	case SubmitReport:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-	case DeliverReport:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-	case MemoryAvailable:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-	case RpAckMT:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-	case RpAckMO:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-	case RpErrorMT:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
-	case RpErrorMO:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
 	case CpAck:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
+		ti = v.TI
 	case CpError:
-		ti = v.TI & 0x07
-		isTx = v.TI&0x08 == 0x08
+		ti = v.TI
+	case interface{ transactionID() byte }:
+		ti = v.transactionID()
 	default:
-		smc.CtrlReq(CpError{TI: ti, CS: 98})
+		smc.ctrlReq(CpError{TI: ti, CS: 98})
 		return
 	}
 
-	var c chan CPDU
-	if isTx {
-		c = smc.txStack[ti]
-	} else {
-		c = smc.rxStack[ti]
+	_, isData := pdu.(interface{ transactionID() byte })
+	if ti&0x07 == 0x07 {
+		// TI extension is not supported
+		if isData {
+			smc.ctrlReq(CpError{TI: ti ^ 0x08, CS: 81})
+		}
+		return
 	}
-	if c != nil {
-		c <- pdu
-	} else if isTx {
-		smc.CtrlReq(CpError{TI: ti, CS: 81})
+	if ti&0x08 == 0x08 {
+		smc.txInd(ti&0x07, pdu, isData)
 	} else {
-		smc.CtrlReq(CpError{TI: ti | 0x08, CS: 81})
+		smc.rxInd(ti&0x07, pdu, isData)
 	}
 }
 
-func (smc SMC) rxHandler(c chan CPDU, ti byte) {
-	ti = ti | 0x08
-	defer func() {
-		smc.rxStack[ti&0x07] = nil
-		close(c)
-	}()
+func (smc *SMC) txInd(ti byte, pdu CPDU, isData bool) {
+	smc.mutex.Lock()
+	t := smc.tx[ti]
+	if t == nil {
+		smc.mutex.Unlock()
+		if isData {
+			smc.ctrlReq(CpError{TI: ti, CS: 81})
+		}
+		return
+	}
 
-	pdu := <-c
+	switch pdu.(type) {
+	case CpAck:
+		if t.state == CMWaitForCPAck {
+			stopTC1M(t)
+			t.state = CMConnectionEstablished
+		}
+		smc.mutex.Unlock()
+	case CpError:
+		stopTC1M(t)
+		smc.tx[ti] = nil
+		smc.mutex.Unlock()
+		t.answer <- pdu
+	default:
+		// CP-DATA in wait for CP-ACK state is implicit CP-ACK
+		stopTC1M(t)
+		smc.tx[ti] = nil
+		smc.mutex.Unlock()
+		smc.ctrlReq(CpAck{TI: ti})
+		t.answer <- pdu
+	}
+}
+
+func (smc *SMC) rxInd(ti byte, pdu CPDU, isData bool) {
+	smc.mutex.Lock()
+	t := smc.rx[ti]
+
+	switch {
+	case isData && t != nil:
+		// retransmitted CP-DATA, previous CP-ACK might be lost
+		smc.mutex.Unlock()
+		smc.ctrlReq(CpAck{TI: ti | 0x08})
+	case isData:
+		ctx, cancel := context.WithCancel(context.Background())
+		t = &cmTransaction{state: CMConnectionEstablished, cancel: cancel}
+		smc.rx[ti] = t
+		smc.mutex.Unlock()
+		smc.ctrlReq(CpAck{TI: ti | 0x08})
+		go smc.relay(ctx, ti, t, pdu)
+	case t == nil:
+		smc.mutex.Unlock()
+	default:
+		if _, ok := pdu.(CpAck); !ok || t.state == CMWaitForCPAck {
+			stopTC1M(t)
+			smc.rx[ti] = nil
+			if t.cancel != nil {
+				t.cancel()
+			}
+		}
+		smc.mutex.Unlock()
+	}
+}
+
+// relay indicate received RPDU to upper layer and send the answer
+func (smc *SMC) relay(ctx context.Context, ti byte, t *cmTransaction, pdu CPDU) {
+	a := smc.relayAnswer(ctx, pdu, ti|0x08)
+	t.cancel()
+
+	smc.mutex.Lock()
+	if smc.rx[ti] != t {
+		// released by CP-ERROR
+		smc.mutex.Unlock()
+		return
+	}
+	if _, ok := a.(CpError); ok || a == nil {
+		// no answer from upper SMR
+		smc.rx[ti] = nil
+		smc.mutex.Unlock()
+		if a != nil {
+			smc.ctrlReq(a)
+		}
+		return
+	}
+	t.data = a
+	t.state = CMWaitForCPAck
+	smc.startTC1M(&smc.rx, ti, t)
+	smc.mutex.Unlock()
+
+	smc.ctrlReq(a)
+}
+
+func (smc *SMC) relayAnswer(ctx context.Context, pdu CPDU, ti byte) CPDU {
+	if smc.RelayInd != nil {
+		r, ok := pdu.(RPDU)
+		if !ok {
+			return CpError{TI: ti, CS: 95}
+		}
+		a, e := smc.RelayInd(r)
+		if ce, ok := e.(CpError); ok {
+			ce.TI = ti
+			return ce
+		} else if e != nil {
+			return CpError{TI: ti, CS: 111}
+		}
+		if a == nil {
+			return nil
+		}
+		return withTI(a, ti)
+	}
 
 	var a TPDU
 	var e error
@@ -123,43 +304,22 @@ func (smc SMC) rxHandler(c chan CPDU, ti byte) {
 
 	switch v := pdu.(type) {
 	case Submit:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = true
-		if smc.TranspInd == nil {
-			e = RpError{CS: 97}
-		} else {
-			a, e = smc.TranspInd(v)
-		}
+		a, e = smc.transpInd(ctx, v)
 	case Command:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = true
-		if smc.TranspInd == nil {
-			e = RpError{CS: 97}
-		} else {
-			a, e = smc.TranspInd(v)
-		}
+		a, e = smc.transpInd(ctx, v)
 	case Deliver:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = false
-		if smc.TranspInd == nil {
-			e = RpError{CS: 97}
-		} else {
-			a, e = smc.TranspInd(v)
-		}
+		a, e = smc.transpInd(ctx, v)
 	case StatusReport:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = false
-		if smc.TranspInd == nil {
-			e = RpError{CS: 97}
-		} else {
-			a, e = smc.TranspInd(v)
-		}
+		a, e = smc.transpInd(ctx, v)
 	case MemoryAvailable:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = true
 		if smc.MemAvailInd == nil {
@@ -168,39 +328,29 @@ func (smc SMC) rxHandler(c chan CPDU, ti byte) {
 			e = smc.MemAvailInd()
 		}
 	case DeliverReport:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = true
 		e = RpError{CS: 95}
 	case SubmitReport:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = false
 		e = RpError{CS: 95}
 	case RpAckMO:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = true
 		e = RpError{CS: 95}
 	case RpAckMT:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = false
 		e = RpError{CS: 95}
 	case RpErrorMO:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = true
 		e = RpError{CS: 95}
 	case RpErrorMT:
-		smc.CtrlReq(CpAck{TI: ti})
 		mr = v.RMR
 		isNW = false
 		e = RpError{CS: 95}
-	case CpAck:
-		return
-	case CpError:
-		return
 	}
 
 	if e == nil {
@@ -209,166 +359,319 @@ func (smc SMC) rxHandler(c chan CPDU, ti byte) {
 			if isNW {
 				v.RMR = mr
 				v.TI = ti
-				smc.CtrlReq(v)
-			} else {
-				e = RpError{CS: 111}
+				return v
 			}
 		case DeliverReport:
 			if !isNW {
 				v.RMR = mr
 				v.TI = ti
-				smc.CtrlReq(v)
-			} else {
-				e = RpError{CS: 111}
+				return v
 			}
 		case nil:
 			a := RpAck{RMR: mr}
 			a.TI = ti
 			if isNW {
-				smc.CtrlReq(RpAckMT(a))
-			} else {
-				smc.CtrlReq(RpAckMO(a))
+				return RpAckMT(a)
 			}
-		default:
-			e = RpError{CS: 111}
+			return RpAckMO(a)
 		}
 	}
 
-	switch v := e.(type) {
-	case RpError:
-		v.RMR = mr
-		v.TI = ti
-		if isNW {
-			smc.CtrlReq(RpErrorMT(v))
-		} else {
-			smc.CtrlReq(RpErrorMO(v))
-		}
-	case nil:
-	default:
-		a := RpError{RMR: mr, CS: 111}
-		a.TI = ti
-		if isNW {
-			smc.CtrlReq(RpErrorMT(a))
-		} else {
-			smc.CtrlReq(RpErrorMO(a))
-		}
+	v, ok := e.(RpError)
+	if !ok {
+		v = RpError{CS: 111}
 	}
-
-	<-c
+	v.RMR = mr
+	v.TI = ti
+	if isNW {
+		return RpErrorMT(v)
+	}
+	return RpErrorMO(v)
 }
 
-// TranspReq handle TP-DATA
-func (smc SMC) TranspReq(pdu TPDU) (TPDU, error) {
-	var ti byte = 255
-	c := make(chan CPDU)
-	for i, sc := range smc.txStack {
-		if sc == nil {
-			ti = byte(i)
-			smc.txStack[i] = c
-			break
-		}
+func (smc *SMC) transpInd(ctx context.Context, pdu TPDU) (TPDU, error) {
+	if smc.TranspIndContext != nil {
+		return smc.TranspIndContext(ctx, pdu)
 	}
-	if ti == 255 {
-		return nil, RpError{CS: 42}
+	if smc.TranspInd == nil {
+		return nil, RpError{CS: 97}
 	}
+	return smc.TranspInd(pdu)
+}
 
-	defer func() {
-		smc.txStack[ti] = nil
-		close(c)
-	}()
+// TranspReq send TPDU in new transaction and returns the answer.
+// CP-DATA is retransmitted on TC1M expiry, and ErrTC1MExpired is
+// returned when CP-ACK is not received after retransmissions.
+func (smc *SMC) TranspReq(pdu TPDU) (TPDU, error) {
+	return smc.TranspReqContext(context.Background(), pdu)
+}
 
+// TranspReqContext is TranspReq that aborts the transaction on cancel
+// of ctx. CP-ERROR is sent to peer and the error of ctx is returned.
+func (smc *SMC) TranspReqContext(ctx context.Context, pdu TPDU) (TPDU, error) {
 	var isNW bool
-	switch v := pdu.(type) {
-	case Submit:
+	switch pdu.(type) {
+	case Submit, Command:
 		isNW = false
-		v.TI = ti
-		v.RMR = ti
-		v.SCA = smc.SCAddress
-	case Command:
-		isNW = false
-		v.TI = ti
-		v.RMR = ti
-		v.SCA = smc.SCAddress
-	case Deliver:
-		isNW = true
-		v.TI = ti
-		v.RMR = ti
-		v.SCA = smc.SCAddress
-	case StatusReport:
+	case Deliver, StatusReport:
 		isNW = true
-		v.TI = ti
-		v.RMR = ti
-		v.SCA = smc.SCAddress
 	default:
 		return nil, RpError{CS: 97}
 	}
-	smc.CtrlReq(pdu)
 
-	a := <-c
-	switch v := a.(type) {
-	case CpAck:
-		a = <-c
-	case CpError:
-		return nil, v
+	answer, mr, e := smc.exchange(ctx, func(mr byte) CPDU {
+		switch v := pdu.(type) {
+		case Submit:
+			v.TI, v.RMR, v.SCA = mr, mr, smc.SCAddress
+			return v
+		case Command:
+			v.TI, v.RMR, v.SCA = mr, mr, smc.SCAddress
+			return v
+		case Deliver:
+			v.TI, v.RMR, v.SCA = mr, mr, smc.SCAddress
+			return v
+		case StatusReport:
+			v.TI, v.RMR, v.SCA = mr, mr, smc.SCAddress
+			return v
+		}
+		return nil
+	})
+	if e != nil {
+		return nil, e
 	}
 
-	switch v := a.(type) {
+	switch v := answer.(type) {
 	case SubmitReport:
-		if v.RMR != ti {
-			smc.CtrlReq(CpError{TI: ti, CS: 81})
+		if v.RMR != mr {
 			return nil, RpError{CS: 81}
 		}
 		if !isNW {
-			smc.CtrlReq(CpAck{TI: ti})
 			return v, nil
 		}
 	case DeliverReport:
-		if v.RMR != ti {
-			smc.CtrlReq(CpError{TI: ti, CS: 81})
+		if v.RMR != mr {
 			return nil, RpError{CS: 81}
 		}
 		if isNW {
-			smc.CtrlReq(CpAck{TI: ti})
 			return v, nil
 		}
 	case RpAckMT:
-		if v.RMR != ti {
-			smc.CtrlReq(CpError{TI: ti, CS: 81})
+		if v.RMR != mr {
 			return nil, RpError{CS: 81}
 		}
 		if !isNW {
-			smc.CtrlReq(CpAck{TI: ti})
 			return nil, nil
 		}
 	case RpAckMO:
-		if v.RMR != ti {
-			smc.CtrlReq(CpError{TI: ti, CS: 81})
+		if v.RMR != mr {
 			return nil, RpError{CS: 81}
 		}
 		if isNW {
-			smc.CtrlReq(CpAck{TI: ti})
 			return nil, nil
 		}
 	case RpErrorMT:
-		if v.RMR != ti {
-			smc.CtrlReq(CpError{TI: ti, CS: 81})
+		if v.RMR != mr {
 			return nil, RpError{CS: 81}
 		}
 		if !isNW {
-			smc.CtrlReq(CpAck{TI: ti})
 			return nil, RpError(v)
 		}
 	case RpErrorMO:
-		if v.RMR != ti {
-			smc.CtrlReq(CpError{TI: ti, CS: 81})
+		if v.RMR != mr {
 			return nil, RpError{CS: 81}
 		}
 		if isNW {
-			smc.CtrlReq(CpAck{TI: ti})
 			return nil, RpError(v)
 		}
 	}
-
-	smc.CtrlReq(CpError{TI: ti, CS: 95})
 	return nil, RpError{CS: 95}
 }
+
+// MemAvailReq send RP-SMMA in new transaction and wait RP-ACK.
+// RP-SMMA is not retransmitted on RP-ERROR.
+func (smc *SMC) MemAvailReq() error {
+	return smc.MemAvailReqContext(context.Background())
+}
+
+// MemAvailReqContext is MemAvailReq that aborts the transaction
+// on cancel of ctx
+func (smc *SMC) MemAvailReqContext(ctx context.Context) error {
+	answer, mr, e := smc.exchange(ctx, func(mr byte) CPDU {
+		p := MemoryAvailable{RMR: mr}
+		p.TI = mr
+		return p
+	})
+	if e != nil {
+		return e
+	}
+
+	switch v := answer.(type) {
+	case RpAckMT:
+		if v.RMR != mr {
+			return RpError{CS: 81}
+		}
+		return nil
+	case RpErrorMT:
+		if v.RMR != mr {
+			return RpError{CS: 81}
+		}
+		return RpError(v)
+	}
+	return RpError{CS: 95}
+}
+
+// RelayReq send RP-DATA or RP-SMMA of upper SMR in new transaction,
+// and the answer is passed to RelayInd.
+// SMC is RelayLink and RelayAborter, so it can be set to SMR by SetLink.
+func (smc *SMC) RelayReq(p RPDU) error {
+	var mr byte
+	switch v := p.(type) {
+	case Submit:
+		mr = v.RMR
+	case Command:
+		mr = v.RMR
+	case Deliver:
+		mr = v.RMR
+	case StatusReport:
+		mr = v.RMR
+	case MemoryAvailable:
+		mr = v.RMR
+	default:
+		return RpError{CS: 97}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &relayReq{cancel: cancel}
+	smc.mutex.Lock()
+	if smc.relays == nil {
+		smc.relays = make(map[byte]*relayReq)
+	}
+	smc.relays[mr] = r
+	smc.mutex.Unlock()
+
+	go func() {
+		answer, _, e := smc.exchange(ctx, func(ti byte) CPDU {
+			return withTI(p, ti)
+		})
+		cancel()
+		smc.mutex.Lock()
+		if smc.relays[mr] == r {
+			delete(smc.relays, mr)
+		}
+		smc.mutex.Unlock()
+
+		// SMR waits the answer until TR1M expiry if it is not received
+		if a, ok := answer.(RPDU); ok && e == nil && smc.RelayInd != nil {
+			smc.RelayInd(a)
+		}
+	}()
+	return nil
+}
+
+// AbortReq release the transaction of RPDU that is sent by RelayReq
+// with RP-MR mr, CP-ERROR is sent to peer
+func (smc *SMC) AbortReq(mr byte) {
+	smc.mutex.Lock()
+	r := smc.relays[mr]
+	smc.mutex.Unlock()
+	if r != nil {
+		r.cancel()
+	}
+}
+
+// withTI returns p that is sent in CP-DATA of ti
+func withTI(p RPDU, ti byte) CPDU {
+	switch v := p.(type) {
+	case Submit:
+		v.TI = ti
+		return v
+	case Command:
+		v.TI = ti
+		return v
+	case Deliver:
+		v.TI = ti
+		return v
+	case StatusReport:
+		v.TI = ti
+		return v
+	case MemoryAvailable:
+		v.TI = ti
+		return v
+	case SubmitReport:
+		v.TI = ti
+		return v
+	case DeliverReport:
+		v.TI = ti
+		return v
+	case RpAckMO:
+		v.TI = ti
+		return v
+	case RpAckMT:
+		v.TI = ti
+		return v
+	case RpErrorMO:
+		v.TI = ti
+		return v
+	case RpErrorMT:
+		v.TI = ti
+		return v
+	}
+	return p
+}
+
+// exchange send CP-DATA that is made by data in new transaction,
+// and returns the answer and its TI
+func (smc *SMC) exchange(ctx context.Context, data func(ti byte) CPDU) (
+	CPDU, byte, error) {
+	t := &cmTransaction{
+		state:  CMWaitForCPAck,
+		answer: make(chan CPDU, 1)}
+	smc.mutex.Lock()
+	ti := -1
+	for i := range smc.tx {
+		j := (int(smc.next) + i) % len(smc.tx)
+		if smc.tx[j] == nil {
+			ti = j
+			smc.tx[j] = t
+			smc.next = byte(j+1) % byte(len(smc.tx))
+			break
+		}
+	}
+	if ti == -1 {
+		smc.mutex.Unlock()
+		return nil, 0, RpError{CS: 42}
+	}
+
+	mr := byte(ti)
+	pdu := data(mr)
+	t.data = pdu
+	smc.startTC1M(&smc.tx, mr, t)
+	smc.mutex.Unlock()
+
+	smc.ctrlReq(pdu)
+
+	var answer CPDU
+	select {
+	case answer = <-t.answer:
+	case <-ctx.Done():
+		smc.mutex.Lock()
+		if smc.tx[mr] != t {
+			// already released by the answer
+			smc.mutex.Unlock()
+			return nil, mr, ctx.Err()
+		}
+		stopTC1M(t)
+		smc.tx[mr] = nil
+		smc.mutex.Unlock()
+		smc.ctrlReq(CpError{TI: mr, CS: 111})
+		return nil, mr, ctx.Err()
+	}
+
+	switch v := answer.(type) {
+	case nil:
+		return nil, mr, ErrTC1MExpired
+	case CpError:
+		return nil, mr, v
+	}
+	return answer, mr, nil
+}
@@ -0,0 +1,307 @@
+package sms_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+	"github.com/fkgi/teldata"
+)
+
+// connectSMC connect MS side SMC ms and SC side SMC sc with loopback,
+// CPDU is dropped if drop returns true
+func connectSMC(t *testing.T, ms, sc *sms.SMC, drop func(mo bool, p sms.CPDU) bool) {
+	l := &sms.CtrlLoopback{Drop: drop}
+	l.Connect(ms, sc)
+	t.Cleanup(l.Close)
+}
+
+func newSMC() *sms.SMC {
+	smc := &sms.SMC{SCAddress: sms.Address{
+		TON: sms.TypeInternational, NPI: sms.PlanISDNTelephone}}
+	smc.SCAddress.Addr, _ = teldata.ParseTBCD("447785016005")
+	return smc
+}
+
+func waitIdle(t *testing.T, smc *sms.SMC) {
+	for i := 0; i < 100; i++ {
+		idle := true
+		for ti := byte(0); ti < 16; ti++ {
+			if smc.State(ti) != sms.CMIdle {
+				idle = false
+			}
+		}
+		if idle {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Error("transaction is not released")
+}
+
+func TestSMCTransfer(t *testing.T) {
+	ms := newSMC()
+	sc := newSMC()
+	connectSMC(t, ms, sc, nil)
+
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		if _, ok := p.(sms.Submit); !ok {
+			t.Errorf("unexpected TPDU %T", p)
+		}
+		return sms.SubmitReport{SCTS: scts(time.Now())}, nil
+	}
+	ms.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		if _, ok := p.(sms.Deliver); !ok {
+			t.Errorf("unexpected TPDU %T", p)
+		}
+		return nil, nil
+	}
+
+	a, e := ms.TranspReq(randSubmit())
+	if e != nil {
+		t.Fatalf("submit failed: %s", e)
+	}
+	if _, ok := a.(sms.SubmitReport); !ok {
+		t.Errorf("unexpected answer %T", a)
+	}
+
+	a, e = sc.TranspReq(randDeliver())
+	if e != nil {
+		t.Fatalf("deliver failed: %s", e)
+	}
+	if a != nil {
+		t.Errorf("unexpected answer %T", a)
+	}
+
+	ms.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		return nil, sms.RpError{CS: 22}
+	}
+	_, e = sc.TranspReq(randDeliver())
+	if re, ok := e.(sms.RpError); !ok || re.CS != 22 {
+		t.Errorf("unexpected error %v", e)
+	}
+
+	waitIdle(t, ms)
+	waitIdle(t, sc)
+}
+
+func TestSMCRetransmission(t *testing.T) {
+	ms := newSMC()
+	sc := newSMC()
+	ms.TC1M = time.Millisecond * 20
+	sc.TC1M = time.Millisecond * 20
+
+	var mu sync.Mutex
+	dropData, dropAck := 1, 1
+	connectSMC(t, ms, sc, func(mo bool, p sms.CPDU) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := p.(sms.Submit); ok && dropData > 0 {
+			dropData--
+			return true
+		}
+		if _, ok := p.(sms.CpAck); ok && !mo && dropAck > 0 {
+			dropAck--
+			return true
+		}
+		return false
+	})
+
+	var count int32
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		atomic.AddInt32(&count, 1)
+		time.Sleep(time.Millisecond * 50)
+		return nil, nil
+	}
+
+	if _, e := ms.TranspReq(randSubmit()); e != nil {
+		t.Fatalf("submit failed: %s", e)
+	}
+	if c := atomic.LoadInt32(&count); c != 1 {
+		t.Errorf("TranspInd is called %d times", c)
+	}
+	waitIdle(t, ms)
+	waitIdle(t, sc)
+}
+
+func TestSMCTC1MExpiry(t *testing.T) {
+	ms := &sms.SMC{TC1M: time.Millisecond * 10}
+	var count int32
+	ms.CtrlReq = func(p sms.CPDU) {
+		atomic.AddInt32(&count, 1)
+	}
+
+	if _, e := ms.TranspReq(randSubmit()); !errors.Is(e, sms.ErrTC1MExpired) {
+		t.Errorf("unexpected error %v", e)
+	}
+	if c := atomic.LoadInt32(&count); c != 3 {
+		t.Errorf("CP-DATA is sent %d times", c)
+	}
+
+	count = 0
+	ms.MaxRetrans = -1
+	if _, e := ms.TranspReq(randSubmit()); !errors.Is(e, sms.ErrTC1MExpired) {
+		t.Errorf("unexpected error %v", e)
+	}
+	if c := atomic.LoadInt32(&count); c != 1 {
+		t.Errorf("CP-DATA is sent %d times", c)
+	}
+	waitIdle(t, ms)
+}
+
+func TestSMCError(t *testing.T) {
+	ms := &sms.SMC{}
+	sent := make(chan sms.CPDU, 10)
+	ms.CtrlReq = func(p sms.CPDU) {
+		sent <- p
+	}
+
+	// CP-ERROR from peer release the transaction
+	go func() {
+		p := (<-sent).(sms.Submit)
+		if ms.State(p.TI) != sms.CMWaitForCPAck {
+			t.Errorf("unexpected state %s", ms.State(p.TI))
+		}
+		ms.CtrlInd(sms.CpAck{TI: p.TI | 0x08})
+		if ms.State(p.TI) != sms.CMConnectionEstablished {
+			t.Errorf("unexpected state %s", ms.State(p.TI))
+		}
+		ms.CtrlInd(sms.CpError{TI: p.TI | 0x08, CS: 17})
+	}()
+	_, e := ms.TranspReq(randSubmit())
+	if ce, ok := e.(sms.CpError); !ok || ce.CS != 17 {
+		t.Errorf("unexpected error %v", e)
+	}
+	waitIdle(t, ms)
+
+	// CP-DATA for unknown transaction
+	r := sms.RpAckMT{}
+	r.TI = 0x08
+	ms.CtrlInd(r)
+	if p, ok := (<-sent).(sms.CpError); !ok {
+		t.Errorf("unexpected CPDU %v", p)
+	} else if p.CS != 81 || p.TI != 0x00 {
+		t.Errorf("unexpected CP-ERROR %v", p)
+	}
+	r.TI = 0x0b
+	ms.CtrlInd(r)
+	if p, ok := (<-sent).(sms.CpError); !ok {
+		t.Errorf("unexpected CPDU %v", p)
+	} else if p.CS != 81 || p.TI != 0x03 {
+		t.Errorf("unexpected CP-ERROR %v", p)
+	}
+
+	// CP-ACK and CP-ERROR for unknown transaction are ignored
+	ms.CtrlInd(sms.CpAck{TI: 0x09})
+	ms.CtrlInd(sms.CpError{TI: 0x02, CS: 17})
+	select {
+	case p := <-sent:
+		t.Errorf("unexpected CPDU %v", p)
+	case <-time.After(time.Millisecond * 10):
+	}
+}
+
+func TestSMCContext(t *testing.T) {
+	ms := &sms.SMC{}
+	sent := make(chan sms.CPDU, 10)
+	ms.CtrlReq = func(p sms.CPDU) {
+		sent <- p
+	}
+
+	// cancel aborts the transaction with CP-ERROR
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		p := (<-sent).(sms.Submit)
+		if ms.State(p.TI) != sms.CMWaitForCPAck {
+			t.Errorf("unexpected state %s", ms.State(p.TI))
+		}
+		cancel()
+	}()
+	if _, e := ms.TranspReqContext(ctx, randSubmit()); !errors.Is(e, context.Canceled) {
+		t.Errorf("unexpected error %v", e)
+	}
+	if p, ok := (<-sent).(sms.CpError); !ok {
+		t.Errorf("unexpected CPDU %v", p)
+	} else if p.CS != 111 || p.TI&0x08 != 0 {
+		t.Errorf("unexpected CP-ERROR %v", p)
+	} else if ms.State(p.TI) != sms.CMIdle {
+		t.Errorf("unexpected state %s", ms.State(p.TI))
+	}
+
+	// CP-ERROR from peer cancels the indication
+	canceled := make(chan struct{})
+	ms.TranspIndContext = func(ctx context.Context, p sms.TPDU) (sms.TPDU, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+	d := randDeliver()
+	d.TI = 0x03
+	ms.CtrlInd(d)
+	if p, ok := (<-sent).(sms.CpAck); !ok || p.TI != 0x0b {
+		t.Errorf("unexpected CPDU %v", p)
+	}
+	ms.CtrlInd(sms.CpError{TI: 0x03, CS: 17})
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("indication is not canceled")
+	}
+	select {
+	case p := <-sent:
+		t.Errorf("unexpected CPDU %v", p)
+	case <-time.After(time.Millisecond * 10):
+	}
+	waitIdle(t, ms)
+}
+
+func TestSMCMemoryAvailable(t *testing.T) {
+	ms := newSMC()
+	sc := newSMC()
+	connectSMC(t, ms, sc, nil)
+
+	if e := ms.MemAvailReq(); e == nil {
+		t.Errorf("unexpected success")
+	} else if re, ok := e.(sms.RpError); !ok || re.CS != 97 {
+		t.Errorf("unexpected error %v", e)
+	}
+
+	sc.MemAvailInd = func() error {
+		return nil
+	}
+	if e := ms.MemAvailReq(); e != nil {
+		t.Errorf("unexpected error %v", e)
+	}
+	waitIdle(t, ms)
+	waitIdle(t, sc)
+}
+
+func TestSMCMemoryAvailableContext(t *testing.T) {
+	ms := &sms.SMC{}
+	sent := make(chan sms.CPDU, 10)
+	ms.CtrlReq = func(p sms.CPDU) {
+		sent <- p
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		p := (<-sent).(sms.MemoryAvailable)
+		if ms.State(p.TI) != sms.CMWaitForCPAck {
+			t.Errorf("unexpected state %s", ms.State(p.TI))
+		}
+		cancel()
+	}()
+	if e := ms.MemAvailReqContext(ctx); !errors.Is(e, context.Canceled) {
+		t.Errorf("unexpected error %v", e)
+	}
+	if p, ok := (<-sent).(sms.CpError); !ok {
+		t.Errorf("unexpected CPDU %v", p)
+	} else if p.CS != 111 || ms.State(p.TI) != sms.CMIdle {
+		t.Errorf("unexpected CP-ERROR %v", p)
+	}
+	waitIdle(t, ms)
+}
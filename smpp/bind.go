@@ -0,0 +1,172 @@
+package smpp
+
+import "fmt"
+
+// InterfaceVersion is interface_version of SMPP 3.4
+const InterfaceVersion byte = 0x34
+
+// BindType is type of bind operation
+type BindType byte
+
+const (
+	// Transmitter is bind_transmitter
+	Transmitter BindType = 1
+	// Receiver is bind_receiver
+	Receiver BindType = 2
+	// Transceiver is bind_transceiver
+	Transceiver BindType = 3
+)
+
+func bindType(c CommandID) BindType {
+	switch c {
+	case BindTransmitterID:
+		return Transmitter
+	case BindReceiverID:
+		return Receiver
+	case BindTransceiverID:
+		return Transceiver
+	}
+	return 0
+}
+
+func (t BindType) commandID() CommandID {
+	switch t {
+	case Transmitter:
+		return BindTransmitterID
+	case Receiver:
+		return BindReceiverID
+	}
+	return BindTransceiverID
+}
+
+func (t BindType) String() string {
+	switch t {
+	case Transmitter:
+		return "transmitter"
+	case Receiver:
+		return "receiver"
+	case Transceiver:
+		return "transceiver"
+	}
+	return fmt.Sprintf("unknown(%d)", byte(t))
+}
+
+// CanSend reports ESME that is bound with t can send message
+func (t BindType) CanSend() bool {
+	return t == Transmitter || t == Transceiver
+}
+
+// CanReceive reports ESME that is bound with t can receive message
+func (t BindType) CanReceive() bool {
+	return t == Receiver || t == Transceiver
+}
+
+// Bind is bind_transmitter, bind_receiver or bind_transceiver
+type Bind struct {
+	Type         BindType
+	SystemID     string
+	Password     string
+	SystemType   string
+	Version      byte // interface_version
+	AddrTON      byte
+	AddrNPI      byte
+	AddressRange string
+}
+
+// CommandID returns command_id of this PDU
+func (p Bind) CommandID() CommandID {
+	return p.Type.commandID()
+}
+
+func (p Bind) appendBody(w *writer) {
+	w.cstring(p.SystemID, 16)
+	w.cstring(p.Password, 9)
+	w.cstring(p.SystemType, 13)
+	w.bytes(p.Version, p.AddrTON, p.AddrNPI)
+	w.cstring(p.AddressRange, 41)
+}
+
+func (p *Bind) unmarshalBody(r *reader) error {
+	return r.fields([]int{16, 9, 13, 41},
+		&p.SystemID, &p.Password, &p.SystemType,
+		&p.Version, &p.AddrTON, &p.AddrNPI, &p.AddressRange)
+}
+
+func (p Bind) String() string {
+	return fmt.Sprintf("bind_%s system_id=%q system_type=%q version=%x",
+		p.Type, p.SystemID, p.SystemType, p.Version)
+}
+
+// BindResp is response of Bind
+type BindResp struct {
+	Type     BindType
+	SystemID string
+	TLVs     TLVs
+}
+
+// CommandID returns command_id of this PDU
+func (p BindResp) CommandID() CommandID {
+	return p.Type.commandID() | 0x80000000
+}
+
+func (p BindResp) appendBody(w *writer) {
+	w.cstring(p.SystemID, 16)
+	w.tlvs(p.TLVs)
+}
+
+func (p *BindResp) unmarshalBody(r *reader) (e error) {
+	if p.SystemID, e = r.cstring(16); e == nil {
+		p.TLVs, e = readTLVs(r)
+	}
+	return
+}
+
+// Unbind is unbind
+type Unbind struct{}
+
+// CommandID returns command_id of this PDU
+func (Unbind) CommandID() CommandID { return UnbindID }
+
+func (Unbind) appendBody(*writer) {}
+
+func (*Unbind) unmarshalBody(*reader) error { return nil }
+
+// UnbindResp is response of Unbind
+type UnbindResp struct{}
+
+// CommandID returns command_id of this PDU
+func (UnbindResp) CommandID() CommandID { return UnbindRespID }
+
+func (UnbindResp) appendBody(*writer) {}
+
+func (*UnbindResp) unmarshalBody(*reader) error { return nil }
+
+// EnquireLink is enquire_link
+type EnquireLink struct{}
+
+// CommandID returns command_id of this PDU
+func (EnquireLink) CommandID() CommandID { return EnquireLinkID }
+
+func (EnquireLink) appendBody(*writer) {}
+
+func (*EnquireLink) unmarshalBody(*reader) error { return nil }
+
+// EnquireLinkResp is response of EnquireLink
+type EnquireLinkResp struct{}
+
+// CommandID returns command_id of this PDU
+func (EnquireLinkResp) CommandID() CommandID { return EnquireLinkRespID }
+
+func (EnquireLinkResp) appendBody(*writer) {}
+
+func (*EnquireLinkResp) unmarshalBody(*reader) error { return nil }
+
+// GenericNack is generic_nack
+type GenericNack struct{}
+
+// CommandID returns command_id of this PDU
+func (GenericNack) CommandID() CommandID { return GenericNackID }
+
+func (GenericNack) appendBody(*writer) {}
+
+func (*GenericNack) unmarshalBody(*reader) error { return nil }
@@ -0,0 +1,401 @@
+package smpp
+
+import (
+	"time"
+	"unicode/utf16"
+
+	"github.com/fkgi/sms"
+	"github.com/fkgi/teldata"
+)
+
+// ToSubmit make Submit TPDUs from submit_sm.
+// Long message_payload is segmented with concatenation reference
+// allocated by a, or by temporary allocator if a is nil.
+// TP-MR is user_message_reference only if the message is not segmented.
+func (p SubmitSM) ToSubmit(a *sms.RefAllocator) (s []sms.Submit, e error) {
+	da, e := toAddress(p.Dest)
+	if e != nil {
+		return
+	}
+	vp, e := toValidityPeriod(p.ValidityPeriod, time.Now())
+	if e != nil {
+		return
+	}
+	dcs, ud, e := p.userData(a)
+	if e != nil {
+		return
+	}
+
+	for _, u := range ud {
+		s = append(s, sms.Submit{
+			RP:  p.EsmClass&EsmReplyPath == EsmReplyPath,
+			SRR: p.RegisteredDelivery&0x03 != 0,
+			DA:  da,
+			PID: p.ProtocolID,
+			DCS: dcs,
+			VP:  vp,
+			UD:  u})
+	}
+	if r, ok := p.TLVs.Uint(TagUserMessageReference); ok && len(s) == 1 {
+		s[0].TMR = byte(r)
+	}
+	return
+}
+
+// ToDeliver make Deliver TPDUs from deliver_sm.
+// Long message_payload is segmented with concatenation reference
+// allocated by a, or by temporary allocator if a is nil.
+// TP-SCTS is current time.
+func (p DeliverSM) ToDeliver(a *sms.RefAllocator) (d []sms.Deliver, e error) {
+	oa, e := toAddress(p.Source)
+	if e != nil {
+		return
+	}
+	dcs, ud, e := p.userData(a)
+	if e != nil {
+		return
+	}
+
+	mms := false
+	if v, ok := p.TLVs.Uint(TagMoreMessagesToSend); ok {
+		mms = v == 1
+	}
+	ts, e := sms.TimeToSCTimeStamp(time.Now())
+	if e != nil {
+		if ts, e = sms.TimeToSCTimeStamp(time.Now().UTC()); e != nil {
+			return
+		}
+	}
+	for i, u := range ud {
+		d = append(d, sms.Deliver{
+			MMS:  mms || i != len(ud)-1,
+			RP:   p.EsmClass&EsmReplyPath == EsmReplyPath,
+			OA:   oa,
+			PID:  p.ProtocolID,
+			DCS:  dcs,
+			SCTS: ts,
+			UD:   u})
+	}
+	return
+}
+
+// NewSubmitSM make submit_sm from Submit TPDU.
+// TP-MR is set to user_message_reference.
+func NewSubmitSM(s sms.Submit) (p SubmitSM, e error) {
+	p.Dest = fromAddress(s.DA)
+	p.ProtocolID = s.PID
+	if s.RP {
+		p.EsmClass |= EsmReplyPath
+	}
+	if s.SRR {
+		p.RegisteredDelivery = RegisteredDeliveryReceipt
+	}
+	if p.ValidityPeriod, e = fromValidityPeriod(s.VP); e != nil {
+		return
+	}
+	if e = p.setUserData(s.DCS, s.UD); e != nil {
+		return
+	}
+	p.TLVs = p.TLVs.SetUint16(TagUserMessageReference, uint16(s.TMR))
+	return
+}
+
+// NewDeliverSM make deliver_sm from Deliver TPDU
+func NewDeliverSM(d sms.Deliver) (p DeliverSM, e error) {
+	p.Source = fromAddress(d.OA)
+	p.ProtocolID = d.PID
+	if d.RP {
+		p.EsmClass |= EsmReplyPath
+	}
+	if e = p.setUserData(d.DCS, d.UD); e != nil {
+		return
+	}
+	if d.MMS {
+		p.TLVs = p.TLVs.SetUint8(TagMoreMessagesToSend, 1)
+	}
+	return
+}
+
+// userData make DCS and segmented TP-UD from
+// data_coding, esm_class, short_message and TLVs
+func (p Message) userData(a *sms.RefAllocator) (
+	dcs sms.DataCoding, ud []sms.UserData, e error) {
+	b := p.ShortMessage
+	if v, ok := p.TLVs.Get(TagMessagePayload); ok {
+		if len(b) != 0 {
+			e = ErrDuplicatePayload
+			return
+		}
+		b = v
+	}
+
+	var h []sms.UserDataHdr
+	if p.EsmClass&EsmUDHI == EsmUDHI {
+		if len(b) == 0 || int(b[0]) >= len(b) {
+			e = ErrInvalidLength
+			return
+		}
+		h = sms.UnmarshalUDHs(b[:b[0]+1])
+		b = b[b[0]+1:]
+	}
+	if ref, ok := p.TLVs.Uint(TagSARMsgRefNum); ok {
+		max, _ := p.TLVs.Uint(TagSARTotalSegments)
+		seq, _ := p.TLVs.Uint(TagSARSegmentSeqnum)
+		if ref > 0xff {
+			h = append(h, sms.ConcatenatedSM16bit{
+				RefNum: uint16(ref), MaxNum: byte(max), SeqNum: byte(seq)})
+		} else {
+			h = append(h, sms.ConcatenatedSM{
+				RefNum: byte(ref), MaxNum: byte(max), SeqNum: byte(seq)})
+		}
+	}
+
+	if dcs, e = toDataCoding(p.DataCoding); e != nil {
+		return
+	}
+	u := sms.UserData{UDH: h}
+	switch dcs.Charset() {
+	case sms.Charset8bitData:
+		u.Set8bitData(b)
+	case sms.CharsetUCS2:
+		if len(b)%2 != 0 {
+			e = ErrInvalidLength
+			return
+		}
+		s := make([]uint16, len(b)/2)
+		for i := range s {
+			s[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		}
+		u.Text = string(utf16.Decode(s))
+	default:
+		switch p.DataCoding {
+		case 0x01:
+			u.Text = string(b)
+		case 0x03:
+			r := make([]rune, len(b))
+			for i, c := range b {
+				r[i] = rune(c)
+			}
+			u.Text = string(r)
+		default:
+			u.Text = sms.UnmarshalUnpackedGSM7bitString(b).String()
+		}
+		if _, ge := sms.StringToGSM7bit(u.Text); ge != nil {
+			// coding group other than general can't be changed to UCS2
+			c, ok := dcs.(sms.GeneralDataCoding)
+			if !ok {
+				e = UnknownDataCodingError{DataCoding: p.DataCoding}
+				return
+			}
+			c.MsgCharset = sms.CharsetUCS2
+			dcs = c
+		}
+	}
+
+	if udLength(u, dcs.Charset()) <= 140 {
+		ud = []sms.UserData{u}
+		return
+	}
+	if len(h) != 0 {
+		e = ErrUDHSegmentation
+		return
+	}
+	if a == nil {
+		if a, e = sms.NewConcatRefAllocator(nil, ""); e != nil {
+			return
+		}
+	}
+	if dcs.Charset() == sms.Charset8bitData {
+		ud, _, e = sms.MakeSeparatedDataRef(b, a)
+		return
+	}
+
+	var cs sms.Charset
+	if ud, cs, _, e = sms.MakeSeparatedTextRef(u.Text, a); e != nil {
+		return
+	}
+	if cs != dcs.Charset() {
+		c, ok := dcs.(sms.GeneralDataCoding)
+		if !ok {
+			e = UnknownDataCodingError{DataCoding: p.DataCoding}
+			return
+		}
+		c.MsgCharset = cs
+		dcs = c
+	}
+	return
+}
+
+// udLength returns octets of TP-UD
+func udLength(u sms.UserData, c sms.Charset) int {
+	h := len(sms.MarshalUDHs(u.UDH))
+	switch c {
+	case sms.Charset8bitData:
+		d, _ := u.Get8bitData()
+		return h + len(d)
+	case sms.CharsetUCS2:
+		return h + len(utf16.Encode([]rune(u.Text)))*2
+	}
+	s, _ := sms.StringToGSM7bit(u.Text)
+	l := (h*8+6)/7 + s.Length()
+	return (l*7 + 7) / 8
+}
+
+// setUserData set data_coding, esm_class and short_message
+// from DCS and TP-UD
+func (p *Message) setUserData(dcs sms.DataCoding, u sms.UserData) (e error) {
+	if p.DataCoding, e = fromDataCoding(dcs); e != nil {
+		return
+	}
+	if len(u.UDH) != 0 {
+		p.EsmClass |= EsmUDHI
+		p.ShortMessage = sms.MarshalUDHs(u.UDH)
+	}
+
+	c := sms.CharsetGSM7bit
+	if dcs != nil {
+		c = dcs.Charset()
+	}
+	switch c {
+	case sms.Charset8bitData:
+		var d []byte
+		if d, e = u.Get8bitData(); e != nil {
+			return
+		}
+		p.ShortMessage = append(p.ShortMessage, d...)
+	case sms.CharsetUCS2:
+		for _, r := range utf16.Encode([]rune(u.Text)) {
+			p.ShortMessage = append(p.ShortMessage, byte(r>>8), byte(r))
+		}
+	default:
+		var s sms.GSM7bitString
+		if s, e = sms.StringToGSM7bit(u.Text); e != nil {
+			return
+		}
+		p.ShortMessage = append(p.ShortMessage, s.Unpacked()...)
+	}
+	if len(p.ShortMessage) > 254 {
+		p.TLVs = p.TLVs.Set(TagMessagePayload, p.ShortMessage)
+		p.ShortMessage = nil
+	}
+	return
+}
+
+// toDataCoding make DCS from data_coding.
+// SMSC default alphabet is GSM 7bit default alphabet,
+// and IA5 or Latin 1 is GSM 7bit or UCS2 by the text.
+func toDataCoding(b byte) (sms.DataCoding, error) {
+	switch b {
+	case 0x00, 0x01, 0x03:
+		return sms.GeneralDataCoding{MsgCharset: sms.CharsetGSM7bit}, nil
+	case 0x02, 0x04:
+		return sms.GeneralDataCoding{MsgCharset: sms.Charset8bitData}, nil
+	case 0x08:
+		return sms.GeneralDataCoding{MsgCharset: sms.CharsetUCS2}, nil
+	}
+	if b >= 0xc0 {
+		if c := sms.UnmarshalDataCoding(b); c != nil {
+			return c, nil
+		}
+	}
+	return nil, UnknownDataCodingError{DataCoding: b}
+}
+
+// fromDataCoding make data_coding from DCS
+func fromDataCoding(c sms.DataCoding) (byte, error) {
+	switch v := c.(type) {
+	case nil:
+		return 0x00, nil
+	case sms.GeneralDataCoding:
+		if v.AutoDelete || v.Compressed {
+			break
+		}
+		if v.MsgClass == sms.NoMessageClass {
+			switch v.MsgCharset {
+			case sms.CharsetGSM7bit:
+				return 0x00, nil
+			case sms.Charset8bitData:
+				return 0x04, nil
+			case sms.CharsetUCS2:
+				return 0x08, nil
+			}
+		} else if v.MsgCharset != sms.CharsetUCS2 {
+			return sms.DataCodingMessage{
+				IsData:   v.MsgCharset == sms.Charset8bitData,
+				MsgClass: v.MsgClass}.Marshal(), nil
+		}
+	default:
+		if b := c.Marshal(); b >= 0xc0 {
+			return b, nil
+		}
+	}
+	return 0, UnknownDataCodingError{DataCoding: c.Marshal()}
+}
+
+// toAddress make SMS address from SMPP address
+func toAddress(a Address) (r sms.Address, e error) {
+	r.TON, r.NPI = a.TON, a.NPI
+	switch {
+	case len(a.Addr) == 0:
+	case a.TON == sms.TypeAlphanumeric:
+		r.Addr, e = sms.StringToGSM7bit(a.Addr)
+	default:
+		var t teldata.TBCD
+		if t, e = teldata.ParseTBCD(a.Addr); e != nil {
+			e = sms.InvalidAddressError{Addr: a.Addr}
+		}
+		r.Addr = t
+	}
+	return
+}
+
+// fromAddress make SMPP address from SMS address
+func fromAddress(a sms.Address) (r Address) {
+	r.TON, r.NPI = a.TON, a.NPI
+	if a.Addr != nil {
+		r.Addr = a.Addr.String()
+	}
+	return
+}
+
+// toValidityPeriod make TP-VP from validity_period.
+// Relative time is relative format if it is representable,
+// and absolute format otherwise.
+func toValidityPeriod(s string, ref time.Time) (sms.ValidityPeriod, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, rel, e := ParseTime(s, ref)
+	if e != nil {
+		return nil, e
+	}
+	if rel {
+		if vp, e := sms.MakeValidityPeriod(
+			sms.VPFormatRelative, t.Sub(ref), false, ref); e == nil {
+			return vp, nil
+		}
+	}
+	ts, e := sms.TimeToSCTimeStamp(t)
+	if e != nil {
+		return nil, e
+	}
+	return sms.VPAbsolute(ts), nil
+}
+
+// fromValidityPeriod make validity_period from TP-VP
+func fromValidityPeriod(vp sms.ValidityPeriod) (string, error) {
+	switch v := vp.(type) {
+	case nil:
+		return "", nil
+	case sms.VPAbsolute:
+		t, e := sms.SCTimeStamp(v).Time()
+		if e != nil {
+			return "", e
+		}
+		return FormatTime(t), nil
+	}
+	if d := vp.Duration(); d != 0 {
+		return FormatRelativeTime(d), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,330 @@
+package smpp_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+	"github.com/fkgi/sms/smpp"
+)
+
+// transfer marshal and unmarshal p
+func transfer(t *testing.T, p smpp.PDU) smpp.PDU {
+	b := marshal(t, p, 1)
+	t.Logf("% x", b)
+	r, _, e := smpp.Unmarshal(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return r
+}
+
+func TestConvertSubmit(t *testing.T) {
+	da, _ := sms.ParseAddress("+819012345678")
+	alpha, _ := sms.StringToGSM7bit("Alpha")
+	ud8 := sms.UserData{UDH: []sms.UserDataHdr{sms.ConcatenatedSM{
+		RefNum: 3, MaxNum: 2, SeqNum: 1}}}
+	ud8.Set8bitData([]byte{0x00, 0xff, 0x10})
+
+	for _, orig := range []sms.Submit{
+		{TMR: 5, SRR: true, RP: true, DA: da, PID: 0x41,
+			DCS: sms.GeneralDataCoding{MsgCharset: sms.CharsetGSM7bit},
+			VP:  sms.VPRelative(167),
+			UD:  sms.UserData{Text: "Hello {world} €"}},
+		{TMR: 6, DA: sms.Address{TON: sms.TypeAlphanumeric, Addr: alpha},
+			DCS: sms.GeneralDataCoding{MsgCharset: sms.CharsetUCS2},
+			UD:  sms.UserData{Text: "こんにちは😀"}},
+		{TMR: 7, DA: da,
+			DCS: sms.GeneralDataCoding{MsgCharset: sms.Charset8bitData},
+			UD:  ud8},
+		{TMR: 8, DA: da,
+			DCS: sms.DataCodingMessage{MsgClass: sms.MessageClass0},
+			UD:  sms.UserData{Text: "flash"}},
+	} {
+		p, e := smpp.NewSubmitSM(orig)
+		if e != nil {
+			t.Fatal(e)
+		}
+		t.Log(p)
+		if orig.SRR != (p.RegisteredDelivery == smpp.RegisteredDeliveryReceipt) {
+			t.Fatalf("registered_delivery mismatch %x", p.RegisteredDelivery)
+		}
+		if (len(orig.UD.UDH) != 0) != (p.EsmClass&smpp.EsmUDHI != 0) {
+			t.Fatalf("esm_class mismatch %x", p.EsmClass)
+		}
+
+		s, e := transfer(t, p).(smpp.SubmitSM).ToSubmit(nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if len(s) != 1 {
+			t.Fatalf("unexpected %d segments", len(s))
+		}
+		ocom := s[0]
+		t.Log(ocom)
+		if ocom.TMR != orig.TMR || ocom.SRR != orig.SRR || ocom.RP != orig.RP ||
+			ocom.PID != orig.PID {
+			t.Fatal("flag mismatch")
+		}
+		if !ocom.DA.Equal(orig.DA) {
+			t.Fatalf("DA mismatch %s", ocom.DA)
+		}
+		if !ocom.DCS.Equal(orig.DCS) {
+			t.Fatalf("DCS mismatch %s", ocom.DCS)
+		}
+		if !ocom.UD.Equal(orig.UD) {
+			t.Fatalf("UD mismatch %s", ocom.UD)
+		}
+		if (orig.VP == nil) != (ocom.VP == nil) ||
+			orig.VP != nil && !orig.VP.Equal(ocom.VP) {
+			t.Fatalf("VP mismatch %s", ocom.VP)
+		}
+	}
+}
+
+func TestConvertDeliver(t *testing.T) {
+	oa, _ := sms.ParseAddress("+819012345678")
+	orig := sms.Deliver{MMS: true, OA: oa, PID: 0x00,
+		DCS: sms.GeneralDataCoding{MsgCharset: sms.CharsetGSM7bit},
+		UD:  sms.UserData{Text: "deliver [test]"}}
+	p, e := smpp.NewDeliverSM(orig)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if v, ok := p.TLVs.Uint(smpp.TagMoreMessagesToSend); !ok || v != 1 {
+		t.Fatal("more_messages_to_send mismatch")
+	}
+
+	d, e := transfer(t, p).(smpp.DeliverSM).ToDeliver(nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(d) != 1 || !d[0].MMS || !d[0].OA.Equal(oa) ||
+		!d[0].UD.Equal(orig.UD) || d[0].SCTS.IsZero() {
+		t.Fatalf("unexpected %v", d)
+	}
+}
+
+func TestConvertDataCoding(t *testing.T) {
+	for _, c := range []struct {
+		dc   byte
+		sm   []byte
+		dcs  sms.DataCoding
+		text string
+	}{
+		{0x00, []byte{0x48, 0x1b, 0x65, 0x00}, sms.GeneralDataCoding{
+			MsgCharset: sms.CharsetGSM7bit}, "H€@"},
+		{0x01, []byte("abc"), sms.GeneralDataCoding{
+			MsgCharset: sms.CharsetGSM7bit}, "abc"},
+		{0x03, []byte{0x41, 0xe9}, sms.GeneralDataCoding{
+			MsgCharset: sms.CharsetGSM7bit}, "Aé"},
+		{0x03, []byte{0x41, 0xa9}, sms.GeneralDataCoding{
+			MsgCharset: sms.CharsetUCS2}, "A©"},
+		{0x02, []byte{0x01, 0x02}, sms.GeneralDataCoding{
+			MsgCharset: sms.Charset8bitData}, "AQI="},
+		{0x08, []byte{0x30, 0x42}, sms.GeneralDataCoding{
+			MsgCharset: sms.CharsetUCS2}, "あ"},
+		{0xf1, []byte("cls1"), sms.DataCodingMessage{
+			MsgClass: sms.MessageClass1}, "cls1"},
+	} {
+		p := smpp.SubmitSM{}
+		p.Dest.Addr = "1234"
+		p.DataCoding = c.dc
+		p.ShortMessage = c.sm
+		s, e := p.ToSubmit(nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if !s[0].DCS.Equal(c.dcs) || s[0].UD.Text != c.text {
+			t.Fatalf("data_coding %x: unexpected %s %q",
+				c.dc, s[0].DCS, s[0].UD.Text)
+		}
+	}
+
+	p := smpp.SubmitSM{}
+	p.DataCoding = 0x05
+	var dce smpp.UnknownDataCodingError
+	if _, e := p.ToSubmit(nil); !errors.As(e, &dce) || dce.DataCoding != 0x05 {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// message waiting group with unknown extension character
+	p.DataCoding = 0xd0
+	p.ShortMessage = []byte{0x1b, 0x1b}
+	if _, e := p.ToSubmit(nil); !errors.As(e, &dce) || dce.DataCoding != 0xd0 {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// UCS2 with odd length
+	p.DataCoding = 0x08
+	p.ShortMessage = []byte{0x30, 0x42, 0x30}
+	if _, e := p.ToSubmit(nil); !errors.Is(e, smpp.ErrInvalidLength) {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	for _, c := range []struct {
+		dcs sms.DataCoding
+		dc  byte
+	}{
+		{nil, 0x00},
+		{sms.GeneralDataCoding{MsgCharset: sms.Charset8bitData}, 0x04},
+		{sms.GeneralDataCoding{MsgCharset: sms.CharsetUCS2}, 0x08},
+		{sms.GeneralDataCoding{
+			MsgClass: sms.MessageClass2, MsgCharset: sms.Charset8bitData}, 0xf6},
+		{sms.MessageWaiting{Behavior: sms.StoreMessageGSM7bit, Active: true}, 0xd8},
+	} {
+		p, e := smpp.NewSubmitSM(sms.Submit{DCS: c.dcs})
+		if e != nil {
+			t.Fatal(e)
+		}
+		if p.DataCoding != c.dc {
+			t.Fatalf("%v: unexpected data_coding %x", c.dcs, p.DataCoding)
+		}
+	}
+	if _, e := smpp.NewSubmitSM(sms.Submit{DCS: sms.GeneralDataCoding{
+		MsgClass: sms.MessageClass1, MsgCharset: sms.CharsetUCS2}}); e == nil {
+		t.Fatal("UCS2 with message class must fail")
+	}
+}
+
+func TestConvertPayload(t *testing.T) {
+	a, _ := sms.NewConcatRef16Allocator(nil, "")
+	for _, c := range []struct {
+		dc      byte
+		payload []byte
+		n       int
+	}{
+		{0x00, bytes.Repeat([]byte("a"), 400), 3},
+		{0x00, bytes.Repeat([]byte("a"), 160), 1},
+		{0x04, bytes.Repeat([]byte{0xaa}, 300), 3},
+		{0x08, bytes.Repeat([]byte{0x30, 0x42}, 100), 2},
+	} {
+		p := smpp.DeliverSM{}
+		p.Source.Addr = "1234"
+		p.DataCoding = c.dc
+		p.TLVs = p.TLVs.Set(smpp.TagMessagePayload, c.payload)
+
+		d, e := transfer(t, p).(smpp.DeliverSM).ToDeliver(a)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if len(d) != c.n {
+			t.Fatalf("data_coding %x: unexpected %d segments", c.dc, len(d))
+		}
+
+		var buf []byte
+		for i, s := range d {
+			if s.MMS != (i != len(d)-1) {
+				t.Fatalf("MMS mismatch at %d", i)
+			}
+			if len(d) > 1 {
+				h, ok := s.UD.UDH[0].(sms.ConcatenatedSM16bit)
+				if !ok || h.MaxNum != byte(len(d)) || h.SeqNum != byte(i+1) {
+					t.Fatalf("unexpected UDH %v", s.UD.UDH)
+				}
+			}
+			// segment must be encodable without truncation
+			r, e := sms.UnmarshalTPMT(s.MarshalTP())
+			if e != nil {
+				t.Fatal(e)
+			}
+			u := r.(sms.Deliver).UD
+			if !u.Equal(s.UD) {
+				t.Fatalf("segment %d is truncated", i)
+			}
+
+			p, e := smpp.NewDeliverSM(s)
+			if e != nil {
+				t.Fatal(e)
+			}
+			b := p.ShortMessage
+			if len(d) > 1 {
+				b = b[b[0]+1:]
+			}
+			buf = append(buf, b...)
+		}
+		if !bytes.Equal(buf, c.payload) {
+			t.Fatalf("data_coding %x: payload mismatch", c.dc)
+		}
+	}
+
+	p := smpp.SubmitSM{}
+	p.ShortMessage = []byte("a")
+	p.TLVs = p.TLVs.Set(smpp.TagMessagePayload, []byte("b"))
+	if _, e := p.ToSubmit(nil); e != smpp.ErrDuplicatePayload {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// more than 255 segments
+	p = smpp.SubmitSM{}
+	p.DataCoding = 0x04
+	p.TLVs = p.TLVs.Set(smpp.TagMessagePayload, make([]byte, 40000))
+	if _, e := p.ToSubmit(a); !errors.Is(e, sms.ErrTooManySegments) {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	p = smpp.SubmitSM{}
+	p.EsmClass = smpp.EsmUDHI
+	p.TLVs = p.TLVs.Set(smpp.TagMessagePayload, append(
+		[]byte{0x05, 0x00, 0x03, 0x01, 0x02, 0x01},
+		strings.Repeat("a", 160)...))
+	if _, e := p.ToSubmit(nil); e != smpp.ErrUDHSegmentation {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
+
+func TestConvertSAR(t *testing.T) {
+	p := smpp.SubmitSM{}
+	p.ShortMessage = []byte("part")
+	p.TLVs = p.TLVs.
+		SetUint16(smpp.TagSARMsgRefNum, 0x1234).
+		SetUint8(smpp.TagSARTotalSegments, 3).
+		SetUint8(smpp.TagSARSegmentSeqnum, 2)
+	s, e := p.ToSubmit(nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(s) != 1 || len(s[0].UD.UDH) != 1 || !s[0].UD.UDH[0].Equal(
+		sms.ConcatenatedSM16bit{RefNum: 0x1234, MaxNum: 3, SeqNum: 2}) {
+		t.Fatalf("unexpected %v", s)
+	}
+}
+
+func TestTime(t *testing.T) {
+	ref := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		s   string
+		t   time.Time
+		rel bool
+	}{
+		{"240102030405636+", time.Date(2024, 1, 2, 3, 4, 5, 6e8,
+			time.FixedZone("", 9*3600)), false},
+		{"991231235959012-", time.Date(2099, 12, 31, 23, 59, 59, 0,
+			time.FixedZone("", -3*3600)), false},
+		{"000001020304000R", ref.Add(26*time.Hour + 3*time.Minute + 4*time.Second), true},
+		{"010100000000000R", time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC), true},
+	} {
+		r, rel, e := smpp.ParseTime(c.s, ref)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if !r.Equal(c.t) || rel != c.rel {
+			t.Fatalf("%s: unexpected %s", c.s, r)
+		}
+		if !rel && smpp.FormatTime(r) != c.s {
+			t.Fatalf("%s: unexpected format %s", c.s, smpp.FormatTime(r))
+		}
+	}
+	if s := smpp.FormatRelativeTime(26*time.Hour + 3*time.Minute + 4*time.Second); s != "000001020304000R" {
+		t.Fatalf("unexpected format %s", s)
+	}
+	for _, s := range []string{"", "2401020304056", "241302030405600+",
+		"240102030405600X", "2401020304056a0+"} {
+		if _, _, e := smpp.ParseTime(s, ref); e == nil {
+			t.Fatalf("%q must fail", s)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package smpp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status is command_status of SMPP PDU
+type Status uint32
+
+const (
+	// StatusOK is ESME_ROK, no error
+	StatusOK Status = 0x00000000
+	// StatusInvMsgLen is ESME_RINVMSGLEN, message length is invalid
+	StatusInvMsgLen Status = 0x00000001
+	// StatusInvCmdLen is ESME_RINVCMDLEN, command length is invalid
+	StatusInvCmdLen Status = 0x00000002
+	// StatusInvCmdID is ESME_RINVCMDID, invalid command ID
+	StatusInvCmdID Status = 0x00000003
+	// StatusInvBndSts is ESME_RINVBNDSTS, incorrect BIND status for given command
+	StatusInvBndSts Status = 0x00000004
+	// StatusAlyBnd is ESME_RALYBND, ESME already in bound state
+	StatusAlyBnd Status = 0x00000005
+	// StatusSysErr is ESME_RSYSERR, system error
+	StatusSysErr Status = 0x00000008
+	// StatusInvSrcAdr is ESME_RINVSRCADR, invalid source address
+	StatusInvSrcAdr Status = 0x0000000A
+	// StatusInvDstAdr is ESME_RINVDSTADR, invalid dest addr
+	StatusInvDstAdr Status = 0x0000000B
+	// StatusInvMsgID is ESME_RINVMSGID, message ID is invalid
+	StatusInvMsgID Status = 0x0000000C
+	// StatusBindFail is ESME_RBINDFAIL, bind failed
+	StatusBindFail Status = 0x0000000D
+	// StatusInvPaswd is ESME_RINVPASWD, invalid password
+	StatusInvPaswd Status = 0x0000000E
+	// StatusInvSysID is ESME_RINVSYSID, invalid system ID
+	StatusInvSysID Status = 0x0000000F
+	// StatusCancelFail is ESME_RCANCELFAIL, cancel SM failed
+	StatusCancelFail Status = 0x00000011
+	// StatusReplaceFail is ESME_RREPLACEFAIL, replace SM failed
+	StatusReplaceFail Status = 0x00000013
+	// StatusMsgQFul is ESME_RMSGQFUL, message queue full
+	StatusMsgQFul Status = 0x00000014
+	// StatusInvEsmClass is ESME_RINVESMCLASS, invalid esm_class field data
+	StatusInvEsmClass Status = 0x00000043
+	// StatusSubmitFail is ESME_RSUBMITFAIL, submit_sm or submit_multi failed
+	StatusSubmitFail Status = 0x00000045
+	// StatusThrottled is ESME_RTHROTTLED, throttling error
+	StatusThrottled Status = 0x00000058
+	// StatusInvOptParStream is ESME_RINVOPTPARSTREAM, error in the optional part of the PDU body
+	StatusInvOptParStream Status = 0x000000C0
+	// StatusInvParLen is ESME_RINVPARLEN, invalid parameter length
+	StatusInvParLen Status = 0x000000C2
+	// StatusInvDCS is ESME_RINVDCS, invalid data coding scheme
+	StatusInvDCS Status = 0x00000104
+	// StatusDeliveryFailure is ESME_RDELIVERYFAILURE, delivery failure
+	StatusDeliveryFailure Status = 0x000000FE
+	// StatusUnknownErr is ESME_RUNKNOWNERR, unknown error
+	StatusUnknownErr Status = 0x000000FF
+)
+
+func (s Status) Error() string {
+	switch s {
+	case StatusOK:
+		return "ESME_ROK"
+	case StatusInvMsgLen:
+		return "ESME_RINVMSGLEN"
+	case StatusInvCmdLen:
+		return "ESME_RINVCMDLEN"
+	case StatusInvCmdID:
+		return "ESME_RINVCMDID"
+	case StatusInvBndSts:
+		return "ESME_RINVBNDSTS"
+	case StatusAlyBnd:
+		return "ESME_RALYBND"
+	case StatusSysErr:
+		return "ESME_RSYSERR"
+	case StatusInvSrcAdr:
+		return "ESME_RINVSRCADR"
+	case StatusInvDstAdr:
+		return "ESME_RINVDSTADR"
+	case StatusInvMsgID:
+		return "ESME_RINVMSGID"
+	case StatusBindFail:
+		return "ESME_RBINDFAIL"
+	case StatusInvPaswd:
+		return "ESME_RINVPASWD"
+	case StatusInvSysID:
+		return "ESME_RINVSYSID"
+	case StatusCancelFail:
+		return "ESME_RCANCELFAIL"
+	case StatusReplaceFail:
+		return "ESME_RREPLACEFAIL"
+	case StatusMsgQFul:
+		return "ESME_RMSGQFUL"
+	case StatusInvEsmClass:
+		return "ESME_RINVESMCLASS"
+	case StatusSubmitFail:
+		return "ESME_RSUBMITFAIL"
+	case StatusThrottled:
+		return "ESME_RTHROTTLED"
+	case StatusInvOptParStream:
+		return "ESME_RINVOPTPARSTREAM"
+	case StatusInvParLen:
+		return "ESME_RINVPARLEN"
+	case StatusInvDCS:
+		return "ESME_RINVDCS"
+	case StatusDeliveryFailure:
+		return "ESME_RDELIVERYFAILURE"
+	case StatusUnknownErr:
+		return "ESME_RUNKNOWNERR"
+	}
+	return fmt.Sprintf("command_status %08x", uint32(s))
+}
+
+// UnknownCommandError show invalid command_id
+type UnknownCommandError struct {
+	ID CommandID
+}
+
+func (e UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command_id %08x", uint32(e.ID))
+}
+
+// DecodeError show the PDU body is broken at Offset
+type DecodeError struct {
+	ID     CommandID
+	Offset int
+	Err    error
+}
+
+func (e DecodeError) Error() string {
+	return fmt.Sprintf("invalid %s at offset %d: %s", e.ID, e.Offset, e.Err)
+}
+
+func (e DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// EncodeError show the field of PDU at Offset can't be encoded
+type EncodeError struct {
+	ID     CommandID
+	Offset int
+	Err    error
+}
+
+func (e EncodeError) Error() string {
+	return fmt.Sprintf("failed to encode %s at offset %d: %s", e.ID, e.Offset, e.Err)
+}
+
+func (e EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownDataCodingError show data_coding that can't be converted to DCS
+type UnknownDataCodingError struct {
+	DataCoding byte
+}
+
+func (e UnknownDataCodingError) Error() string {
+	return fmt.Sprintf("unknown data_coding %x", e.DataCoding)
+}
+
+// InvalidTimeError show invalid SMPP time format
+type InvalidTimeError struct {
+	Value string
+}
+
+func (e InvalidTimeError) Error() string {
+	return fmt.Sprintf("invalid time format %q", e.Value)
+}
+
+var (
+	// ErrInvalidLength is returned when length of PDU or field is invalid
+	ErrInvalidLength = errors.New("invalid length")
+	// ErrExtraData is returned when PDU has unknown data after the body
+	ErrExtraData = errors.New("extra data after PDU body")
+	// ErrInvalidTLV is returned when TLV is broken
+	ErrInvalidTLV = errors.New("invalid TLV")
+	// ErrDuplicatePayload is returned when both short_message and
+	// message_payload are present
+	ErrDuplicatePayload = errors.New("both short_message and message_payload are present")
+	// ErrUDHSegmentation is returned when message that has UDH
+	// needs segmentation
+	ErrUDHSegmentation = errors.New("message with UDH is too long")
+	// ErrUnsupportedTPDU is returned when the TPDU can't be sent
+	// on SMPP session
+	ErrUnsupportedTPDU = errors.New("unsupported TPDU")
+
+	// ErrTimeout is returned when response is not received in time
+	ErrTimeout = errors.New("response timeout")
+	// ErrNotBound is returned when the session is not bound
+	ErrNotBound = errors.New("session is not bound")
+	// ErrSessionClosed is returned when the session is closed
+	ErrSessionClosed = errors.New("session closed")
+)
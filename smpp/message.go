@@ -0,0 +1,352 @@
+package smpp
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// EsmDeliveryReceipt is message type of esm_class for SMSC delivery receipt
+	EsmDeliveryReceipt byte = 0x04
+	// EsmUDHI is UDHI indicator of esm_class
+	EsmUDHI byte = 0x40
+	// EsmReplyPath is reply path of esm_class
+	EsmReplyPath byte = 0x80
+
+	// RegisteredDeliveryReceipt request SMSC delivery receipt
+	// on both success and failure
+	RegisteredDeliveryReceipt byte = 0x01
+	// RegisteredDeliveryFailure request SMSC delivery receipt on failure
+	RegisteredDeliveryFailure byte = 0x02
+)
+
+// MessageState is message_state of query_sm_resp
+type MessageState byte
+
+const (
+	// StateEnroute means the message is in enroute state
+	StateEnroute MessageState = 1
+	// StateDelivered means the message is delivered to destination
+	StateDelivered MessageState = 2
+	// StateExpired means validity period of the message has expired
+	StateExpired MessageState = 3
+	// StateDeleted means the message has been deleted
+	StateDeleted MessageState = 4
+	// StateUndeliverable means the message is undeliverable
+	StateUndeliverable MessageState = 5
+	// StateAccepted means the message is in accepted state
+	StateAccepted MessageState = 6
+	// StateUnknown means the message is in invalid state
+	StateUnknown MessageState = 7
+	// StateRejected means the message is in a rejected state
+	StateRejected MessageState = 8
+)
+
+// Address is source or destination address of SMPP PDU
+type Address struct {
+	TON  byte
+	NPI  byte
+	Addr string
+}
+
+func (a Address) String() string {
+	return fmt.Sprintf("%s(ton=%d,npi=%d)", a.Addr, a.TON, a.NPI)
+}
+
+// Message is common body of submit_sm and deliver_sm
+type Message struct {
+	ServiceType          string
+	Source               Address
+	Dest                 Address
+	EsmClass             byte
+	ProtocolID           byte
+	Priority             byte
+	ScheduleDeliveryTime string
+	ValidityPeriod       string
+	RegisteredDelivery   byte
+	ReplaceIfPresent     byte
+	DataCoding           byte
+	SMDefaultMsgID       byte
+	ShortMessage         []byte
+	TLVs                 TLVs
+}
+
+func (p Message) appendBody(w *writer) {
+	w.cstring(p.ServiceType, 6)
+	w.address(p.Source, 21)
+	w.address(p.Dest, 21)
+	w.bytes(p.EsmClass, p.ProtocolID, p.Priority)
+	w.cstring(p.ScheduleDeliveryTime, 17)
+	w.cstring(p.ValidityPeriod, 17)
+	w.bytes(p.RegisteredDelivery, p.ReplaceIfPresent,
+		p.DataCoding, p.SMDefaultMsgID)
+	w.shortMessage(p.ShortMessage)
+	w.tlvs(p.TLVs)
+}
+
+func (p *Message) unmarshalBody(r *reader) (e error) {
+	var l byte
+	if e = r.fields([]int{6, 21, 21, 17, 17},
+		&p.ServiceType, &p.Source, &p.Dest,
+		&p.EsmClass, &p.ProtocolID, &p.Priority,
+		&p.ScheduleDeliveryTime, &p.ValidityPeriod,
+		&p.RegisteredDelivery, &p.ReplaceIfPresent,
+		&p.DataCoding, &p.SMDefaultMsgID, &l); e != nil {
+		return
+	}
+	if l > 254 {
+		return ErrInvalidLength
+	}
+	if p.ShortMessage, e = r.octets(int(l)); e != nil {
+		return
+	}
+	p.TLVs, e = readTLVs(r)
+	return
+}
+
+func (p Message) String() string {
+	w := new(bytes.Buffer)
+	fmt.Fprintf(w, "source=%s dest=%s", p.Source, p.Dest)
+	fmt.Fprintf(w, " esm_class=%x data_coding=%x", p.EsmClass, p.DataCoding)
+	fmt.Fprintf(w, " registered_delivery=%x", p.RegisteredDelivery)
+	fmt.Fprintf(w, " short_message=% x", p.ShortMessage)
+	for _, t := range p.TLVs {
+		fmt.Fprintf(w, " %s", t)
+	}
+	return w.String()
+}
+
+// SubmitSM is submit_sm
+type SubmitSM struct {
+	Message
+}
+
+// CommandID returns command_id of this PDU
+func (SubmitSM) CommandID() CommandID { return SubmitSMID }
+
+func (p SubmitSM) String() string {
+	return "submit_sm " + p.Message.String()
+}
+
+// SubmitSMResp is response of SubmitSM
+type SubmitSMResp struct {
+	MessageID string
+}
+
+// CommandID returns command_id of this PDU
+func (SubmitSMResp) CommandID() CommandID { return SubmitSMRespID }
+
+func (p SubmitSMResp) appendBody(w *writer) {
+	w.cstring(p.MessageID, 65)
+}
+
+func (p *SubmitSMResp) unmarshalBody(r *reader) (e error) {
+	p.MessageID, e = r.cstring(65)
+	return
+}
+
+// DeliverSM is deliver_sm
+type DeliverSM struct {
+	Message
+}
+
+// CommandID returns command_id of this PDU
+func (DeliverSM) CommandID() CommandID { return DeliverSMID }
+
+func (p DeliverSM) String() string {
+	return "deliver_sm " + p.Message.String()
+}
+
+// DeliverSMResp is response of DeliverSM,
+// MessageID is unused and should be empty
+type DeliverSMResp struct {
+	MessageID string
+}
+
+// CommandID returns command_id of this PDU
+func (DeliverSMResp) CommandID() CommandID { return DeliverSMRespID }
+
+func (p DeliverSMResp) appendBody(w *writer) {
+	w.cstring(p.MessageID, 65)
+}
+
+func (p *DeliverSMResp) unmarshalBody(r *reader) (e error) {
+	p.MessageID, e = r.cstring(65)
+	return
+}
+
+// DataSM is data_sm
+type DataSM struct {
+	ServiceType        string
+	Source             Address
+	Dest               Address
+	EsmClass           byte
+	RegisteredDelivery byte
+	DataCoding         byte
+	TLVs               TLVs
+}
+
+// CommandID returns command_id of this PDU
+func (DataSM) CommandID() CommandID { return DataSMID }
+
+func (p DataSM) appendBody(w *writer) {
+	w.cstring(p.ServiceType, 6)
+	w.address(p.Source, 65)
+	w.address(p.Dest, 65)
+	w.bytes(p.EsmClass, p.RegisteredDelivery, p.DataCoding)
+	w.tlvs(p.TLVs)
+}
+
+func (p *DataSM) unmarshalBody(r *reader) (e error) {
+	if e = r.fields([]int{6, 65, 65},
+		&p.ServiceType, &p.Source, &p.Dest,
+		&p.EsmClass, &p.RegisteredDelivery, &p.DataCoding); e == nil {
+		p.TLVs, e = readTLVs(r)
+	}
+	return
+}
+
+// DataSMResp is response of DataSM
+type DataSMResp struct {
+	MessageID string
+	TLVs      TLVs
+}
+
+// CommandID returns command_id of this PDU
+func (DataSMResp) CommandID() CommandID { return DataSMRespID }
+
+func (p DataSMResp) appendBody(w *writer) {
+	w.cstring(p.MessageID, 65)
+	w.tlvs(p.TLVs)
+}
+
+func (p *DataSMResp) unmarshalBody(r *reader) (e error) {
+	if p.MessageID, e = r.cstring(65); e == nil {
+		p.TLVs, e = readTLVs(r)
+	}
+	return
+}
+
+// QuerySM is query_sm
+type QuerySM struct {
+	MessageID string
+	Source    Address
+}
+
+// CommandID returns command_id of this PDU
+func (QuerySM) CommandID() CommandID { return QuerySMID }
+
+func (p QuerySM) appendBody(w *writer) {
+	w.cstring(p.MessageID, 65)
+	w.address(p.Source, 21)
+}
+
+func (p *QuerySM) unmarshalBody(r *reader) error {
+	return r.fields([]int{65, 21}, &p.MessageID, &p.Source)
+}
+
+// QuerySMResp is response of QuerySM
+type QuerySMResp struct {
+	MessageID string
+	FinalDate string
+	State     MessageState
+	ErrorCode byte
+}
+
+// CommandID returns command_id of this PDU
+func (QuerySMResp) CommandID() CommandID { return QuerySMRespID }
+
+func (p QuerySMResp) appendBody(w *writer) {
+	w.cstring(p.MessageID, 65)
+	w.cstring(p.FinalDate, 17)
+	w.bytes(byte(p.State), p.ErrorCode)
+}
+
+func (p *QuerySMResp) unmarshalBody(r *reader) error {
+	var s byte
+	e := r.fields([]int{65, 17},
+		&p.MessageID, &p.FinalDate, &s, &p.ErrorCode)
+	p.State = MessageState(s)
+	return e
+}
+
+// CancelSM is cancel_sm
+type CancelSM struct {
+	ServiceType string
+	MessageID   string
+	Source      Address
+	Dest        Address
+}
+
+// CommandID returns command_id of this PDU
+func (CancelSM) CommandID() CommandID { return CancelSMID }
+
+func (p CancelSM) appendBody(w *writer) {
+	w.cstring(p.ServiceType, 6)
+	w.cstring(p.MessageID, 65)
+	w.address(p.Source, 21)
+	w.address(p.Dest, 21)
+}
+
+func (p *CancelSM) unmarshalBody(r *reader) error {
+	return r.fields([]int{6, 65, 21, 21},
+		&p.ServiceType, &p.MessageID, &p.Source, &p.Dest)
+}
+
+// CancelSMResp is response of CancelSM
+type CancelSMResp struct{}
+
+// CommandID returns command_id of this PDU
+func (CancelSMResp) CommandID() CommandID { return CancelSMRespID }
+
+func (CancelSMResp) appendBody(*writer) {}
+
+func (*CancelSMResp) unmarshalBody(*reader) error { return nil }
+
+// ReplaceSM is replace_sm
+type ReplaceSM struct {
+	MessageID            string
+	Source               Address
+	ScheduleDeliveryTime string
+	ValidityPeriod       string
+	RegisteredDelivery   byte
+	SMDefaultMsgID       byte
+	ShortMessage         []byte
+}
+
+// CommandID returns command_id of this PDU
+func (ReplaceSM) CommandID() CommandID { return ReplaceSMID }
+
+func (p ReplaceSM) appendBody(w *writer) {
+	w.cstring(p.MessageID, 65)
+	w.address(p.Source, 21)
+	w.cstring(p.ScheduleDeliveryTime, 17)
+	w.cstring(p.ValidityPeriod, 17)
+	w.bytes(p.RegisteredDelivery, p.SMDefaultMsgID)
+	w.shortMessage(p.ShortMessage)
+}
+
+func (p *ReplaceSM) unmarshalBody(r *reader) (e error) {
+	var l byte
+	if e = r.fields([]int{65, 21, 17, 17},
+		&p.MessageID, &p.Source,
+		&p.ScheduleDeliveryTime, &p.ValidityPeriod,
+		&p.RegisteredDelivery, &p.SMDefaultMsgID, &l); e != nil {
+		return
+	}
+	if l > 254 {
+		return ErrInvalidLength
+	}
+	p.ShortMessage, e = r.octets(int(l))
+	return
+}
+
+// ReplaceSMResp is response of ReplaceSM
+type ReplaceSMResp struct{}
+
+// CommandID returns command_id of this PDU
+func (ReplaceSMResp) CommandID() CommandID { return ReplaceSMRespID }
+
+func (ReplaceSMResp) appendBody(*writer) {}
+
+func (*ReplaceSMResp) unmarshalBody(*reader) error { return nil }
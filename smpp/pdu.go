@@ -0,0 +1,427 @@
+/*
+Package smpp implements SMPP 3.4 PDU codec and conversion
+between submit_sm/deliver_sm and SMS TPDU.
+*/
+package smpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// CommandID is command_id of SMPP PDU
+type CommandID uint32
+
+const (
+	// GenericNackID is command_id of generic_nack
+	GenericNackID CommandID = 0x80000000
+	// BindReceiverID is command_id of bind_receiver
+	BindReceiverID CommandID = 0x00000001
+	// BindReceiverRespID is command_id of bind_receiver_resp
+	BindReceiverRespID CommandID = 0x80000001
+	// BindTransmitterID is command_id of bind_transmitter
+	BindTransmitterID CommandID = 0x00000002
+	// BindTransmitterRespID is command_id of bind_transmitter_resp
+	BindTransmitterRespID CommandID = 0x80000002
+	// QuerySMID is command_id of query_sm
+	QuerySMID CommandID = 0x00000003
+	// QuerySMRespID is command_id of query_sm_resp
+	QuerySMRespID CommandID = 0x80000003
+	// SubmitSMID is command_id of submit_sm
+	SubmitSMID CommandID = 0x00000004
+	// SubmitSMRespID is command_id of submit_sm_resp
+	SubmitSMRespID CommandID = 0x80000004
+	// DeliverSMID is command_id of deliver_sm
+	DeliverSMID CommandID = 0x00000005
+	// DeliverSMRespID is command_id of deliver_sm_resp
+	DeliverSMRespID CommandID = 0x80000005
+	// UnbindID is command_id of unbind
+	UnbindID CommandID = 0x00000006
+	// UnbindRespID is command_id of unbind_resp
+	UnbindRespID CommandID = 0x80000006
+	// ReplaceSMID is command_id of replace_sm
+	ReplaceSMID CommandID = 0x00000007
+	// ReplaceSMRespID is command_id of replace_sm_resp
+	ReplaceSMRespID CommandID = 0x80000007
+	// CancelSMID is command_id of cancel_sm
+	CancelSMID CommandID = 0x00000008
+	// CancelSMRespID is command_id of cancel_sm_resp
+	CancelSMRespID CommandID = 0x80000008
+	// BindTransceiverID is command_id of bind_transceiver
+	BindTransceiverID CommandID = 0x00000009
+	// BindTransceiverRespID is command_id of bind_transceiver_resp
+	BindTransceiverRespID CommandID = 0x80000009
+	// EnquireLinkID is command_id of enquire_link
+	EnquireLinkID CommandID = 0x00000015
+	// EnquireLinkRespID is command_id of enquire_link_resp
+	EnquireLinkRespID CommandID = 0x80000015
+	// DataSMID is command_id of data_sm
+	DataSMID CommandID = 0x00000103
+	// DataSMRespID is command_id of data_sm_resp
+	DataSMRespID CommandID = 0x80000103
+)
+
+func (c CommandID) String() string {
+	switch c {
+	case GenericNackID:
+		return "generic_nack"
+	case BindReceiverID:
+		return "bind_receiver"
+	case BindReceiverRespID:
+		return "bind_receiver_resp"
+	case BindTransmitterID:
+		return "bind_transmitter"
+	case BindTransmitterRespID:
+		return "bind_transmitter_resp"
+	case QuerySMID:
+		return "query_sm"
+	case QuerySMRespID:
+		return "query_sm_resp"
+	case SubmitSMID:
+		return "submit_sm"
+	case SubmitSMRespID:
+		return "submit_sm_resp"
+	case DeliverSMID:
+		return "deliver_sm"
+	case DeliverSMRespID:
+		return "deliver_sm_resp"
+	case UnbindID:
+		return "unbind"
+	case UnbindRespID:
+		return "unbind_resp"
+	case ReplaceSMID:
+		return "replace_sm"
+	case ReplaceSMRespID:
+		return "replace_sm_resp"
+	case CancelSMID:
+		return "cancel_sm"
+	case CancelSMRespID:
+		return "cancel_sm_resp"
+	case BindTransceiverID:
+		return "bind_transceiver"
+	case BindTransceiverRespID:
+		return "bind_transceiver_resp"
+	case EnquireLinkID:
+		return "enquire_link"
+	case EnquireLinkRespID:
+		return "enquire_link_resp"
+	case DataSMID:
+		return "data_sm"
+	case DataSMRespID:
+		return "data_sm_resp"
+	}
+	return fmt.Sprintf("unknown(%08x)", uint32(c))
+}
+
+// IsResp reports c is command_id of response PDU
+func (c CommandID) IsResp() bool {
+	return c&0x80000000 == 0x80000000
+}
+
+// MaxLength is maximum command_length of PDU to read
+var MaxLength = 0x10000
+
+// PDU is body of SMPP PDU
+type PDU interface {
+	CommandID() CommandID
+	appendBody(*writer)
+}
+
+// body is pointer of PDU that can be decoded
+type body interface {
+	PDU
+	unmarshalBody(*reader) error
+}
+
+// Header is header of SMPP PDU except command_length
+type Header struct {
+	ID     CommandID // command_id
+	Status Status    // command_status
+	Seq    uint32    // sequence_number
+}
+
+// Marshal returns byte data of PDU p with command_status st and
+// sequence_number seq
+func Marshal(p PDU, st Status, seq uint32) ([]byte, error) {
+	return Append(nil, p, st, seq)
+}
+
+// Append append byte data of PDU p with command_status st and
+// sequence_number seq to dst.
+// dst is returned without change if a field of p exceeds its max length.
+func Append(dst []byte, p PDU, st Status, seq uint32) ([]byte, error) {
+	h := len(dst)
+	w := &writer{b: append(dst, make([]byte, 16)...), h: h}
+	p.appendBody(w)
+	if w.e != nil {
+		return dst[:h], EncodeError{ID: p.CommandID(), Offset: w.o, Err: w.e}
+	}
+	b := w.b
+	binary.BigEndian.PutUint32(b[h:], uint32(len(b)-h))
+	binary.BigEndian.PutUint32(b[h+4:], uint32(p.CommandID()))
+	binary.BigEndian.PutUint32(b[h+8:], uint32(st))
+	binary.BigEndian.PutUint32(b[h+12:], seq)
+	return b, nil
+}
+
+// Unmarshal decode byte data of a SMPP PDU.
+// Body of response PDU that has error command_status can be empty,
+// and the header is returned even if the body is broken.
+func Unmarshal(b []byte) (p PDU, h Header, e error) {
+	if len(b) < 16 {
+		e = ErrInvalidLength
+		return
+	}
+	if l := binary.BigEndian.Uint32(b); l != uint32(len(b)) {
+		e = ErrInvalidLength
+		return
+	}
+	h.ID = CommandID(binary.BigEndian.Uint32(b[4:]))
+	h.Status = Status(binary.BigEndian.Uint32(b[8:]))
+	h.Seq = binary.BigEndian.Uint32(b[12:])
+
+	var u body
+	switch h.ID {
+	case GenericNackID:
+		u = &GenericNack{}
+	case BindReceiverID, BindTransmitterID, BindTransceiverID:
+		u = &Bind{Type: bindType(h.ID)}
+	case BindReceiverRespID, BindTransmitterRespID, BindTransceiverRespID:
+		u = &BindResp{Type: bindType(h.ID &^ 0x80000000)}
+	case QuerySMID:
+		u = &QuerySM{}
+	case QuerySMRespID:
+		u = &QuerySMResp{}
+	case SubmitSMID:
+		u = &SubmitSM{}
+	case SubmitSMRespID:
+		u = &SubmitSMResp{}
+	case DeliverSMID:
+		u = &DeliverSM{}
+	case DeliverSMRespID:
+		u = &DeliverSMResp{}
+	case UnbindID:
+		u = &Unbind{}
+	case UnbindRespID:
+		u = &UnbindResp{}
+	case ReplaceSMID:
+		u = &ReplaceSM{}
+	case ReplaceSMRespID:
+		u = &ReplaceSMResp{}
+	case CancelSMID:
+		u = &CancelSM{}
+	case CancelSMRespID:
+		u = &CancelSMResp{}
+	case EnquireLinkID:
+		u = &EnquireLink{}
+	case EnquireLinkRespID:
+		u = &EnquireLinkResp{}
+	case DataSMID:
+		u = &DataSM{}
+	case DataSMRespID:
+		u = &DataSMResp{}
+	default:
+		e = UnknownCommandError{ID: h.ID}
+		return
+	}
+
+	r := &reader{Buffer: bytes.NewBuffer(b[16:])}
+	if h.ID.IsResp() && h.Status != StatusOK && r.Len() == 0 {
+		p = deref(u)
+		return
+	}
+	if e = u.unmarshalBody(r); e == nil && r.Len() != 0 {
+		e = ErrExtraData
+	}
+	if e != nil {
+		e = DecodeError{ID: h.ID, Offset: len(b) - r.Len(), Err: e}
+	}
+	p = deref(u)
+	return
+}
+
+// deref returns value of PDU pointer that is made in Unmarshal
+func deref(p body) PDU {
+	switch v := p.(type) {
+	case *GenericNack:
+		return *v
+	case *Bind:
+		return *v
+	case *BindResp:
+		return *v
+	case *QuerySM:
+		return *v
+	case *QuerySMResp:
+		return *v
+	case *SubmitSM:
+		return *v
+	case *SubmitSMResp:
+		return *v
+	case *DeliverSM:
+		return *v
+	case *DeliverSMResp:
+		return *v
+	case *Unbind:
+		return *v
+	case *UnbindResp:
+		return *v
+	case *ReplaceSM:
+		return *v
+	case *ReplaceSMResp:
+		return *v
+	case *CancelSM:
+		return *v
+	case *CancelSMResp:
+		return *v
+	case *EnquireLink:
+		return *v
+	case *EnquireLinkResp:
+		return *v
+	case *DataSM:
+		return *v
+	case *DataSMResp:
+		return *v
+	}
+	return p
+}
+
+// Read reads byte data of a SMPP PDU from r
+func Read(r io.Reader) ([]byte, error) {
+	h := make([]byte, 4)
+	if _, e := io.ReadFull(r, h); e != nil {
+		return nil, e
+	}
+	l := int(binary.BigEndian.Uint32(h))
+	if l < 16 || l > MaxLength {
+		return nil, ErrInvalidLength
+	}
+	b := make([]byte, l)
+	copy(b, h)
+	if _, e := io.ReadFull(r, b[4:]); e != nil {
+		if e == io.EOF {
+			e = io.ErrUnexpectedEOF
+		}
+		return nil, e
+	}
+	return b, nil
+}
+
+// reader reads fields of PDU body
+type reader struct {
+	*bytes.Buffer
+}
+
+// cstring reads C-Octet String that is max octets including NULL
+func (r *reader) cstring(max int) (string, error) {
+	b, e := r.ReadBytes(0x00)
+	if e != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	if len(b) > max {
+		return "", ErrInvalidLength
+	}
+	return string(b[:len(b)-1]), nil
+}
+
+func (r *reader) byte() (byte, error) {
+	b, e := r.ReadByte()
+	if e != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+func (r *reader) octets(l int) ([]byte, error) {
+	if r.Len() < l {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return append([]byte{}, r.Next(l)...), nil
+}
+
+// fields reads C-Octet String and Integer fields in order,
+// each field is *string, *byte or *Address
+func (r *reader) fields(max []int, v ...interface{}) (e error) {
+	i := 0
+	for _, f := range v {
+		switch p := f.(type) {
+		case *string:
+			*p, e = r.cstring(max[i])
+			i++
+		case *byte:
+			*p, e = r.byte()
+		case *Address:
+			if p.TON, e = r.byte(); e != nil {
+				return
+			}
+			if p.NPI, e = r.byte(); e != nil {
+				return
+			}
+			p.Addr, e = r.cstring(max[i])
+			i++
+		}
+		if e != nil {
+			return
+		}
+	}
+	return
+}
+
+// writer writes fields of PDU body,
+// writing stops at the first field that exceeds its max length
+type writer struct {
+	b []byte
+	h int   // head of the PDU
+	o int   // offset of the invalid field
+	e error // error of the invalid field
+}
+
+func (w *writer) fail(e error) {
+	w.e = e
+	w.o = len(w.b) - w.h
+}
+
+// cstring writes C-Octet String that is max octets including NULL
+func (w *writer) cstring(s string, max int) {
+	switch {
+	case w.e != nil:
+	case len(s) >= max:
+		w.fail(ErrInvalidLength)
+	default:
+		w.b = append(append(w.b, s...), 0x00)
+	}
+}
+
+func (w *writer) bytes(b ...byte) {
+	if w.e == nil {
+		w.b = append(w.b, b...)
+	}
+}
+
+func (w *writer) address(a Address, max int) {
+	w.bytes(a.TON, a.NPI)
+	w.cstring(a.Addr, max)
+}
+
+// shortMessage writes sm_length and short_message
+func (w *writer) shortMessage(b []byte) {
+	if w.e == nil && len(b) > 254 {
+		w.fail(ErrInvalidLength)
+		return
+	}
+	w.bytes(byte(len(b)))
+	w.bytes(b...)
+}
+
+func (w *writer) tlvs(l TLVs) {
+	for _, v := range l {
+		if w.e == nil && len(v.Value) > 0xffff {
+			w.fail(ErrInvalidTLV)
+			return
+		}
+		w.bytes(byte(v.Tag>>8), byte(v.Tag),
+			byte(len(v.Value)>>8), byte(len(v.Value)))
+		w.bytes(v.Value...)
+	}
+}
@@ -0,0 +1,223 @@
+package smpp_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/fkgi/sms/smpp"
+)
+
+// marshal returns byte data of p, or fails t
+func marshal(t *testing.T, p smpp.PDU, seq uint32) []byte {
+	b, e := smpp.Marshal(p, smpp.StatusOK, seq)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return b
+}
+
+func TestPDURoundTrip(t *testing.T) {
+	msg := smpp.Message{
+		ServiceType:          "CMT",
+		Source:               smpp.Address{TON: 1, NPI: 1, Addr: "819012345678"},
+		Dest:                 smpp.Address{TON: 5, NPI: 0, Addr: "Alpha"},
+		EsmClass:             smpp.EsmUDHI,
+		ProtocolID:           0x00,
+		Priority:             1,
+		ScheduleDeliveryTime: "240101120000000+",
+		ValidityPeriod:       "000001000000000R",
+		RegisteredDelivery:   smpp.RegisteredDeliveryReceipt,
+		DataCoding:           0x08,
+		ShortMessage:         []byte{0x05, 0x00, 0x03, 0x01, 0x02, 0x01, 0x00, 0x41},
+		TLVs: smpp.TLVs{
+			{Tag: smpp.TagUserMessageReference, Value: []byte{0x00, 0x10}},
+			{Tag: 0x1400, Value: []byte{}}}}
+
+	for _, p := range []smpp.PDU{
+		smpp.Bind{Type: smpp.Transmitter, SystemID: "esme", Password: "secret",
+			SystemType: "test", Version: smpp.InterfaceVersion,
+			AddrTON: 1, AddrNPI: 1, AddressRange: "^81"},
+		smpp.Bind{Type: smpp.Receiver, SystemID: "esme"},
+		smpp.Bind{Type: smpp.Transceiver, SystemID: "esme"},
+		smpp.BindResp{Type: smpp.Transmitter, SystemID: "smsc"},
+		smpp.BindResp{Type: smpp.Transceiver, SystemID: "smsc", TLVs: smpp.TLVs{
+			{Tag: smpp.TagSCInterfaceVersion, Value: []byte{0x34}}}},
+		smpp.Unbind{},
+		smpp.UnbindResp{},
+		smpp.EnquireLink{},
+		smpp.EnquireLinkResp{},
+		smpp.GenericNack{},
+		smpp.SubmitSM{Message: msg},
+		smpp.SubmitSMResp{MessageID: "0123456789abcdef"},
+		smpp.DeliverSM{Message: msg},
+		smpp.DeliverSMResp{},
+		smpp.DataSM{ServiceType: "WAP", Source: msg.Source, Dest: msg.Dest,
+			EsmClass: 0x00, RegisteredDelivery: 0x01, DataCoding: 0x04,
+			TLVs: smpp.TLVs{{Tag: smpp.TagMessagePayload, Value: []byte{1, 2, 3}}}},
+		smpp.DataSMResp{MessageID: "id1"},
+		smpp.QuerySM{MessageID: "id1", Source: msg.Source},
+		smpp.QuerySMResp{MessageID: "id1", FinalDate: "240101120000000+",
+			State: smpp.StateDelivered, ErrorCode: 0},
+		smpp.CancelSM{ServiceType: "CMT", MessageID: "id1",
+			Source: msg.Source, Dest: msg.Dest},
+		smpp.CancelSMResp{},
+		smpp.ReplaceSM{MessageID: "id1", Source: msg.Source,
+			ValidityPeriod: "000002000000000R", RegisteredDelivery: 1,
+			ShortMessage: []byte("replaced")},
+		smpp.ReplaceSMResp{},
+	} {
+		b := marshal(t, p, 0x7fffffff)
+		t.Logf("%s: % x", p.CommandID(), b)
+
+		res, h, e := smpp.Unmarshal(b)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if h.ID != p.CommandID() || h.Status != smpp.StatusOK || h.Seq != 0x7fffffff {
+			t.Fatalf("header mismatch %+v", h)
+		}
+		if !reflect.DeepEqual(p, res) {
+			t.Fatalf("PDU mismatch\norig=%+v\nocom=%+v", p, res)
+		}
+	}
+}
+
+func TestPDUVector(t *testing.T) {
+	b, _ := hex.DecodeString("00000010000000150000000000000001")
+	p, h, e := smpp.Unmarshal(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, ok := p.(smpp.EnquireLink); !ok || h.Seq != 1 {
+		t.Fatalf("unexpected %T %+v", p, h)
+	}
+
+	b = marshal(t, smpp.Bind{
+		Type: smpp.Transmitter, SystemID: "ab", Password: "cd",
+		Version: smpp.InterfaceVersion}, 2)
+	if x := hex.EncodeToString(b); x !=
+		"0000001b0000000200000000000000026162006364000034000000" {
+		t.Fatalf("unexpected bind_transmitter %s", x)
+	}
+}
+
+func TestPDUError(t *testing.T) {
+	// error response without body
+	b := marshal(t, smpp.UnbindResp{}, 1)
+	b[7] = 0x04 // submit_sm_resp
+	b[11] = byte(smpp.StatusThrottled)
+	p, h, e := smpp.Unmarshal(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, ok := p.(smpp.SubmitSMResp); !ok || h.Status != smpp.StatusThrottled {
+		t.Fatalf("unexpected %T %+v", p, h)
+	}
+	if h.Status.Error() != "ESME_RTHROTTLED" {
+		t.Fatalf("unexpected %s", h.Status)
+	}
+
+	// unknown command
+	b[7] = 0x99
+	var uce smpp.UnknownCommandError
+	if _, h, e = smpp.Unmarshal(b); !errors.As(e, &uce) {
+		t.Fatalf("unexpected error %v", e)
+	} else if h.Seq != 1 {
+		t.Fatalf("header is not returned %+v", h)
+	}
+
+	// too long C-Octet String
+	b = marshal(t, smpp.SubmitSMResp{
+		MessageID: string(bytes.Repeat([]byte("1"), 64))}, 1)
+	b = append(b[:len(b)-1], '1', 0x00)
+	b[3]++
+	if _, _, e = smpp.Unmarshal(b); !errors.Is(e, smpp.ErrInvalidLength) {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// truncated body
+	b = marshal(t, smpp.QuerySM{MessageID: "id"}, 1)
+	b = b[:len(b)-1]
+	b[3]--
+	if _, _, e = smpp.Unmarshal(b); !errors.Is(e, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// extra data
+	b = append(marshal(t, smpp.EnquireLink{}, 1), 0x00)
+	b[3]++
+	if _, _, e = smpp.Unmarshal(b); !errors.Is(e, smpp.ErrExtraData) {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// broken TLV
+	b = append(marshal(t, smpp.DataSMResp{}, 1), 0x04, 0x24, 0x00)
+	b[3] += 3
+	if _, _, e = smpp.Unmarshal(b); !errors.Is(e, smpp.ErrInvalidTLV) {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
+
+func TestPDUEncodeError(t *testing.T) {
+	long := func(n int) string { return string(bytes.Repeat([]byte("1"), n)) }
+	for _, p := range []smpp.PDU{
+		smpp.SubmitSM{Message: smpp.Message{ShortMessage: make([]byte, 255)}},
+		smpp.ReplaceSM{ShortMessage: make([]byte, 300)},
+		smpp.SubmitSM{Message: smpp.Message{ServiceType: long(6)}},
+		smpp.DeliverSM{Message: smpp.Message{Dest: smpp.Address{Addr: long(21)}}},
+		smpp.DeliverSM{Message: smpp.Message{ValidityPeriod: long(17)}},
+		smpp.SubmitSMResp{MessageID: long(65)},
+		smpp.Bind{Type: smpp.Transceiver, Password: long(9)},
+		smpp.DataSMResp{TLVs: smpp.TLVs{
+			{Tag: smpp.TagMessagePayload, Value: make([]byte, 0x10000)}}},
+	} {
+		dst := []byte{0x01}
+		b, e := smpp.Append(dst, p, smpp.StatusOK, 1)
+		var ee smpp.EncodeError
+		if !errors.As(e, &ee) || ee.ID != p.CommandID() {
+			t.Fatalf("%s: unexpected error %v", p.CommandID(), e)
+		}
+		t.Log(e)
+		if !bytes.Equal(b, dst) {
+			t.Fatalf("%s: unexpected data % x", p.CommandID(), b)
+		}
+	}
+
+	b := marshal(t, smpp.SubmitSM{
+		Message: smpp.Message{ShortMessage: make([]byte, 254)}}, 1)
+	if _, _, e := smpp.Unmarshal(b); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestRead(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.Write(marshal(t, smpp.EnquireLink{}, 1))
+	buf.Write(marshal(t, smpp.SubmitSMResp{MessageID: "1"}, 2))
+	buf.Write([]byte{0x00, 0x00, 0x00, 0x20, 0x00})
+
+	for i := uint32(1); i <= 2; i++ {
+		b, e := smpp.Read(buf)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, h, e := smpp.Unmarshal(b); e != nil || h.Seq != i {
+			t.Fatalf("unexpected %+v %v", h, e)
+		}
+	}
+	if _, e := smpp.Read(buf); e != io.ErrUnexpectedEOF {
+		t.Fatalf("unexpected error %v", e)
+	}
+	if _, e := smpp.Read(buf); e != io.EOF {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	buf.Write([]byte{0x00, 0x00, 0x00, 0x08})
+	if _, e := smpp.Read(buf); e != smpp.ErrInvalidLength {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
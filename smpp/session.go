@@ -0,0 +1,732 @@
+package smpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+var (
+	// Window is maximum number of outstanding requests
+	Window = 10
+	// Timeout is waiting time for response
+	Timeout = time.Duration(10 * time.Second)
+	// EnquireInterval is idle time to send enquire_link
+	EnquireInterval = time.Duration(30 * time.Second)
+	// ThrottleWait is waiting time to retry request rejected by throttling
+	ThrottleWait = time.Duration(time.Second)
+	// ThrottleRetry is maximum retry count of request rejected by throttling
+	ThrottleRetry = 3
+)
+
+// SessionState is state of SMPP session
+type SessionState byte
+
+const (
+	// SessionOpen is connected but not bound state
+	SessionOpen SessionState = iota
+	// SessionBound is bound state
+	SessionBound
+	// SessionUnbinding is state waiting outstanding requests to unbind
+	SessionUnbinding
+	// SessionClosed is closed state
+	SessionClosed
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionOpen:
+		return "Open"
+	case SessionBound:
+		return "Bound"
+	case SessionUnbinding:
+		return "Unbinding"
+	case SessionClosed:
+		return "Closed"
+	}
+	return fmt.Sprintf("Unknown(%d)", byte(s))
+}
+
+// Session is SMPP session of ESME (client) or SMSC (server) on a connection.
+// Session can't be reused after it is closed.
+type Session struct {
+	// Bind is bind request that is sent by Open
+	Bind Bind
+	// SystemID is system_id in bind response that is sent by Accept
+	SystemID string
+	// Window overrides Window value if it is not zero.
+	// Incoming requests over the window are rejected by throttling error.
+	Window int
+	// Timeout overrides Timeout value if it is not zero
+	Timeout time.Duration
+	// EnquireInterval overrides EnquireInterval value if it is not zero,
+	// enquire_link is disabled if it is negative
+	EnquireInterval time.Duration
+	// ThrottleWait overrides ThrottleWait value if it is not zero
+	ThrottleWait time.Duration
+	// ThrottleRetry overrides ThrottleRetry value if it is not zero,
+	// retry is disabled if it is negative
+	ThrottleRetry int
+	// ConcatRef allocates reference to segment long message_payload
+	ConcatRef *sms.RefAllocator
+
+	// Authenticate checks bind request on server side,
+	// all requests are accepted if it is nil
+	Authenticate func(Bind) Status
+	// TranspInd receives Submit on server side or Deliver on client side
+	TranspInd func(sms.TPDU) (sms.TPDU, error)
+	// RequestInd receives other requests, like query_sm or data_sm,
+	// and returns the response
+	RequestInd func(PDU) (PDU, Status)
+
+	conn     net.Conn
+	server   bool
+	slots    chan struct{} // window of outgoing requests
+	done     chan struct{}
+	bound    chan Status
+	active   sync.WaitGroup // outstanding outgoing requests
+	handling sync.WaitGroup // outstanding incoming requests
+	wmutex   sync.Mutex
+
+	mutex    sync.Mutex
+	state    SessionState
+	bindType BindType
+	seq      uint32
+	pending  map[uint32]chan answer
+	incoming int
+	msgID    uint64
+	lastRx   time.Time
+	unbound  bool // unbind is requested by peer or answered
+	err      error
+}
+
+type answer struct {
+	p PDU
+	h Header
+}
+
+func (s *Session) window() int {
+	if s.Window != 0 {
+		return s.Window
+	}
+	return Window
+}
+
+func (s *Session) timeout() time.Duration {
+	if s.Timeout != 0 {
+		return s.Timeout
+	}
+	return Timeout
+}
+
+func (s *Session) enquireInterval() time.Duration {
+	if s.EnquireInterval != 0 {
+		return s.EnquireInterval
+	}
+	return EnquireInterval
+}
+
+func (s *Session) throttleWait() time.Duration {
+	if s.ThrottleWait != 0 {
+		return s.ThrottleWait
+	}
+	return ThrottleWait
+}
+
+func (s *Session) throttleRetry() int {
+	if s.ThrottleRetry != 0 {
+		return s.ThrottleRetry
+	}
+	return ThrottleRetry
+}
+
+// State returns state of the session
+func (s *Session) State() SessionState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+// BindType returns bind type of the bound session
+func (s *Session) BindType() BindType {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.bindType
+}
+
+// Done returns channel that is closed when the session is closed,
+// it is available after Open or Accept
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason of close, nil if the session is unbound
+func (s *Session) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// Open binds the session on connection c as ESME
+func (s *Session) Open(ctx context.Context, c net.Conn) error {
+	s.start(c, false)
+
+	b := s.Bind
+	if b.Type == 0 {
+		b.Type = Transceiver
+	}
+	if b.Version == 0 {
+		b.Version = InterfaceVersion
+	}
+	_, e := s.request(ctx, b, false)
+	if e != nil {
+		s.close(e)
+	}
+	return e
+}
+
+// Accept waits bind request from ESME on connection c as SMSC
+func (s *Session) Accept(ctx context.Context, c net.Conn) error {
+	s.start(c, true)
+
+	t := time.NewTimer(s.timeout())
+	defer t.Stop()
+	select {
+	case st := <-s.bound:
+		if st == StatusOK {
+			return nil
+		}
+		s.close(st)
+		return st
+	case <-t.C:
+		s.close(ErrTimeout)
+		return ErrTimeout
+	case <-ctx.Done():
+		s.close(ctx.Err())
+		return ctx.Err()
+	case <-s.done:
+		return s.Err()
+	}
+}
+
+func (s *Session) start(c net.Conn, server bool) {
+	s.conn = c
+	s.server = server
+	s.slots = make(chan struct{}, s.window())
+	s.done = make(chan struct{})
+	s.bound = make(chan Status, 1)
+	s.pending = make(map[uint32]chan answer)
+	s.lastRx = time.Now()
+	go s.serve()
+	go s.keepalive()
+}
+
+// Unbind waits outstanding requests and unbinds the session
+func (s *Session) Unbind(ctx context.Context) error {
+	s.mutex.Lock()
+	if s.state != SessionBound {
+		s.mutex.Unlock()
+		return ErrNotBound
+	}
+	s.state = SessionUnbinding
+	s.mutex.Unlock()
+
+	w := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		s.handling.Wait()
+		close(w)
+	}()
+	select {
+	case <-w:
+	case <-ctx.Done():
+		s.close(ctx.Err())
+		return ctx.Err()
+	case <-s.done:
+		return ErrSessionClosed
+	}
+
+	_, e := s.request(ctx, Unbind{}, false)
+	s.close(e)
+	return e
+}
+
+// Close closes the session without unbind
+func (s *Session) Close() error {
+	s.close(ErrSessionClosed)
+	return nil
+}
+
+func (s *Session) close(e error) {
+	s.mutex.Lock()
+	if s.state == SessionClosed {
+		s.mutex.Unlock()
+		return
+	}
+	s.state = SessionClosed
+	s.err = e
+	s.mutex.Unlock()
+
+	s.conn.Close()
+	close(s.done)
+}
+
+// Send sends request PDU p and returns the response.
+// Request rejected by throttling error is retried after ThrottleWait.
+// Status is returned as error if the response has error status.
+func (s *Session) Send(ctx context.Context, p PDU) (r PDU, e error) {
+	for i := 0; ; i++ {
+		r, e = s.request(ctx, p, true)
+		if e != StatusThrottled || s.throttleRetry() < 0 || i >= s.throttleRetry() {
+			return
+		}
+		t := time.NewTimer(s.throttleWait())
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-s.done:
+			t.Stop()
+			return nil, ErrSessionClosed
+		}
+	}
+}
+
+// TranspReq sends Submit on client side or Deliver on server side.
+// SubmitReport is returned for accepted Submit.
+func (s *Session) TranspReq(p sms.TPDU) (sms.TPDU, error) {
+	return s.TranspReqContext(context.Background(), p)
+}
+
+// TranspReqContext is TranspReq with context
+func (s *Session) TranspReqContext(ctx context.Context, p sms.TPDU) (sms.TPDU, error) {
+	switch v := p.(type) {
+	case sms.Submit:
+		r, e := NewSubmitSM(v)
+		if e != nil {
+			return nil, e
+		}
+		if _, e = s.Send(ctx, r); e != nil {
+			return nil, e
+		}
+		ts, _ := sms.TimeToSCTimeStamp(time.Now())
+		return sms.SubmitReport{SCTS: ts}, nil
+	case sms.Deliver:
+		r, e := NewDeliverSM(v)
+		if e != nil {
+			return nil, e
+		}
+		_, e = s.Send(ctx, r)
+		return nil, e
+	}
+	return nil, ErrUnsupportedTPDU
+}
+
+// allowed reports the message PDU p can be sent to the peer
+func (s *Session) allowed(p PDU) bool {
+	switch p.(type) {
+	case SubmitSM, QuerySM, CancelSM, ReplaceSM:
+		return !s.server && s.bindType.CanSend()
+	case DeliverSM:
+		return s.server && s.bindType.CanReceive()
+	case DataSM:
+		if s.server {
+			return s.bindType.CanReceive()
+		}
+		return s.bindType.CanSend()
+	}
+	return true
+}
+
+// request sends p and waits the response.
+// Message request uses a slot of the window, and
+// it is allowed only in bound state.
+func (s *Session) request(ctx context.Context, p PDU, msg bool) (PDU, error) {
+	if msg {
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.done:
+			return nil, ErrSessionClosed
+		}
+		defer func() { <-s.slots }()
+	}
+
+	s.mutex.Lock()
+	switch {
+	case s.state == SessionClosed:
+		s.mutex.Unlock()
+		return nil, ErrSessionClosed
+	case msg && s.state != SessionBound:
+		s.mutex.Unlock()
+		return nil, ErrNotBound
+	case msg && !s.allowed(p):
+		s.mutex.Unlock()
+		return nil, StatusInvBndSts
+	}
+	if msg {
+		s.active.Add(1)
+		defer s.active.Done()
+	}
+	s.seq = s.seq%0x7fffffff + 1
+	seq := s.seq
+	c := make(chan answer, 1)
+	s.pending[seq] = c
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.pending, seq)
+		s.mutex.Unlock()
+	}()
+
+	if e := s.write(p, StatusOK, seq); e != nil {
+		return nil, e
+	}
+	t := time.NewTimer(s.timeout())
+	defer t.Stop()
+	var a answer
+	select {
+	case a = <-c:
+	case <-t.C:
+		return nil, ErrTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.done:
+		// the answer may be received just before close
+		select {
+		case a = <-c:
+		default:
+			return nil, ErrSessionClosed
+		}
+	}
+	if a.h.Status != StatusOK {
+		return a.p, a.h.Status
+	}
+	return a.p, nil
+}
+
+func (s *Session) write(p PDU, st Status, seq uint32) error {
+	b, e := Marshal(p, st, seq)
+	if e != nil && p.CommandID().IsResp() {
+		// answer error without the broken body
+		if st == StatusOK {
+			st = StatusSysErr
+		}
+		b, e = Marshal(errorResp(p.CommandID()), st, seq)
+	}
+	if e != nil {
+		return e
+	}
+	s.wmutex.Lock()
+	defer s.wmutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+	_, e = s.conn.Write(b)
+	return e
+}
+
+// serve receives PDU until the connection is closed
+func (s *Session) serve() {
+	for {
+		b, e := Read(s.conn)
+		if e != nil {
+			s.mutex.Lock()
+			if s.unbound {
+				e = nil
+			}
+			s.mutex.Unlock()
+			s.close(e)
+			return
+		}
+		s.mutex.Lock()
+		s.lastRx = time.Now()
+		s.mutex.Unlock()
+
+		p, h, e := Unmarshal(b)
+		switch {
+		case h.ID.IsResp():
+			if e != nil && h.Status == StatusOK {
+				h.Status = StatusSysErr
+			}
+			s.mutex.Lock()
+			// bound before the request from peer that follows the response
+			if t := bindType(h.ID &^ 0x80000000); t != 0 && !s.server &&
+				h.Status == StatusOK && s.state == SessionOpen {
+				s.state = SessionBound
+				s.bindType = t
+			}
+			if h.ID == UnbindRespID && h.Status == StatusOK {
+				s.unbound = true
+			}
+			if c, ok := s.pending[h.Seq]; ok {
+				select {
+				case c <- answer{p: p, h: h}:
+				default:
+				}
+			}
+			s.mutex.Unlock()
+		case errors.As(e, &DecodeError{}):
+			// known command that has broken body
+			s.write(respOf(p), statusOfDecodeError(e), h.Seq)
+		case e != nil:
+			st := StatusInvCmdLen
+			if errors.As(e, &UnknownCommandError{}) {
+				st = StatusInvCmdID
+			}
+			s.write(GenericNack{}, st, h.Seq)
+		default:
+			s.indicate(p, h.Seq)
+		}
+	}
+}
+
+// indicate handles request p from peer
+func (s *Session) indicate(p PDU, seq uint32) {
+	switch v := p.(type) {
+	case EnquireLink:
+		s.write(EnquireLinkResp{}, StatusOK, seq)
+		return
+	case Unbind:
+		// no more request is accepted before waiting outstanding requests
+		s.mutex.Lock()
+		s.unbound = true
+		if s.state == SessionBound {
+			s.state = SessionUnbinding
+		}
+		s.mutex.Unlock()
+		go s.unbind(seq)
+		return
+	case Bind:
+		s.bind(v, seq)
+		return
+	}
+
+	s.mutex.Lock()
+	switch {
+	case s.state != SessionBound:
+		s.mutex.Unlock()
+		s.write(respOf(p), StatusInvBndSts, seq)
+		return
+	case s.incoming >= s.window():
+		s.mutex.Unlock()
+		s.write(respOf(p), StatusThrottled, seq)
+		return
+	}
+	s.incoming++
+	s.handling.Add(1)
+	s.mutex.Unlock()
+
+	go func() {
+		r, st := s.handle(p)
+		s.write(r, st, seq)
+
+		s.mutex.Lock()
+		s.incoming--
+		s.mutex.Unlock()
+		s.handling.Done()
+	}()
+}
+
+// bind handles bind request on server side
+func (s *Session) bind(p Bind, seq uint32) {
+	r := BindResp{Type: p.Type, SystemID: s.SystemID}
+	s.mutex.Lock()
+	if !s.server || s.state != SessionOpen {
+		st := StatusAlyBnd
+		if !s.server || s.state != SessionBound {
+			st = StatusInvBndSts
+		}
+		s.mutex.Unlock()
+		s.write(r, st, seq)
+		return
+	}
+	s.mutex.Unlock()
+
+	st := StatusOK
+	if s.Authenticate != nil {
+		st = s.Authenticate(p)
+	}
+	if st == StatusOK {
+		r.TLVs = r.TLVs.SetUint8(TagSCInterfaceVersion, InterfaceVersion)
+		s.mutex.Lock()
+		s.state = SessionBound
+		s.bindType = p.Type
+		s.mutex.Unlock()
+	}
+	s.write(r, st, seq)
+	select {
+	case s.bound <- st:
+	default:
+	}
+}
+
+// unbind handles unbind request after outstanding incoming requests
+func (s *Session) unbind(seq uint32) {
+	s.handling.Wait()
+	s.write(UnbindResp{}, StatusOK, seq)
+	s.close(nil)
+}
+
+// handle returns response of message request p
+func (s *Session) handle(p PDU) (PDU, Status) {
+	switch v := p.(type) {
+	case SubmitSM:
+		if !s.server {
+			return SubmitSMResp{}, StatusInvBndSts
+		}
+		sm, e := v.ToSubmit(s.ConcatRef)
+		if e != nil {
+			return SubmitSMResp{}, statusOfError(e, StatusSubmitFail)
+		}
+		for _, t := range sm {
+			if st := s.transpInd(t, StatusSubmitFail); st != StatusOK {
+				return SubmitSMResp{}, st
+			}
+		}
+		s.mutex.Lock()
+		s.msgID++
+		id := strconv.FormatUint(s.msgID, 16)
+		s.mutex.Unlock()
+		return SubmitSMResp{MessageID: id}, StatusOK
+	case DeliverSM:
+		if s.server {
+			return DeliverSMResp{}, StatusInvBndSts
+		}
+		dm, e := v.ToDeliver(s.ConcatRef)
+		if e != nil {
+			return DeliverSMResp{}, statusOfError(e, StatusDeliveryFailure)
+		}
+		for _, t := range dm {
+			if st := s.transpInd(t, StatusDeliveryFailure); st != StatusOK {
+				return DeliverSMResp{}, st
+			}
+		}
+		return DeliverSMResp{}, StatusOK
+	}
+	if s.RequestInd != nil {
+		return s.RequestInd(p)
+	}
+	return GenericNack{}, StatusInvCmdID
+}
+
+// transpInd indicates TPDU t and returns the status of the answer,
+// f is the status of failure that is not Status
+func (s *Session) transpInd(t sms.TPDU, f Status) Status {
+	if s.TranspInd == nil {
+		return f
+	}
+	a, e := s.TranspInd(t)
+	if e != nil {
+		return statusOfError(e, f)
+	}
+	switch v := a.(type) {
+	case sms.SubmitReport:
+		if v.FCS&0x80 == 0x80 {
+			return f
+		}
+	case sms.DeliverReport:
+		if v.FCS&0x80 == 0x80 {
+			return f
+		}
+	}
+	return StatusOK
+}
+
+// statusOfError returns Status of error e,
+// f is the status of error that is not Status
+func statusOfError(e error, f Status) Status {
+	var st Status
+	if errors.As(e, &st) {
+		return st
+	}
+	var dc UnknownDataCodingError
+	if errors.As(e, &dc) {
+		return StatusInvDCS
+	}
+	return f
+}
+
+// statusOfDecodeError returns Status of broken PDU body
+func statusOfDecodeError(e error) Status {
+	switch {
+	case errors.Is(e, ErrInvalidTLV):
+		return StatusInvOptParStream
+	case errors.Is(e, ErrInvalidLength):
+		return StatusInvParLen
+	}
+	return StatusInvCmdLen
+}
+
+// respOf returns empty response of request p
+func respOf(p PDU) PDU {
+	switch v := p.(type) {
+	case Bind:
+		return BindResp{Type: v.Type}
+	case SubmitSM:
+		return SubmitSMResp{}
+	case DeliverSM:
+		return DeliverSMResp{}
+	case DataSM:
+		return DataSMResp{}
+	case QuerySM:
+		return QuerySMResp{}
+	case CancelSM:
+		return CancelSMResp{}
+	case ReplaceSM:
+		return ReplaceSMResp{}
+	case Unbind:
+		return UnbindResp{}
+	case EnquireLink:
+		return EnquireLinkResp{}
+	}
+	return GenericNack{}
+}
+
+// errorResp is response PDU that has no body
+type errorResp CommandID
+
+func (r errorResp) CommandID() CommandID { return CommandID(r) }
+
+func (errorResp) appendBody(*writer) {}
+
+// keepalive sends enquire_link when the session is idle for
+// EnquireInterval, and closes the session if no response
+func (s *Session) keepalive() {
+	d := s.enquireInterval()
+	if d <= 0 {
+		return
+	}
+	for {
+		s.mutex.Lock()
+		w := time.Until(s.lastRx.Add(d))
+		s.mutex.Unlock()
+
+		if w <= 0 {
+			_, e := s.request(context.Background(), EnquireLink{}, false)
+			var st Status
+			if e != nil && !errors.As(e, &st) {
+				if e == ErrTimeout {
+					s.close(e)
+				}
+				return
+			}
+			continue
+		}
+
+		t := time.NewTimer(w)
+		select {
+		case <-t.C:
+		case <-s.done:
+			t.Stop()
+			return
+		}
+	}
+}
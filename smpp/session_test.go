@@ -0,0 +1,418 @@
+package smpp_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+	"github.com/fkgi/sms/smpp"
+)
+
+// connect binds client to server over local TCP listener
+func connect(t *testing.T, server, client *smpp.Session) error {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		c, e := l.Accept()
+		if e == nil {
+			e = server.Accept(context.Background(), c)
+		}
+		accepted <- e
+	}()
+
+	c, e := net.Dial("tcp", l.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = client.Open(context.Background(), c)
+	if ae := <-accepted; e == nil {
+		e = ae
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return e
+}
+
+func TestSessionSubmit(t *testing.T) {
+	rx := make(chan sms.Submit, 10)
+	server := &smpp.Session{SystemID: "smsc",
+		TranspInd: func(p sms.TPDU) (sms.TPDU, error) {
+			rx <- p.(sms.Submit)
+			return nil, nil
+		}}
+	client := &smpp.Session{Bind: smpp.Bind{
+		Type: smpp.Transmitter, SystemID: "esme"}}
+	if e := connect(t, server, client); e != nil {
+		t.Fatal(e)
+	}
+	if client.State() != smpp.SessionBound || server.State() != smpp.SessionBound ||
+		server.BindType() != smpp.Transmitter {
+		t.Fatalf("unexpected state %s %s", client.State(), server.State())
+	}
+
+	da, _ := sms.ParseAddress("+819012345678")
+	orig := sms.Submit{TMR: 9, DA: da, SRR: true,
+		UD: sms.UserData{Text: "hello"}}
+	a, e := client.TranspReq(orig)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, ok := a.(sms.SubmitReport); !ok {
+		t.Fatalf("unexpected answer %T", a)
+	}
+	s := <-rx
+	if !s.DA.Equal(da) || s.TMR != 9 || !s.SRR || s.UD.Text != "hello" {
+		t.Fatalf("unexpected %s", s)
+	}
+
+	// long message_payload is segmented
+	p := smpp.SubmitSM{}
+	p.Dest.TON, p.Dest.NPI, p.Dest.Addr = 1, 1, "819012345678"
+	p.TLVs = p.TLVs.Set(smpp.TagMessagePayload, make([]byte, 300))
+	r, e := client.Send(context.Background(), p)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if r.(smpp.SubmitSMResp).MessageID == "" {
+		t.Fatal("no message_id")
+	}
+	for i := 0; i < 2; i++ {
+		if s = <-rx; len(s.UD.UDH) != 1 {
+			t.Fatalf("unexpected segment %s", s)
+		}
+	}
+
+	// transmitter can't receive deliver_sm
+	if _, e = server.TranspReq(sms.Deliver{OA: da}); e != smpp.StatusInvBndSts {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
+
+func TestSessionDeliver(t *testing.T) {
+	oa, _ := sms.ParseAddress("+819012345678")
+	server := &smpp.Session{}
+	client := &smpp.Session{Bind: smpp.Bind{Type: smpp.Receiver},
+		TranspInd: func(p sms.TPDU) (sms.TPDU, error) {
+			d := p.(sms.Deliver)
+			if !d.OA.Equal(oa) {
+				return nil, smpp.StatusInvSrcAdr
+			}
+			if d.UD.Text == "full" {
+				return sms.DeliverReport{FCS: 0xd3}, nil
+			}
+			return nil, nil
+		}}
+	if e := connect(t, server, client); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, c := range []struct {
+		d  sms.Deliver
+		st error
+	}{
+		{sms.Deliver{OA: oa, UD: sms.UserData{Text: "ok"}}, nil},
+		{sms.Deliver{OA: oa, UD: sms.UserData{Text: "full"}}, smpp.StatusDeliveryFailure},
+		{sms.Deliver{UD: sms.UserData{Text: "ok"}}, smpp.StatusInvSrcAdr},
+	} {
+		if _, e := server.TranspReq(c.d); e != c.st {
+			t.Fatalf("%s: unexpected error %v", c.d.UD.Text, e)
+		}
+	}
+
+	// receiver can't send submit_sm
+	if _, e := client.TranspReq(sms.Submit{DA: oa}); e != smpp.StatusInvBndSts {
+		t.Fatalf("unexpected error %v", e)
+	}
+	// request that has no handler
+	if _, e := server.Send(context.Background(), smpp.DataSM{}); e != smpp.StatusInvCmdID {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
+
+func TestSessionBindFailure(t *testing.T) {
+	server := &smpp.Session{Authenticate: func(b smpp.Bind) smpp.Status {
+		if b.Password != "secret" {
+			return smpp.StatusInvPaswd
+		}
+		return smpp.StatusOK
+	}}
+	client := &smpp.Session{Bind: smpp.Bind{SystemID: "esme", Password: "wrong"}}
+	if e := connect(t, server, client); e != smpp.StatusInvPaswd {
+		t.Fatalf("unexpected error %v", e)
+	}
+	<-client.Done()
+	<-server.Done()
+	if _, e := client.Send(context.Background(), smpp.SubmitSM{}); e != smpp.ErrSessionClosed {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
+
+// blocker is TranspInd that waits release and counts concurrent call
+type blocker struct {
+	mutex   sync.Mutex
+	current int
+	max     int
+	calls   int
+	release chan struct{}
+}
+
+func (b *blocker) transpInd(sms.TPDU) (sms.TPDU, error) {
+	b.mutex.Lock()
+	b.current++
+	b.calls++
+	if b.current > b.max {
+		b.max = b.current
+	}
+	b.mutex.Unlock()
+
+	<-b.release
+
+	b.mutex.Lock()
+	b.current--
+	b.mutex.Unlock()
+	return nil, nil
+}
+
+func sendParallel(client *smpp.Session, n int) chan error {
+	r := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			p := smpp.SubmitSM{}
+			p.ShortMessage = []byte("test")
+			_, e := client.Send(context.Background(), p)
+			r <- e
+		}()
+	}
+	return r
+}
+
+func TestSessionWindow(t *testing.T) {
+	b := &blocker{release: make(chan struct{})}
+	server := &smpp.Session{TranspInd: b.transpInd}
+	client := &smpp.Session{Window: 2}
+	if e := connect(t, server, client); e != nil {
+		t.Fatal(e)
+	}
+
+	r := sendParallel(client, 6)
+	time.Sleep(100 * time.Millisecond)
+	b.mutex.Lock()
+	if b.current != 2 {
+		t.Fatalf("unexpected %d outstanding requests", b.current)
+	}
+	b.mutex.Unlock()
+	close(b.release)
+	for i := 0; i < 6; i++ {
+		if e := <-r; e != nil {
+			t.Fatal(e)
+		}
+	}
+	if b.max != 2 {
+		t.Fatalf("unexpected %d outstanding requests", b.max)
+	}
+}
+
+func TestSessionThrottling(t *testing.T) {
+	b := &blocker{release: make(chan struct{})}
+	server := &smpp.Session{Window: 1, TranspInd: b.transpInd}
+	client := &smpp.Session{ThrottleWait: 20 * time.Millisecond, ThrottleRetry: -1}
+	if e := connect(t, server, client); e != nil {
+		t.Fatal(e)
+	}
+
+	// without retry
+	r := sendParallel(client, 3)
+	for i := 0; i < 2; i++ {
+		if e := <-r; e != smpp.StatusThrottled {
+			t.Fatalf("unexpected error %v", e)
+		}
+	}
+	b.release <- struct{}{}
+	if e := <-r; e != nil {
+		t.Fatal(e)
+	}
+
+	// with retry
+	client.ThrottleRetry = 100
+	r = sendParallel(client, 3)
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(30 * time.Millisecond)
+			b.release <- struct{}{}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		if e := <-r; e != nil {
+			t.Fatal(e)
+		}
+	}
+	if b.max != 1 || b.calls != 4 {
+		t.Fatalf("unexpected %d outstanding requests in %d", b.max, b.calls)
+	}
+}
+
+func TestSessionEnquireLink(t *testing.T) {
+	server := &smpp.Session{EnquireInterval: 20 * time.Millisecond}
+	client := &smpp.Session{EnquireInterval: 20 * time.Millisecond}
+	if e := connect(t, server, client); e != nil {
+		t.Fatal(e)
+	}
+	select {
+	case <-client.Done():
+		t.Fatalf("unexpected close %v", client.Err())
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// peer that answers only bind
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+	go func() {
+		c, e := l.Accept()
+		if e != nil {
+			return
+		}
+		defer c.Close()
+		b, e := smpp.Read(c)
+		if e != nil {
+			return
+		}
+		p, h, _ := smpp.Unmarshal(b)
+		b, _ = smpp.Marshal(smpp.BindResp{Type: p.(smpp.Bind).Type}, smpp.StatusOK, h.Seq)
+		c.Write(b)
+		for {
+			if _, e := smpp.Read(c); e != nil {
+				return
+			}
+		}
+	}()
+	c, e := net.Dial("tcp", l.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	client = &smpp.Session{
+		EnquireInterval: 20 * time.Millisecond, Timeout: 50 * time.Millisecond}
+	if e = client.Open(context.Background(), c); e != nil {
+		t.Fatal(e)
+	}
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("dead peer is not detected")
+	}
+	if client.Err() != smpp.ErrTimeout {
+		t.Fatalf("unexpected error %v", client.Err())
+	}
+}
+
+func TestSessionUnbind(t *testing.T) {
+	b := &blocker{release: make(chan struct{})}
+	server := &smpp.Session{TranspInd: b.transpInd}
+	client := &smpp.Session{}
+	if e := connect(t, server, client); e != nil {
+		t.Fatal(e)
+	}
+
+	r := sendParallel(client, 2)
+	time.Sleep(50 * time.Millisecond)
+	unbound := make(chan error, 1)
+	go func() {
+		unbound <- client.Unbind(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if client.State() != smpp.SessionUnbinding {
+		t.Fatalf("unexpected state %s", client.State())
+	}
+	if _, e := client.Send(context.Background(), smpp.SubmitSM{}); e != smpp.ErrNotBound {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	close(b.release)
+	for i := 0; i < 2; i++ {
+		if e := <-r; e != nil {
+			t.Fatal(e)
+		}
+	}
+	if e := <-unbound; e != nil {
+		t.Fatal(e)
+	}
+	<-client.Done()
+	<-server.Done()
+	if client.Err() != nil || server.Err() != nil {
+		t.Fatalf("unexpected error %v %v", client.Err(), server.Err())
+	}
+}
+
+func TestSessionBrokenRequest(t *testing.T) {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+	server := &smpp.Session{}
+	go func() {
+		if c, e := l.Accept(); e == nil {
+			server.Accept(context.Background(), c)
+		}
+	}()
+	t.Cleanup(func() { server.Close() })
+
+	c, e := net.Dial("tcp", l.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer c.Close()
+	exchange := func(b []byte) (smpp.PDU, smpp.Header) {
+		c.Write(b)
+		r, e := smpp.Read(c)
+		if e != nil {
+			t.Fatal(e)
+		}
+		p, h, _ := smpp.Unmarshal(r)
+		return p, h
+	}
+	b, _ := smpp.Marshal(smpp.Bind{Type: smpp.Transceiver}, smpp.StatusOK, 1)
+	if _, h := exchange(b); h.Status != smpp.StatusOK {
+		t.Fatalf("bind failed %s", h.Status)
+	}
+
+	for _, c := range []struct {
+		tail []byte
+		id   smpp.CommandID
+		st   smpp.Status
+	}{
+		{[]byte{0x04, 0x24, 0x00}, smpp.SubmitSMRespID, smpp.StatusInvOptParStream},
+		{nil, smpp.SubmitSMRespID, smpp.StatusInvCmdLen},
+	} {
+		p := smpp.SubmitSM{}
+		p.ShortMessage = []byte("test")
+		b, _ = smpp.Marshal(p, smpp.StatusOK, 2)
+		if c.tail == nil {
+			b = b[:len(b)-3]
+		} else {
+			b = append(b, c.tail...)
+		}
+		b[3] = byte(len(b))
+		if _, h := exchange(b); h.ID != c.id || h.Status != c.st || h.Seq != 2 {
+			t.Fatalf("unexpected response %+v", h)
+		}
+	}
+
+	b, _ = smpp.Marshal(smpp.EnquireLink{}, smpp.StatusOK, 3)
+	b[7] = 0x99
+	if _, h := exchange(b); h.ID != smpp.GenericNackID || h.Status != smpp.StatusInvCmdID {
+		t.Fatalf("unexpected response %+v", h)
+	}
+}
@@ -0,0 +1,84 @@
+package smpp
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ParseTime parse SMPP time format "YYMMDDhhmmsstnnp".
+// Relative time ("R" suffix) is added to the reference time ref.
+func ParseTime(s string, ref time.Time) (t time.Time, relative bool, e error) {
+	if len(s) != 16 {
+		e = InvalidTimeError{Value: s}
+		return
+	}
+	var v [7]int
+	for i := range v {
+		if v[i], e = strconv.Atoi(s[i*2 : i*2+2]); e != nil || v[i] < 0 {
+			e = InvalidTimeError{Value: s}
+			return
+		}
+	}
+	if s[14] < '0' || s[14] > '9' {
+		e = InvalidTimeError{Value: s}
+		return
+	}
+	tenth := v[6] / 10
+	nn := v[6]%10*10 + int(s[14]-'0')
+
+	switch s[15] {
+	case 'R':
+		relative = true
+		t = ref.AddDate(v[0], v[1], v[2]).Add(
+			time.Duration(v[3])*time.Hour +
+				time.Duration(v[4])*time.Minute +
+				time.Duration(v[5])*time.Second)
+	case '+', '-':
+		if v[1] < 1 || v[1] > 12 || v[2] < 1 || v[2] > 31 ||
+			v[3] > 23 || v[4] > 59 || v[5] > 59 || nn > 48 {
+			e = InvalidTimeError{Value: s}
+			return
+		}
+		z := nn * 15 * 60
+		if s[15] == '-' {
+			z = -z
+		}
+		t = time.Date(2000+v[0], time.Month(v[1]), v[2], v[3], v[4], v[5],
+			tenth*int(time.Second/10), time.FixedZone("", z))
+	default:
+		e = InvalidTimeError{Value: s}
+	}
+	return
+}
+
+// FormatTime make SMPP absolute time format text
+func FormatTime(t time.Time) string {
+	_, z := t.Zone()
+	p := '+'
+	if z < 0 {
+		p = '-'
+		z = -z
+	}
+	return fmt.Sprintf("%02d%02d%02d%02d%02d%02d%d%02d%c",
+		t.Year()%100, int(t.Month()), t.Day(),
+		t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond()/int(time.Second/10), z/900, p)
+}
+
+// FormatRelativeTime make SMPP relative time format text.
+// Days are carried to years by 365 days.
+func FormatRelativeTime(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	s := int64(d / time.Second)
+	days := s / 86400
+	y := days / 365
+	if y > 99 {
+		y = 99
+		days = 364
+	}
+	return fmt.Sprintf("%02d%02d%02d%02d%02d%02d000R",
+		y, 0, days%365, s%86400/3600, s%3600/60, s%60)
+}
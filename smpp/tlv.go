@@ -0,0 +1,170 @@
+package smpp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Tag is tag of optional parameter TLV
+type Tag uint16
+
+const (
+	// TagDestAddrSubunit is dest_addr_subunit
+	TagDestAddrSubunit Tag = 0x0005
+	// TagPayloadType is payload_type
+	TagPayloadType Tag = 0x0019
+	// TagReceiptedMessageID is receipted_message_id
+	TagReceiptedMessageID Tag = 0x001E
+	// TagUserMessageReference is user_message_reference
+	TagUserMessageReference Tag = 0x0204
+	// TagSourcePort is source_port
+	TagSourcePort Tag = 0x020A
+	// TagDestinationPort is destination_port
+	TagDestinationPort Tag = 0x020B
+	// TagSARMsgRefNum is sar_msg_ref_num
+	TagSARMsgRefNum Tag = 0x020C
+	// TagSARTotalSegments is sar_total_segments
+	TagSARTotalSegments Tag = 0x020E
+	// TagSARSegmentSeqnum is sar_segment_seqnum
+	TagSARSegmentSeqnum Tag = 0x020F
+	// TagSCInterfaceVersion is sc_interface_version
+	TagSCInterfaceVersion Tag = 0x0210
+	// TagNetworkErrorCode is network_error_code
+	TagNetworkErrorCode Tag = 0x0423
+	// TagMessagePayload is message_payload
+	TagMessagePayload Tag = 0x0424
+	// TagDeliveryFailureReason is delivery_failure_reason
+	TagDeliveryFailureReason Tag = 0x0425
+	// TagMoreMessagesToSend is more_messages_to_send
+	TagMoreMessagesToSend Tag = 0x0426
+	// TagMessageState is message_state
+	TagMessageState Tag = 0x0427
+)
+
+func (t Tag) String() string {
+	switch t {
+	case TagDestAddrSubunit:
+		return "dest_addr_subunit"
+	case TagPayloadType:
+		return "payload_type"
+	case TagReceiptedMessageID:
+		return "receipted_message_id"
+	case TagUserMessageReference:
+		return "user_message_reference"
+	case TagSourcePort:
+		return "source_port"
+	case TagDestinationPort:
+		return "destination_port"
+	case TagSARMsgRefNum:
+		return "sar_msg_ref_num"
+	case TagSARTotalSegments:
+		return "sar_total_segments"
+	case TagSARSegmentSeqnum:
+		return "sar_segment_seqnum"
+	case TagSCInterfaceVersion:
+		return "sc_interface_version"
+	case TagNetworkErrorCode:
+		return "network_error_code"
+	case TagMessagePayload:
+		return "message_payload"
+	case TagDeliveryFailureReason:
+		return "delivery_failure_reason"
+	case TagMoreMessagesToSend:
+		return "more_messages_to_send"
+	case TagMessageState:
+		return "message_state"
+	}
+	return fmt.Sprintf("tag(%04x)", uint16(t))
+}
+
+// TLV is optional parameter of SMPP PDU
+type TLV struct {
+	Tag   Tag
+	Value []byte
+}
+
+func (t TLV) String() string {
+	return fmt.Sprintf("%s=% x", t.Tag, t.Value)
+}
+
+// TLVs is list of optional parameters
+type TLVs []TLV
+
+// Get returns value of tag t
+func (l TLVs) Get(t Tag) ([]byte, bool) {
+	for _, v := range l {
+		if v.Tag == t {
+			return v.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Uint returns integer value of tag t.
+// ok is false if the tag is not present or the length is not 1, 2 or 4.
+func (l TLVs) Uint(t Tag) (i uint32, ok bool) {
+	v, ok := l.Get(t)
+	if !ok {
+		return
+	}
+	switch len(v) {
+	case 1:
+		i = uint32(v[0])
+	case 2:
+		i = uint32(binary.BigEndian.Uint16(v))
+	case 4:
+		i = binary.BigEndian.Uint32(v)
+	default:
+		ok = false
+	}
+	return
+}
+
+// Set returns TLVs that the value of tag t is replaced or added
+func (l TLVs) Set(t Tag, v []byte) TLVs {
+	for i := range l {
+		if l[i].Tag == t {
+			l[i].Value = v
+			return l
+		}
+	}
+	return append(l, TLV{Tag: t, Value: v})
+}
+
+// SetUint8 returns TLVs that tag t has 1 octet integer value v
+func (l TLVs) SetUint8(t Tag, v uint8) TLVs {
+	return l.Set(t, []byte{v})
+}
+
+// SetUint16 returns TLVs that tag t has 2 octets integer value v
+func (l TLVs) SetUint16(t Tag, v uint16) TLVs {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return l.Set(t, b)
+}
+
+// Delete returns TLVs without tag t
+func (l TLVs) Delete(t Tag) (r TLVs) {
+	for _, v := range l {
+		if v.Tag != t {
+			r = append(r, v)
+		}
+	}
+	return
+}
+
+// readTLVs reads TLVs until end of the body
+func readTLVs(r *reader) (l TLVs, e error) {
+	for r.Len() != 0 {
+		if r.Len() < 4 {
+			return nil, ErrInvalidTLV
+		}
+		h := r.Next(4)
+		t := TLV{Tag: Tag(binary.BigEndian.Uint16(h))}
+		if t.Value, e = r.octets(int(binary.BigEndian.Uint16(h[2:]))); e != nil {
+			return nil, ErrInvalidTLV
+		}
+		l = append(l, t)
+	}
+	return
+}
@@ -1,72 +1,155 @@
 package sms
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
 
 var (
-	// TR1M timer waiting time
+	// TR1M timer waiting time for RP-ACK
 	TR1M = time.Duration(35 * time.Second)
+	// TR2M timer waiting time to send RP-ACK
+	TR2M = time.Duration(15 * time.Second)
+	// TRAM timer waiting time to retransmit RP-SMMA
+	TRAM = time.Duration(30 * time.Second)
 )
 
-// SMR is SM-RP handler
+// RLState is state of SM-RL transaction
+type RLState byte
+
+const (
+	// RLIdle is Idle state
+	RLIdle RLState = iota
+	// RLWaitForRPAck is Wait for RP-ACK state
+	RLWaitForRPAck
+	// RLWaitToSendRPAck is Wait to send RP-ACK state
+	RLWaitToSendRPAck
+	// RLWaitForRetransTimer is Wait for RETRANS Timer state
+	RLWaitForRetransTimer
+)
+
+func (s RLState) String() string {
+	switch s {
+	case RLIdle:
+		return "Idle"
+	case RLWaitForRPAck:
+		return "Wait for RP-ACK"
+	case RLWaitToSendRPAck:
+		return "Wait to send RP-ACK"
+	case RLWaitForRetransTimer:
+		return "Wait for RETRANS Timer"
+	}
+	return fmt.Sprintf("Unknown(%d)", byte(s))
+}
+
+// SMR is SM-RL entity of MS or SC
 type SMR struct {
-	stack     [256]chan RPDU
 	SCAddress Address
+	// TR1M overrides TR1M timer value if it is not zero
+	TR1M time.Duration
+	// TR2M overrides TR2M timer value if it is not zero,
+	// it is used as TR2N on network side
+	TR2M time.Duration
+	// TRAM overrides TRAM timer value if it is not zero
+	TRAM time.Duration
+
+	RelayReq func(RPDU) error
+	// AbortReq is called with RP-MR of the transaction that is
+	// aborted by cancel of ctx, to release the connection of lower layer
+	// like CP-ERROR of SMC. It can be nil.
+	AbortReq  func(mr byte)
+	TranspInd func(TPDU) (TPDU, error)
+	// TranspIndContext is used instead of TranspInd if it is not nil,
+	// the context is canceled on TR2M expiry
+	TranspIndContext func(context.Context, TPDU) (TPDU, error)
+	MemAvailInd      func() error
 
-	RelayReq    func(RPDU) error
-	TranspInd   func(TPDU) (TPDU, error)
-	MemAvailInd func() error
+	mutex   sync.Mutex
+	tx      [256]*rlTransaction // transactions originated by this entity
+	rx      [256]*rlTransaction // transactions originated by peer
+	next    byte                // next RP-MR candidate
+	retrans bool                // RETRANS flag
 }
 
-// TranspReq handle TP-DATA
-func (smr SMR) TranspReq(r TPDU) (TPDU, error) {
-	if smr.RelayReq == nil {
-		return nil, RpError{CS: 97}
+type rlTransaction struct {
+	state  RLState
+	answer chan RPDU // RP-ACK or RP-ERROR from peer
+}
+
+func (smr *SMR) tr1m() time.Duration {
+	if smr.TR1M != 0 {
+		return smr.TR1M
 	}
+	return TR1M
+}
 
-	mr := -1
-	ch := make(chan RPDU)
-	for i, c := range smr.stack {
-		if c == nil {
-			mr = i
-			smr.stack[i] = ch
-			break
-		}
+func (smr *SMR) tr2m() time.Duration {
+	if smr.TR2M != 0 {
+		return smr.TR2M
 	}
-	if mr == -1 {
-		return nil, RpError{CS: 42}
+	return TR2M
+}
+
+func (smr *SMR) tram() time.Duration {
+	if smr.TRAM != 0 {
+		return smr.TRAM
 	}
+	return TRAM
+}
 
-	var e error
-	var isNW bool
-	switch v := r.(type) {
-	case Submit:
-		isNW = false
-		v.RMR = byte(mr)
-		v.SCA = smr.SCAddress
-		e = smr.RelayReq(v)
-	case Command:
-		isNW = false
-		v.RMR = byte(mr)
-		v.SCA = smr.SCAddress
-		e = smr.RelayReq(v)
-	case Deliver:
-		isNW = true
-		v.RMR = byte(mr)
-		v.SCA = smr.SCAddress
-		e = smr.RelayReq(v)
-	case StatusReport:
-		isNW = true
-		v.RMR = byte(mr)
-		v.SCA = smr.SCAddress
-		e = smr.RelayReq(v)
-	default:
-		e = RpError{CS: 97}
+// State returns state of the transaction mr.
+// peer is true for the transaction that is originated by peer.
+func (smr *SMR) State(mr byte, peer bool) RLState {
+	smr.mutex.Lock()
+	defer smr.mutex.Unlock()
+
+	t := smr.tx[mr]
+	if peer {
+		t = smr.rx[mr]
+	}
+	if t == nil {
+		return RLIdle
 	}
+	return t.state
+}
 
-	if e != nil {
-		smr.stack[mr] = nil
-		close(ch)
+// allocate returns new transaction and its RP-MR
+func (smr *SMR) allocate() (*rlTransaction, byte, bool) {
+	smr.mutex.Lock()
+	defer smr.mutex.Unlock()
+
+	for i := range smr.tx {
+		mr := smr.next + byte(i)
+		if smr.tx[mr] == nil {
+			t := &rlTransaction{answer: make(chan RPDU, 1)}
+			smr.tx[mr] = t
+			smr.next = mr + 1
+			return t, mr, true
+		}
+	}
+	return nil, 0, false
+}
+
+func (smr *SMR) release(mr byte) {
+	smr.mutex.Lock()
+	smr.tx[mr] = nil
+	smr.mutex.Unlock()
+}
 
+func (smr *SMR) setState(t *rlTransaction, s RLState) {
+	smr.mutex.Lock()
+	t.state = s
+	smr.mutex.Unlock()
+}
+
+// exchange send RPDU of RP-MR mr and wait the answer until
+// TR1M expiry or cancel of ctx
+func (smr *SMR) exchange(ctx context.Context, t *rlTransaction, mr byte, r RPDU) (RPDU, error) {
+	smr.setState(t, RLWaitForRPAck)
+	if e := smr.RelayReq(r); e != nil {
+		smr.setState(t, RLIdle)
 		switch e.(type) {
 		case RpError:
 			return nil, e
@@ -77,125 +160,317 @@ func (smr SMR) TranspReq(r TPDU) (TPDU, error) {
 		}
 	}
 
-	t := time.AfterFunc(TR1M, func() {
-		if isNW {
-			ch <- RpErrorMO{RMR: byte(mr), CS: 47}
+	var e error
+	tm := time.NewTimer(smr.tr1m())
+	defer tm.Stop()
+	select {
+	case a := <-t.answer:
+		return a, nil
+	case <-tm.C:
+		e = ErrTR1MExpired
+	case <-ctx.Done():
+		e = ctx.Err()
+	}
+
+	smr.mutex.Lock()
+	if t.state == RLWaitForRPAck {
+		t.state = RLIdle
+		smr.mutex.Unlock()
+		if e == ctx.Err() {
+			smr.abort(mr)
 		}
-		ch <- RpErrorMT{RMR: byte(mr), CS: 47}
-	})
-	a := <-ch
-	t.Stop()
-	smr.stack[mr] = nil
-	close(ch)
+		return nil, e
+	}
+	smr.mutex.Unlock()
+	return <-t.answer, nil
+}
+
+// abort requests lower layer to release the transaction mr
+func (smr *SMR) abort(mr byte) {
+	if smr.AbortReq != nil {
+		smr.AbortReq(mr)
+	}
+}
+
+// TranspReq send TPDU in RP-DATA and returns the answer.
+// ErrTR1MExpired is returned when no answer is received until TR1M expiry.
+func (smr *SMR) TranspReq(r TPDU) (TPDU, error) {
+	return smr.TranspReqContext(context.Background(), r)
+}
+
+// TranspReqContext is TranspReq that stops waiting the answer on cancel
+// of ctx and returns the error of ctx.
+// The transaction is released, AbortReq is called, and the late answer
+// is discarded.
+func (smr *SMR) TranspReqContext(ctx context.Context, r TPDU) (TPDU, error) {
+	if smr.RelayReq == nil {
+		return nil, RpError{CS: 97}
+	}
+
+	var isNW bool
+	switch r.(type) {
+	case Submit, Command:
+		isNW = false
+	case Deliver, StatusReport:
+		isNW = true
+	default:
+		return nil, RpError{CS: 97}
+	}
+
+	t, mr, ok := smr.allocate()
+	if !ok {
+		return nil, RpError{CS: 42}
+	}
+	defer smr.release(mr)
+
+	switch v := r.(type) {
+	case Submit:
+		v.RMR, v.SCA = mr, smr.SCAddress
+		r = v
+	case Command:
+		v.RMR, v.SCA = mr, smr.SCAddress
+		r = v
+	case Deliver:
+		v.RMR, v.SCA = mr, smr.SCAddress
+		r = v
+	case StatusReport:
+		v.RMR, v.SCA = mr, smr.SCAddress
+		r = v
+	}
+
+	a, e := smr.exchange(ctx, t, mr, r)
+	if e != nil {
+		return nil, e
+	}
 
 	switch v := a.(type) {
 	case DeliverReport:
-		if v.RMR != byte(mr) {
-			return nil, RpError{CS: 81}
-		}
 		if isNW {
 			return v, nil
 		}
 	case SubmitReport:
-		if v.RMR != byte(mr) {
-			return nil, RpError{CS: 81}
-		}
 		if !isNW {
 			return v, nil
 		}
-	case RpAckMT:
-		if v.RMR != byte(mr) {
-			return nil, RpError{CS: 81}
-		}
+	case RpAckMO:
 		if isNW {
 			return nil, nil
 		}
-	case RpAckMO:
-		if v.RMR != byte(mr) {
-			return nil, RpError{CS: 81}
-		}
+	case RpAckMT:
 		if !isNW {
 			return nil, nil
 		}
-	case RpErrorMT:
-		if v.RMR != byte(mr) {
-			return nil, RpError{CS: 81}
-		}
+	case RpErrorMO:
 		if isNW {
 			return nil, RpError(v)
 		}
-	case RpErrorMO:
-		if v.RMR != byte(mr) {
-			return nil, RpError{CS: 81}
-		}
+	case RpErrorMT:
 		if !isNW {
 			return nil, RpError(v)
 		}
 	}
-
 	return nil, RpError{CS: 95}
 }
 
-// RelayInd handle RP-DATA/SMMA
-func (smr SMR) RelayInd(r RPDU) (RPDU, error) {
-	var a TPDU
-	var e error
+// temporaryCause reports the RP-Cause c is temporary error
+// that RP-SMMA should be retransmitted
+func temporaryCause(c byte) bool {
+	switch c {
+	case 38, 41, 42, 47:
+		return true
+	}
+	return false
+}
+
+// MemAvailReq send RP-SMMA and wait RP-ACK.
+// RP-SMMA is retransmitted once after TRAM expiry when no answer is
+// received or RP-ERROR with temporary cause is received.
+func (smr *SMR) MemAvailReq() error {
+	return smr.MemAvailReqContext(context.Background())
+}
+
+// MemAvailReqContext is MemAvailReq that is stopped on cancel of ctx
+func (smr *SMR) MemAvailReqContext(ctx context.Context) error {
+	if smr.RelayReq == nil {
+		return RpError{CS: 97}
+	}
+
+	t, mr, ok := smr.allocate()
+	if !ok {
+		return RpError{CS: 42}
+	}
+	defer smr.release(mr)
+
+	for {
+		a, e := smr.exchange(ctx, t, mr, MemoryAvailable{RMR: mr})
+		switch v := a.(type) {
+		case RpAckMT:
+			e = nil
+		case RpErrorMT:
+			e = RpError(v)
+		case nil:
+		default:
+			e = RpError{CS: 95}
+		}
+
+		retry := e == ErrTR1MExpired
+		if re, ok := e.(RpError); ok && temporaryCause(re.CS) {
+			retry = true
+		}
+
+		smr.mutex.Lock()
+		if !retry || smr.retrans {
+			smr.retrans = false
+			smr.mutex.Unlock()
+			return e
+		}
+		smr.retrans = true
+		t.state = RLWaitForRetransTimer
+		smr.mutex.Unlock()
+
+		tm := time.NewTimer(smr.tram())
+		select {
+		case <-tm.C:
+		case <-ctx.Done():
+			tm.Stop()
+			smr.mutex.Lock()
+			smr.retrans = false
+			t.state = RLIdle
+			smr.mutex.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+type originatorKey struct{}
+
+// WithOriginator returns ctx that has originating address oa of
+// RP-DATA, like sm-RP-OA of MAP. MO RP-DATA has no RP-OA,
+// so lower layer of SC side provides the MS address by this.
+func WithOriginator(ctx context.Context, oa Address) context.Context {
+	return context.WithValue(ctx, originatorKey{}, oa)
+}
+
+// OriginatorFrom returns originating address of RP-DATA in ctx
+func OriginatorFrom(ctx context.Context) (Address, bool) {
+	oa, ok := ctx.Value(originatorKey{}).(Address)
+	return oa, ok
+}
+
+// RelayInd handle RPDU from peer. It returns the answer for RP-DATA and
+// RP-SMMA, or nil for RP-ACK and RP-ERROR that is the answer of
+// TranspReq and MemAvailReq.
+// The error is CpError when the RPDU can't be handled in SM-RL.
+func (smr *SMR) RelayInd(r RPDU) (RPDU, error) {
+	return smr.RelayIndContext(context.Background(), r)
+}
+
+// RelayIndContext is RelayInd that stops waiting the answer of
+// upper layer on cancel of ctx, and returns RP-ERROR with
+// temporary failure cause.
+// ctx is passed to TranspIndContext, so the originator of RP-DATA
+// that is set by WithOriginator is available in SM-TL.
+func (smr *SMR) RelayIndContext(ctx context.Context, r RPDU) (RPDU, error) {
 	var mr byte
 	var isNW bool
 
 	switch v := r.(type) {
 	case MemoryAvailable:
-		if smr.MemAvailInd == nil {
-			return nil, CpError{CS: 97}
-		}
-		mr = v.RMR
-		isNW = true
-		e = smr.MemAvailInd()
+		mr, isNW = v.RMR, true
 	case Submit:
-		if smr.TranspInd == nil {
-			return nil, CpError{CS: 97}
-		}
-		mr = v.RMR
-		isNW = true
-		a, e = smr.TranspInd(v)
+		mr, isNW = v.RMR, true
 	case Command:
-		if smr.TranspInd == nil {
-			return nil, CpError{CS: 97}
-		}
-		mr = v.RMR
-		isNW = true
-		a, e = smr.TranspInd(v)
+		mr, isNW = v.RMR, true
 	case Deliver:
-		if smr.TranspInd == nil {
-			return nil, CpError{CS: 97}
-		}
-		mr = v.RMR
-		isNW = false
-		a, e = smr.TranspInd(v)
+		mr, isNW = v.RMR, false
 	case StatusReport:
-		if smr.TranspInd == nil {
-			return nil, CpError{CS: 97}
-		}
-		mr = v.RMR
-		isNW = false
-		a, e = smr.TranspInd(v)
+		mr, isNW = v.RMR, false
+	case SubmitReport:
+		smr.answerInd(v.RMR, v)
+		return nil, nil
+	case DeliverReport:
+		smr.answerInd(v.RMR, v)
+		return nil, nil
+	case RpAckMO:
+		smr.answerInd(v.RMR, v)
+		return nil, nil
+	case RpAckMT:
+		smr.answerInd(v.RMR, v)
+		return nil, nil
+	case RpErrorMO:
+		smr.answerInd(v.RMR, v)
+		return nil, nil
+	case RpErrorMT:
+		smr.answerInd(v.RMR, v)
+		return nil, nil
 	default:
 		return nil, CpError{CS: 97}
 	}
 
-	switch v := e.(type) {
+	smr.mutex.Lock()
+	if smr.rx[mr] != nil {
+		smr.mutex.Unlock()
+		return rpError(RpError{RMR: mr, CS: 81}, isNW), nil
+	}
+	smr.rx[mr] = &rlTransaction{state: RLWaitToSendRPAck}
+	smr.mutex.Unlock()
+
+	defer func() {
+		smr.mutex.Lock()
+		smr.rx[mr] = nil
+		smr.mutex.Unlock()
+	}()
+
+	type result struct {
+		a TPDU
+		e error
+	}
+	ictx, cancel := context.WithTimeout(ctx, smr.tr2m())
+	defer cancel()
+	c := make(chan result, 1)
+	go func() {
+		var res result
+		switch v := r.(type) {
+		case MemoryAvailable:
+			if smr.MemAvailInd == nil {
+				res.e = CpError{CS: 97}
+			} else {
+				res.e = smr.MemAvailInd()
+			}
+		case TPDU:
+			if smr.TranspIndContext != nil {
+				res.a, res.e = smr.TranspIndContext(ictx, v)
+			} else if smr.TranspInd != nil {
+				res.a, res.e = smr.TranspInd(v)
+			} else {
+				res.e = CpError{CS: 97}
+			}
+		}
+		c <- res
+	}()
+
+	var res result
+	select {
+	case res = <-c:
+	case <-ictx.Done():
+		if ctx.Err() != nil {
+			return rpError(RpError{RMR: mr, CS: 41}, isNW), nil
+		}
+		return nil, CpError{CS: 111}
+	}
+
+	switch v := res.e.(type) {
 	case RpError:
 		v.RMR = mr
-		if isNW {
-			return RpErrorMT(v), nil
-		}
-		return RpErrorMO(v), nil
+		return rpError(v, isNW), nil
+	case CpError:
+		return nil, v
 	case nil:
 	default:
 		return nil, CpError{CS: 111}
 	}
 
-	switch v := a.(type) {
+	switch v := res.a.(type) {
 	case SubmitReport:
 		if isNW {
 			v.RMR = mr
@@ -215,3 +490,24 @@ func (smr SMR) RelayInd(r RPDU) (RPDU, error) {
 	}
 	return nil, CpError{CS: 111}
 }
+
+func rpError(e RpError, isNW bool) RPDU {
+	if isNW {
+		return RpErrorMT(e)
+	}
+	return RpErrorMO(e)
+}
+
+// answerInd pass the answer to the waiting transaction,
+// the answer for unknown RP-MR is discarded
+func (smr *SMR) answerInd(mr byte, a RPDU) {
+	smr.mutex.Lock()
+	defer smr.mutex.Unlock()
+
+	t := smr.tx[mr]
+	if t == nil || t.state != RLWaitForRPAck {
+		return
+	}
+	t.state = RLIdle
+	t.answer <- a
+}
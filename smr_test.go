@@ -0,0 +1,252 @@
+package sms_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+	"github.com/fkgi/teldata"
+)
+
+// connectSMR connect MS side SMR ms and SC side SMR sc with loopback,
+// RPDU is dropped if drop returns true
+func connectSMR(t *testing.T, ms, sc *sms.SMR, drop func(mo bool, p sms.RPDU) bool) {
+	l := &sms.RelayLoopback{Drop: drop}
+	l.Connect(ms, sc)
+	t.Cleanup(l.Close)
+}
+
+func newSMR() *sms.SMR {
+	smr := &sms.SMR{
+		TR1M: time.Millisecond * 200,
+		TR2M: time.Millisecond * 100,
+		TRAM: time.Millisecond * 50,
+		SCAddress: sms.Address{
+			TON: sms.TypeInternational, NPI: sms.PlanISDNTelephone}}
+	smr.SCAddress.Addr, _ = teldata.ParseTBCD("447785016005")
+	return smr
+}
+
+func TestSMRTransfer(t *testing.T) {
+	ms := newSMR()
+	sc := newSMR()
+	ms.TR1M, sc.TR2M = time.Second*5, time.Second*3
+	connectSMR(t, ms, sc, nil)
+
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		if s, ok := p.(sms.Submit); !ok {
+			t.Errorf("unexpected TPDU %T", p)
+		} else if st := sc.State(s.RMR, true); st != sms.RLWaitToSendRPAck {
+			t.Errorf("unexpected state %s", st)
+		}
+		return sms.SubmitReport{SCTS: scts(time.Now())}, nil
+	}
+	ms.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		return nil, sms.RpError{CS: 22}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(s sms.Submit) {
+			defer wg.Done()
+			a, e := ms.TranspReq(s)
+			if e != nil {
+				t.Errorf("submit failed: %s", e)
+			} else if _, ok := a.(sms.SubmitReport); !ok {
+				t.Errorf("unexpected answer %T", a)
+			}
+		}(randSubmit())
+	}
+	wg.Wait()
+
+	_, e := sc.TranspReq(randDeliver())
+	if re, ok := e.(sms.RpError); !ok || re.CS != 22 {
+		t.Errorf("unexpected error %v", e)
+	}
+
+	for mr := 0; mr < 256; mr++ {
+		if st := ms.State(byte(mr), false); st != sms.RLIdle {
+			t.Errorf("unexpected state %s", st)
+		}
+		if st := sc.State(byte(mr), true); st != sms.RLIdle {
+			t.Errorf("unexpected state %s", st)
+		}
+	}
+}
+
+func TestSMRTimer(t *testing.T) {
+	ms := newSMR()
+	sc := newSMR()
+
+	// TR1M expiry, late answer is discarded
+	var late sms.RPDU
+	ms.RelayReq = func(p sms.RPDU) error {
+		if s, ok := p.(sms.Submit); ok {
+			if st := ms.State(s.RMR, false); st != sms.RLWaitForRPAck {
+				t.Errorf("unexpected state %s", st)
+			}
+			late = sms.RpAckMT{RMR: s.RMR}
+		}
+		return nil
+	}
+	if _, e := ms.TranspReq(randSubmit()); !errors.Is(e, sms.ErrTR1MExpired) {
+		t.Errorf("unexpected error %v", e)
+	}
+	if a, e := ms.RelayInd(late); a != nil || e != nil {
+		t.Errorf("unexpected answer %v %v", a, e)
+	}
+
+	// TR2M expiry
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		time.Sleep(sc.TR2M * 2)
+		return nil, nil
+	}
+	s := randSubmit()
+	s.RMR = 10
+	a, e := sc.RelayInd(s)
+	if ce, ok := e.(sms.CpError); !ok || a != nil {
+		t.Errorf("unexpected answer %v %v", a, e)
+	} else if ce.CS != 111 {
+		t.Errorf("unexpected cause %d", ce.CS)
+	}
+	if st := sc.State(10, true); st != sms.RLIdle {
+		t.Errorf("unexpected state %s", st)
+	}
+}
+
+func TestSMRMemoryAvailable(t *testing.T) {
+	ms := newSMR()
+	sc := newSMR()
+
+	var count, fail int32
+	connectSMR(t, ms, sc, func(mo bool, p sms.RPDU) bool {
+		if _, ok := p.(sms.MemoryAvailable); ok {
+			atomic.AddInt32(&count, 1)
+		}
+		return false
+	})
+	sc.MemAvailInd = func() error {
+		if atomic.AddInt32(&fail, -1) >= 0 {
+			return sms.RpError{CS: 42}
+		}
+		return nil
+	}
+
+	// temporary error is recovered by retransmission
+	fail = 1
+	start := time.Now()
+	if e := ms.MemAvailReq(); e != nil {
+		t.Errorf("unexpected error %v", e)
+	}
+	if count != 2 {
+		t.Errorf("RP-SMMA is sent %d times", count)
+	}
+	if d := time.Since(start); d < ms.TRAM {
+		t.Errorf("retransmitted before TRAM expiry %s", d)
+	}
+
+	// retransmitted only once
+	count, fail = 0, 2
+	if e := ms.MemAvailReq(); e == nil {
+		t.Errorf("unexpected success")
+	} else if re, ok := e.(sms.RpError); !ok || re.CS != 42 {
+		t.Errorf("unexpected error %v", e)
+	}
+	if count != 2 {
+		t.Errorf("RP-SMMA is sent %d times", count)
+	}
+
+	// permanent error is not retransmitted
+	count = 0
+	sc.MemAvailInd = func() error {
+		return sms.RpError{CS: 97}
+	}
+	if e := ms.MemAvailReq(); e == nil {
+		t.Errorf("unexpected success")
+	}
+	if count != 1 {
+		t.Errorf("RP-SMMA is sent %d times", count)
+	}
+
+	// no answer
+	count = 0
+	ms.RelayReq = func(p sms.RPDU) error {
+		atomic.AddInt32(&count, 1)
+		return nil
+	}
+	if e := ms.MemAvailReq(); !errors.Is(e, sms.ErrTR1MExpired) {
+		t.Errorf("unexpected error %v", e)
+	}
+	if count != 2 {
+		t.Errorf("RP-SMMA is sent %d times", count)
+	}
+}
+
+func TestSMRContext(t *testing.T) {
+	ms := newSMR()
+	sc := newSMR()
+
+	// cancel while waiting RP-ACK, late answer is discarded
+	var late sms.RPDU
+	ctx, cancel := context.WithCancel(context.Background())
+	ms.RelayReq = func(p sms.RPDU) error {
+		late = sms.RpAckMT{RMR: p.(sms.Submit).RMR}
+		cancel()
+		return nil
+	}
+	aborted := -1
+	ms.AbortReq = func(mr byte) {
+		aborted = int(mr)
+	}
+	start := time.Now()
+	if _, e := ms.TranspReqContext(ctx, randSubmit()); !errors.Is(e, context.Canceled) {
+		t.Errorf("unexpected error %v", e)
+	}
+	if d := time.Since(start); d >= ms.TR1M {
+		t.Errorf("canceled after %s", d)
+	}
+	mr := late.(sms.RpAckMT).RMR
+	if st := ms.State(mr, false); st != sms.RLIdle {
+		t.Errorf("unexpected state %s", st)
+	}
+	if aborted != int(mr) {
+		t.Errorf("abort is not requested for %d: %d", mr, aborted)
+	}
+	if a, e := ms.RelayInd(late); a != nil || e != nil {
+		t.Errorf("unexpected answer %v %v", a, e)
+	}
+
+	// TR2M is deadline of the indication
+	sc.TranspIndContext = func(ctx context.Context, p sms.TPDU) (sms.TPDU, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("no deadline")
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	s := randSubmit()
+	s.RMR = 10
+	if a, e := sc.RelayInd(s); a != nil {
+		t.Errorf("unexpected answer %v", a)
+	} else if ce, ok := e.(sms.CpError); !ok || ce.CS != 111 {
+		t.Errorf("unexpected error %v", e)
+	}
+
+	// cancel of the indication is answered by RP-ERROR
+	ctx, cancel = context.WithTimeout(context.Background(), sc.TR2M/4)
+	defer cancel()
+	a, e := sc.RelayIndContext(ctx, s)
+	if re, ok := a.(sms.RpErrorMT); !ok || e != nil {
+		t.Errorf("unexpected answer %v %v", a, e)
+	} else if re.CS != 41 || re.RMR != 10 {
+		t.Errorf("unexpected RP-ERROR %v", re)
+	}
+	if st := sc.State(10, true); st != sms.RLIdle {
+		t.Errorf("unexpected state %s", st)
+	}
+}
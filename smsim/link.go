@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// link transfers PDU data to peer simulator
+type link interface {
+	Send([]byte) error
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// open makes link, TCP link accepts a connection if listen is
+// specified, or connects to connect.
+// UDP link sends to connect or the source of the last received data.
+func open(network, listen, connect string) (link, error) {
+	switch network {
+	case "tcp":
+		if listen != "" {
+			l, e := net.Listen("tcp", listen)
+			if e != nil {
+				return nil, e
+			}
+			defer l.Close()
+			c, e := l.Accept()
+			if e != nil {
+				return nil, e
+			}
+			return newTCPLink(c), nil
+		}
+		c, e := net.Dial("tcp", connect)
+		if e != nil {
+			return nil, e
+		}
+		return newTCPLink(c), nil
+	case "udp":
+		if listen == "" {
+			listen = ":0"
+		}
+		la, e := net.ResolveUDPAddr("udp", listen)
+		if e != nil {
+			return nil, e
+		}
+		l := &udpLink{}
+		if connect != "" {
+			if l.peer, e = net.ResolveUDPAddr("udp", connect); e != nil {
+				return nil, e
+			}
+		}
+		if l.conn, e = net.ListenUDP("udp", la); e != nil {
+			return nil, e
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("invalid network: %s", network)
+}
+
+// tcpLink is link over TCP, each data has 2 octets length prefix
+type tcpLink struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	mutex sync.Mutex
+}
+
+func newTCPLink(c net.Conn) *tcpLink {
+	return &tcpLink{conn: c, r: bufio.NewReader(c)}
+}
+
+func (l *tcpLink) Send(b []byte) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	buf := make([]byte, 2, len(b)+2)
+	binary.BigEndian.PutUint16(buf, uint16(len(b)))
+	_, e := l.conn.Write(append(buf, b...))
+	return e
+}
+
+func (l *tcpLink) Receive() ([]byte, error) {
+	buf := make([]byte, 2)
+	if _, e := io.ReadFull(l.r, buf); e != nil {
+		return nil, e
+	}
+	buf = make([]byte, binary.BigEndian.Uint16(buf))
+	_, e := io.ReadFull(l.r, buf)
+	return buf, e
+}
+
+func (l *tcpLink) Close() error {
+	return l.conn.Close()
+}
+
+// udpLink is link over UDP, each datagram has a data
+type udpLink struct {
+	conn  *net.UDPConn
+	peer  *net.UDPAddr
+	mutex sync.Mutex
+}
+
+func (l *udpLink) Send(b []byte) error {
+	l.mutex.Lock()
+	peer := l.peer
+	l.mutex.Unlock()
+	if peer == nil {
+		return errors.New("peer address is unknown")
+	}
+	_, e := l.conn.WriteToUDP(b, peer)
+	return e
+}
+
+func (l *udpLink) Receive() ([]byte, error) {
+	buf := make([]byte, 1500)
+	n, a, e := l.conn.ReadFromUDP(buf)
+	if e != nil {
+		return nil, e
+	}
+	l.mutex.Lock()
+	l.peer = a
+	l.mutex.Unlock()
+	return buf[:n], nil
+}
+
+func (l *udpLink) Close() error {
+	return l.conn.Close()
+}
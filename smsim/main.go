@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/fkgi/sms"
+)
+
+func main() {
+	mode := flag.String("m", "ms", "simulated side `ms|sc`")
+	network := flag.String("n", "udp", "network of the link `tcp|udp`")
+	listen := flag.String("listen", "", "local `address` to listen")
+	connect := flag.String("connect", "", "peer `address` to connect")
+	sca := flag.String("sca", "+819000000000", "SC `address` in RP-DATA")
+	script := flag.String("s", "", "scenario script `file`")
+	exit := flag.Bool("x", false, "exit after the scenario script")
+	verbose := flag.Bool("v", false, "show all sent and received PDU")
+	flag.Parse()
+
+	if *mode != "ms" && *mode != "sc" {
+		fmt.Fprintf(os.Stderr, "invalid side: %s\n", *mode)
+		os.Exit(1)
+	}
+	a, e := sms.ParseAddress(*sca)
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(1)
+	}
+
+	l, e := open(*network, *listen, *connect)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "failed to open link: %s\n", e)
+		os.Exit(1)
+	}
+	defer l.Close()
+
+	s := newSimulator(*mode == "ms", *verbose, l, a)
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.serve()
+	}()
+
+	if *script != "" {
+		f, e := os.Open(*script)
+		if e != nil {
+			fmt.Fprintln(os.Stderr, e)
+			os.Exit(1)
+		}
+		e = s.run(f)
+		f.Close()
+		if e != nil {
+			fmt.Fprintln(os.Stderr, e)
+			os.Exit(1)
+		}
+		if *exit {
+			return
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	select {
+	case <-sig:
+	case e := <-closed:
+		log.Printf("link closed: %s", e)
+	}
+}
@@ -0,0 +1,4 @@
+go build -o smsim/smsim ./smsim
+./smsim/smsim -m sc -n udp -listen 127.0.0.1:10001 -connect 127.0.0.1:10002 -s smsim/sc.txt -x &
+./smsim/smsim -m ms -n udp -listen 127.0.0.1:10002 -connect 127.0.0.1:10001 -s smsim/ms.txt -x
+wait
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+// simulator is MS or SC side stack that is connected to peer with link.
+// SMC is on the link and SMR is over SMC,
+// and SMTL handles transfer over SMR on MS side.
+type simulator struct {
+	ms      bool // MS side if true, SC side if false
+	verbose bool
+	link    link
+	smc     *sms.SMC
+	smr     *sms.SMR
+	smtl    *sms.SMTL // nil on SC side
+
+	mutex    sync.Mutex
+	cause    byte          // RP-Cause to answer, zero for RP-ACK
+	fcs      byte          // TP-FCS to answer
+	ackDelay time.Duration // delay of CP-ACK
+
+	concatRef *sms.RefAllocator // allocates concatenation reference on SC side
+}
+
+func newSimulator(ms, verbose bool, l link, sca sms.Address) *simulator {
+	s := &simulator{ms: ms, verbose: verbose, link: l}
+	s.smc = &sms.SMC{CtrlReq: s.ctrlReq}
+	s.smr = &sms.SMR{
+		SCAddress:   sca,
+		TranspInd:   s.transpInd,
+		MemAvailInd: s.memAvailInd}
+	s.smr.SetLink(s.smc)
+	s.smc.SetUser(s.smr)
+	if ms {
+		s.smtl = &sms.SMTL{
+			SMR:          s.smr,
+			Received:     s.received,
+			StatusReport: s.statusReport}
+	} else {
+		s.concatRef, _ = sms.NewConcatRefAllocator(nil, "")
+	}
+	return s
+}
+
+func (s *simulator) ctrlReq(p sms.CPDU) {
+	s.mutex.Lock()
+	d := s.ackDelay
+	s.mutex.Unlock()
+
+	send := func() {
+		if s.verbose {
+			log.Printf("send %s", p)
+		}
+		if e := s.link.Send(p.MarshalCP()); e != nil {
+			log.Printf("send failed: %s", e)
+		}
+	}
+	if _, ok := p.(sms.CpAck); ok && d != 0 {
+		time.AfterFunc(d, send)
+	} else {
+		send()
+	}
+}
+
+// serve receives data from peer until the link is closed
+func (s *simulator) serve() error {
+	for {
+		b, e := s.link.Receive()
+		if e != nil {
+			return e
+		}
+
+		var p sms.CPDU
+		if s.ms {
+			p, e = sms.UnmarshalCPMT(b)
+		} else {
+			p, e = sms.UnmarshalCPMO(b)
+		}
+		if e != nil {
+			log.Printf("invalid data % x: %s", b, e)
+			continue
+		}
+		if s.verbose {
+			log.Printf("receive %s", p)
+		}
+		s.smc.CtrlInd(p)
+	}
+}
+
+func (s *simulator) transpInd(p sms.TPDU) (sms.TPDU, error) {
+	switch v := p.(type) {
+	case sms.Submit:
+		log.Printf("received Submit to %s: %s", v.DA, v.UD.Text)
+	case sms.Deliver:
+		log.Printf("received Deliver from %s: %s", v.OA, v.UD.Text)
+	case sms.StatusReport:
+		log.Printf("received StatusReport of TP-MR %d: TP-ST %x", v.TMR, v.ST)
+	default:
+		log.Printf("received %T", p)
+	}
+
+	s.mutex.Lock()
+	cs, fcs := s.cause, s.fcs
+	s.mutex.Unlock()
+
+	switch {
+	case fcs != 0 && s.ms:
+		return sms.DeliverReport{CS: cs, FCS: fcs}, nil
+	case fcs != 0:
+		ts, _ := sms.TimeToSCTimeStamp(time.Now())
+		return sms.SubmitReport{CS: cs, FCS: fcs, SCTS: ts}, nil
+	case cs != 0:
+		return nil, sms.RpError{CS: cs}
+	}
+	if s.smtl != nil {
+		return s.smtl.TranspInd(p)
+	}
+	if _, ok := p.(sms.Submit); ok {
+		ts, _ := sms.TimeToSCTimeStamp(time.Now())
+		return sms.SubmitReport{SCTS: ts}, nil
+	}
+	return nil, nil
+}
+
+// received is called by SMTL when all parts of the message are received
+func (s *simulator) received(m sms.ReceivedSM) {
+	log.Printf("received message from %s in %d parts: %s", m.OA, len(m.Parts), m.Text)
+}
+
+// statusReport is called by SMTL for received StatusReport
+func (s *simulator) statusReport(r sms.StatusReport) {
+	log.Printf("status of TP-MR %d is reported: TP-ST %x", r.TMR, r.ST)
+}
+
+func (s *simulator) memAvailInd() error {
+	log.Print("received memory available")
+	return nil
+}
+
+// run executes scenario script from r
+func (s *simulator) run(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if e := s.exec(strings.Fields(line), line); e != nil {
+			return fmt.Errorf("line %d: %s", n, e)
+		}
+	}
+	return sc.Err()
+}
+
+// text returns the remaining text after n fields of line
+func text(line string, n int) string {
+	for i := 0; i < n; i++ {
+		line = strings.TrimSpace(line)
+		j := strings.IndexAny(line, " \t")
+		if j < 0 {
+			return ""
+		}
+		line = line[j:]
+	}
+	return strings.TrimSpace(line)
+}
+
+func parseByte(s string) (byte, error) {
+	i, e := strconv.ParseUint(s, 0, 8)
+	return byte(i), e
+}
+
+func (s *simulator) exec(f []string, line string) (e error) {
+	switch f[0] {
+	case "submit":
+		if !s.ms {
+			return errors.New("submit is available on MS side")
+		}
+		if len(f) < 3 {
+			return errors.New("usage: submit <da> <text>")
+		}
+		var da sms.Address
+		if da, e = sms.ParseAddress(f[1]); e != nil {
+			return
+		}
+		sent, se := s.smtl.Send(context.Background(), da, text(line, 2), nil)
+		for i, p := range sent {
+			var pe error
+			if i == len(sent)-1 {
+				pe = se
+			}
+			result(fmt.Sprintf("Submit (TP-MR %d)", p.TMR))(nil, pe)
+		}
+		if len(sent) == 0 && se != nil {
+			result("Submit")(nil, se)
+		}
+	case "deliver":
+		if s.ms {
+			return errors.New("deliver is available on SC side")
+		}
+		if len(f) < 3 {
+			return errors.New("usage: deliver <oa> <text>")
+		}
+		var oa sms.Address
+		if oa, e = sms.ParseAddress(f[1]); e != nil {
+			return
+		}
+		var ud []sms.UserData
+		var cs sms.Charset
+		var ref uint16
+		if ud, cs, ref, e = sms.MakeSeparatedTextRef(text(line, 2), s.concatRef); e != nil {
+			return
+		}
+		if len(ud) > 1 {
+			defer s.concatRef.Release(ref)
+		}
+		for i, u := range ud {
+			p := sms.Deliver{
+				MMS: i != len(ud)-1,
+				OA:  oa,
+				DCS: sms.GeneralDataCoding{MsgCharset: cs},
+				UD:  u}
+			p.SCTS, _ = sms.TimeToSCTimeStamp(time.Now())
+			result("Deliver")(s.smr.TranspReq(p))
+		}
+	case "report":
+		if s.ms {
+			return errors.New("report is available on SC side")
+		}
+		if len(f) != 4 {
+			return errors.New("usage: report <ra> <mr> <st>")
+		}
+		p := sms.StatusReport{}
+		if p.RA, e = sms.ParseAddress(f[1]); e != nil {
+			return
+		}
+		if p.TMR, e = parseByte(f[2]); e != nil {
+			return
+		}
+		if p.ST, e = parseByte(f[3]); e != nil {
+			return
+		}
+		p.SCTS, _ = sms.TimeToSCTimeStamp(time.Now())
+		p.DT = p.SCTS
+		result(fmt.Sprintf("StatusReport (TP-MR %d)", p.TMR))(s.smr.TranspReq(p))
+	case "smma":
+		if !s.ms {
+			return errors.New("smma is available on MS side")
+		}
+		result("RP-SMMA")(nil, s.smr.MemAvailReq())
+	case "answer":
+		var cs, fcs byte
+		switch {
+		case len(f) == 2 && f[1] == "ack":
+		case len(f) == 3 && f[1] == "rp-error":
+			if cs, e = parseByte(f[2]); e != nil {
+				return
+			}
+		case (len(f) == 3 || len(f) == 4) && f[1] == "fcs":
+			if fcs, e = parseByte(f[2]); e != nil {
+				return
+			}
+			cs = 111
+			if len(f) == 4 {
+				if cs, e = parseByte(f[3]); e != nil {
+					return
+				}
+			}
+		default:
+			return errors.New("usage: answer ack|rp-error <cause>|fcs <fcs> [<cause>]")
+		}
+		s.mutex.Lock()
+		s.cause, s.fcs = cs, fcs
+		s.mutex.Unlock()
+	case "memory":
+		if !s.ms {
+			return errors.New("memory is available on MS side")
+		}
+		switch {
+		case len(f) == 2 && f[1] == "full":
+			s.mutex.Lock()
+			s.cause, s.fcs = 22, 0xd3
+			s.mutex.Unlock()
+		case len(f) == 2 && f[1] == "available":
+			s.mutex.Lock()
+			s.cause, s.fcs = 0, 0
+			s.mutex.Unlock()
+			result("RP-SMMA")(nil, s.smr.MemAvailReq())
+		default:
+			return errors.New("usage: memory full|available")
+		}
+	case "delay-ack":
+		if len(f) != 2 {
+			return errors.New("usage: delay-ack <duration>")
+		}
+		var d time.Duration
+		if d, e = time.ParseDuration(f[1]); e != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.ackDelay = d
+		s.mutex.Unlock()
+	case "wait":
+		if len(f) != 2 {
+			return errors.New("usage: wait <duration>")
+		}
+		var d time.Duration
+		if d, e = time.ParseDuration(f[1]); e != nil {
+			return
+		}
+		time.Sleep(d)
+	default:
+		return fmt.Errorf("unknown command: %s", f[0])
+	}
+	return
+}
+
+// result returns function that logs the answer of the request name
+func result(name string) func(sms.TPDU, error) {
+	return func(a sms.TPDU, e error) {
+		var fe sms.FailureCauseError
+		if errors.As(e, &fe) {
+			log.Printf("%s rejected: TP-FCS %x", name, fe.FCS)
+			return
+		}
+		if e != nil {
+			log.Printf("%s failed: %s", name, e)
+			return
+		}
+		var fcs byte
+		switch v := a.(type) {
+		case sms.SubmitReport:
+			fcs = v.FCS
+		case sms.DeliverReport:
+			fcs = v.FCS
+		}
+		if fcs&0x80 == 0x80 {
+			log.Printf("%s rejected: TP-FCS %x", name, fcs)
+		} else {
+			log.Printf("%s accepted", name)
+		}
+	}
+}
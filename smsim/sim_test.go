@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+var testSCA, _ = sms.ParseAddress("+819000000000")
+
+func TestText(t *testing.T) {
+	for _, c := range []struct {
+		line string
+		n    int
+		text string
+	}{
+		{"submit +8190 hello  world ", 2, "hello  world"},
+		{"submit\t+8190\thello", 2, "hello"},
+		{"submit +8190", 2, ""},
+		{"answer ack", 1, "ack"},
+	} {
+		if s := text(c.line, c.n); s != c.text {
+			t.Errorf("unexpected text of %q: %q", c.line, s)
+		}
+	}
+}
+
+func TestRunError(t *testing.T) {
+	for _, c := range []struct {
+		ms     bool
+		script string
+		err    string
+	}{
+		{true, "# comment\n\nunknown", "line 3: unknown command: unknown"},
+		{false, "submit +8190 hello", "line 1: submit is available on MS side"},
+		{true, "submit +8190", "line 1: usage: submit"},
+		{true, "deliver +8190 hello", "line 1: deliver is available on SC side"},
+		{false, "report +8190 1", "line 1: usage: report"},
+		{false, "report +8190 1 256", "line 1: "},
+		{false, "memory full", "line 1: memory is available on MS side"},
+		{true, "answer nack", "line 1: usage: answer"},
+		{true, "answer rp-error x", "line 1: "},
+		{true, "wait 1s 2s", "line 1: usage: wait"},
+		{true, "delay-ack 10", "line 1: "},
+	} {
+		s := newSimulator(c.ms, false, nil, testSCA)
+		e := s.run(strings.NewReader(c.script))
+		if e == nil || !strings.HasPrefix(e.Error(), c.err) {
+			t.Errorf("unexpected error of %q: %v", c.script, e)
+		}
+	}
+}
+
+func TestRunAnswer(t *testing.T) {
+	s := newSimulator(true, false, nil, testSCA)
+	for _, c := range []struct {
+		script   string
+		cause    byte
+		fcs      byte
+		ackDelay time.Duration
+	}{
+		{"answer rp-error 41", 41, 0, 0},
+		{"answer fcs 0xd0", 111, 0xd0, 0},
+		{"answer fcs 0xd0 0x29", 41, 0xd0, 0},
+		{"answer ack\ndelay-ack 500ms", 0, 0, time.Millisecond * 500},
+		{"memory full", 22, 0xd3, time.Millisecond * 500},
+	} {
+		if e := s.run(strings.NewReader(c.script)); e != nil {
+			t.Fatalf("failed to run %q: %s", c.script, e)
+		}
+		if s.cause != c.cause || s.fcs != c.fcs || s.ackDelay != c.ackDelay {
+			t.Errorf("unexpected answer of %q: %d %x %s",
+				c.script, s.cause, s.fcs, s.ackDelay)
+		}
+	}
+}
+
+// recordLink is link that records sent data
+type recordLink struct {
+	link
+	mutex sync.Mutex
+	sent  [][]byte
+}
+
+func (l *recordLink) Send(b []byte) error {
+	l.mutex.Lock()
+	l.sent = append(l.sent, b)
+	l.mutex.Unlock()
+	return l.link.Send(b)
+}
+
+// submitted returns Submit in CP-DATA that is sent to l
+func submitted(t *testing.T, l *recordLink) (parts []sms.Submit) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, b := range l.sent {
+		p, e := sms.UnmarshalCPMO(b)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if s, ok := p.(sms.Submit); ok {
+			parts = append(parts, s)
+		}
+	}
+	l.sent = nil
+	return
+}
+
+func TestRunSubmit(t *testing.T) {
+	c1, c2 := net.Pipe()
+	l := &recordLink{link: newTCPLink(c1)}
+	ms := newSimulator(true, false, l, testSCA)
+	sc := newSimulator(false, false, newTCPLink(c2), testSCA)
+	go ms.serve()
+	go sc.serve()
+	defer l.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	// SMTL segments the text and allocates reference for each message
+	script := "submit +819087654321 " + strings.Repeat("a", 200) + "\n" +
+		"submit +819087654321 " + strings.Repeat("b", 200)
+	if e := ms.run(strings.NewReader(script)); e != nil {
+		t.Fatal(e)
+	}
+	parts := submitted(t, l)
+	if len(parts) != 4 {
+		t.Fatalf("unexpected number of parts %d", len(parts))
+	}
+	refs := map[byte]bool{}
+	for i, p := range parts {
+		c, ok := p.UD.UDH[0].(sms.ConcatenatedSM)
+		if !ok || c.MaxNum != 2 || c.SeqNum != byte(i%2+1) {
+			t.Errorf("unexpected UDH %v", p.UD.UDH)
+		}
+		refs[c.RefNum] = true
+		if p.SCA.Addr.String() != testSCA.Addr.String() {
+			t.Errorf("unexpected RP-DA %s", p.SCA)
+		}
+	}
+	if len(refs) != 2 {
+		t.Errorf("unexpected concatenation references %v", refs)
+	}
+
+	// TP-FCS is reported as rejection, and remaining part is not sent
+	if e := sc.run(strings.NewReader("answer fcs 0xd0")); e != nil {
+		t.Fatal(e)
+	}
+	if e := ms.run(strings.NewReader(script)); e != nil {
+		t.Fatal(e)
+	}
+	if parts = submitted(t, l); len(parts) != 2 {
+		t.Errorf("unexpected number of parts %d", len(parts))
+	}
+	if n := strings.Count(logs.String(), "rejected: TP-FCS d0"); n != 2 {
+		t.Errorf("%d rejections are logged\n%s", n, logs.String())
+	}
+}
@@ -0,0 +1,306 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StatusReportWait is default period to hold TP-MR of the message
+// that requests status report
+var StatusReportWait = time.Duration(24 * time.Hour)
+
+// SendOptions is optional TP parameters of the message to send
+type SendOptions struct {
+	RD    bool           // TP-RD
+	SRR   bool           // TP-SRR
+	PID   byte           // TP-PID
+	VP    ValidityPeriod // TP-VP
+	Class msgClass       // message class of TP-DCS
+}
+
+// ReceivedSM is complete message that is reassembled from Deliver
+type ReceivedSM struct {
+	OA   Address
+	SCTS SCTimeStamp // TP-SCTS of the last part
+	PID  byte
+	DCS  DataCoding
+	Text string // text of GSM 7bit or UCS2 message
+	Data []byte // data of 8bit message
+
+	Parts []Deliver // received parts in sequence order
+}
+
+// SMTL is SM-TL entity of MS that sends and receives
+// complete messages over SMR.
+// TranspInd of SMR should be set to TranspInd of SMTL.
+type SMTL struct {
+	SMR *SMR
+	// MsgRef allocates TP-MR, it is made on first use if nil
+	MsgRef *RefAllocator
+	// ConcatRef allocates reference of concatenated SM,
+	// it is made on first use if nil
+	ConcatRef *RefAllocator
+	// Tracker tracks delivery state of sent messages if it is not nil
+	Tracker *Tracker
+	// ReassemblyTimeout is period to wait for remaining parts,
+	// zero means no timeout
+	ReassemblyTimeout time.Duration
+	// ReportTimeout is period to hold TP-MR for the final status report,
+	// StatusReportWait is used if zero
+	ReportTimeout time.Duration
+
+	Received     func(ReceivedSM)
+	StatusReport func(StatusReport)
+
+	mutex   sync.Mutex
+	partial map[string]*partialSM
+	held    map[byte]*time.Timer // TP-MR waiting for the final status report
+}
+
+type partialSM struct {
+	parts []*Deliver
+	count int
+}
+
+func (tl *SMTL) refs() (mr, cr *RefAllocator) {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	if tl.MsgRef == nil {
+		tl.MsgRef, _ = NewMsgRefAllocator(nil, "")
+	}
+	if tl.ConcatRef == nil {
+		tl.ConcatRef, _ = NewConcatRefAllocator(nil, "")
+	}
+	return tl.MsgRef, tl.ConcatRef
+}
+
+// Send segments data to Submit and send them to da in order.
+// data is string for text message or []byte for 8bit message,
+// and o can be nil for default options.
+// Sent Submit are returned even if the error is returned, and
+// remaining parts are not sent after the error or cancel of ctx,
+// and ctx is passed to SMR for each part.
+// TP-FCS of the SubmitReport is returned as FailureCauseError.
+// TP-MR of the part that requests status report is outstanding
+// until the final status report is received or ReportTimeout expires.
+func (tl *SMTL) Send(ctx context.Context, da Address, data interface{}, o *SendOptions) (
+	sent []Submit, e error) {
+	if o == nil {
+		o = &SendOptions{}
+	}
+	mr, cr := tl.refs()
+
+	var ud []UserData
+	var cs Charset
+	var ref uint16
+	switch v := data.(type) {
+	case string:
+		ud, cs, ref, e = MakeSeparatedTextRef(v, cr)
+	case []byte:
+		ud, ref, e = MakeSeparatedDataRef(v, cr)
+		cs = Charset8bitData
+	default:
+		e = ErrUnsupportedData
+	}
+	if e != nil {
+		return
+	}
+	if len(ud) > 1 {
+		defer cr.Release(ref)
+	}
+
+	parts := make([]Submit, 0, len(ud))
+	for _, u := range ud {
+		s := Submit{
+			RD:  o.RD,
+			SRR: o.SRR,
+			DA:  da,
+			PID: o.PID,
+			DCS: GeneralDataCoding{MsgClass: o.Class, MsgCharset: cs},
+			VP:  o.VP,
+			UD:  u}
+		var r uint16
+		if r, e = mr.Next(); e != nil {
+			for _, s := range parts {
+				mr.Release(uint16(s.TMR))
+			}
+			return
+		}
+		s.TMR = byte(r)
+		parts = append(parts, s)
+	}
+	if tl.Tracker != nil {
+		tl.Tracker.Track(parts...)
+	}
+
+	for _, s := range parts {
+		if e == nil {
+			e = ctx.Err()
+		}
+		if e != nil {
+			// remaining parts are not sent
+			mr.Release(uint16(s.TMR))
+			if tl.Tracker != nil {
+				tl.Tracker.Submitted(s, nil, e)
+			}
+			continue
+		}
+
+		if s.SRR {
+			tl.hold(s.TMR)
+		}
+		var a TPDU
+		a, e = tl.SMR.TranspReqContext(ctx, s)
+		if tl.Tracker != nil {
+			tl.Tracker.Submitted(s, a, e)
+		}
+		if r, ok := a.(SubmitReport); ok && e == nil && r.FCS&0x80 == 0x80 {
+			e = FailureCauseError{FCS: r.FCS}
+		}
+		if e != nil || !s.SRR {
+			tl.unhold(s.TMR)
+			mr.Release(uint16(s.TMR))
+		}
+		sent = append(sent, s)
+	}
+	return
+}
+
+// hold mark TP-MR mr as waiting for the final status report,
+// mr is released if the report is not received in ReportTimeout
+func (tl *SMTL) hold(mr byte) {
+	d := tl.ReportTimeout
+	if d == 0 {
+		d = StatusReportWait
+	}
+
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	if tl.held == nil {
+		tl.held = make(map[byte]*time.Timer)
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		tl.mutex.Lock()
+		ok := tl.held[mr] == t
+		if ok {
+			delete(tl.held, mr)
+		}
+		r := tl.MsgRef
+		tl.mutex.Unlock()
+		if ok {
+			r.Release(uint16(mr))
+		}
+	})
+	tl.held[mr] = t
+}
+
+// unhold reports mr was waiting for the final status report
+func (tl *SMTL) unhold(mr byte) bool {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	t, ok := tl.held[mr]
+	if ok {
+		t.Stop()
+		delete(tl.held, mr)
+	}
+	return ok
+}
+
+// TranspInd handle Deliver and StatusReport from SMR.
+// Deliver is reassembled and passed to Received when all parts are
+// received, and StatusReport is passed to StatusReport.
+func (tl *SMTL) TranspInd(p TPDU) (TPDU, error) {
+	switch v := p.(type) {
+	case Deliver:
+		if m, ok := tl.reassemble(v); ok && tl.Received != nil {
+			tl.Received(m)
+		}
+	case StatusReport:
+		if (v.ST < 0x20 || v.ST >= 0x40) && tl.unhold(v.TMR) {
+			tl.MsgRef.Release(uint16(v.TMR))
+		}
+		if tl.Tracker != nil {
+			tl.Tracker.Reported(v)
+		}
+		if tl.StatusReport != nil {
+			tl.StatusReport(v)
+		}
+	default:
+		return nil, RpError{CS: 97}
+	}
+	return nil, nil
+}
+
+// concatInfo returns concatenation reference, max and sequence number
+// of u, ok is false if u is not concatenated
+func concatInfo(u UserData) (ref uint16, max, seq byte, ok bool) {
+	for _, h := range u.UDH {
+		switch c := h.(type) {
+		case ConcatenatedSM:
+			return uint16(c.RefNum), c.MaxNum, c.SeqNum, true
+		case ConcatenatedSM16bit:
+			return c.RefNum, c.MaxNum, c.SeqNum, true
+		}
+	}
+	return
+}
+
+// reassemble store d and returns the message if all parts are received
+func (tl *SMTL) reassemble(d Deliver) (ReceivedSM, bool) {
+	ref, max, seq, ok := concatInfo(d.UD)
+	if !ok || max < 2 || seq == 0 || seq > max {
+		return makeReceivedSM([]*Deliver{&d}), true
+	}
+
+	k := fmt.Sprintf("%d:%d:%s:%d:%d", d.OA.TON, d.OA.NPI, d.OA.Addr, ref, max)
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	if tl.partial == nil {
+		tl.partial = make(map[string]*partialSM)
+	}
+	m, ok := tl.partial[k]
+	if !ok {
+		m = &partialSM{parts: make([]*Deliver, max)}
+		tl.partial[k] = m
+		if tl.ReassemblyTimeout != 0 {
+			time.AfterFunc(tl.ReassemblyTimeout, func() {
+				tl.mutex.Lock()
+				if tl.partial[k] == m {
+					delete(tl.partial, k)
+				}
+				tl.mutex.Unlock()
+			})
+		}
+	}
+	if m.parts[seq-1] == nil {
+		m.count++
+	}
+	m.parts[seq-1] = &d
+	if m.count != len(m.parts) {
+		return ReceivedSM{}, false
+	}
+	delete(tl.partial, k)
+	return makeReceivedSM(m.parts), true
+}
+
+func makeReceivedSM(p []*Deliver) (m ReceivedSM) {
+	l := p[len(p)-1]
+	m.OA, m.SCTS, m.PID, m.DCS = l.OA, l.SCTS, l.PID, l.DCS
+	cs := CharsetGSM7bit
+	if l.DCS != nil {
+		cs = l.DCS.Charset()
+	}
+	for _, d := range p {
+		m.Parts = append(m.Parts, *d)
+		if cs == Charset8bitData {
+			b, _ := d.UD.Get8bitData()
+			m.Data = append(m.Data, b...)
+		} else {
+			m.Text += d.UD.Text
+		}
+	}
+	return
+}
@@ -0,0 +1,265 @@
+package sms_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+func newSMTL(t *testing.T) (*sms.SMTL, *sms.SMR) {
+	ms := newSMR()
+	sc := newSMR()
+	connectSMR(t, ms, sc, nil)
+	tl := &sms.SMTL{SMR: ms}
+	ms.TranspInd = tl.TranspInd
+	return tl, sc
+}
+
+func TestSMTLSend(t *testing.T) {
+	tl, sc := newSMTL(t)
+	da, _ := sms.ParseAddress("+819087654321")
+
+	var mu sync.Mutex
+	var rcv []sms.Submit
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		mu.Lock()
+		rcv = append(rcv, p.(sms.Submit))
+		mu.Unlock()
+		return sms.SubmitReport{SCTS: scts(time.Now())}, nil
+	}
+
+	for _, d := range []interface{}{
+		strings.Repeat("a", 400), strings.Repeat("あ", 100),
+		bytes.Repeat([]byte{0xa5}, 300), "hello"} {
+		rcv = nil
+		sent, e := tl.Send(context.Background(), da, d, nil)
+		if e != nil {
+			t.Fatalf("send failed: %s", e)
+		}
+		if len(sent) != len(rcv) {
+			t.Fatalf("%d parts are sent but %d parts are received",
+				len(sent), len(rcv))
+		}
+
+		var text string
+		var data []byte
+		mrs := map[byte]bool{}
+		for i, s := range rcv {
+			if !s.DA.Equal(da) {
+				t.Errorf("unexpected TP-DA %s", s.DA)
+			}
+			mrs[s.TMR] = true
+			if len(rcv) > 1 {
+				c, ok := s.UD.UDH[0].(sms.ConcatenatedSM)
+				if !ok || c.SeqNum != byte(i+1) || c.MaxNum != byte(len(rcv)) {
+					t.Errorf("unexpected header %v", s.UD.UDH)
+				}
+			}
+			if s.DCS.Charset() == sms.Charset8bitData {
+				b, _ := s.UD.Get8bitData()
+				data = append(data, b...)
+			} else {
+				text += s.UD.Text
+			}
+		}
+		if len(mrs) != len(rcv) {
+			t.Errorf("TP-MR is reused in a message")
+		}
+		if b, ok := d.([]byte); ok && !bytes.Equal(b, data) {
+			t.Errorf("unexpected data % x", data)
+		} else if s, ok := d.(string); ok && s != text {
+			t.Errorf("unexpected text %s", text)
+		}
+	}
+
+	if _, e := tl.Send(context.Background(), da, 1, nil); !errors.Is(e, sms.ErrUnsupportedData) {
+		t.Errorf("unexpected error %v", e)
+	}
+}
+
+func TestSMTLSendFailure(t *testing.T) {
+	tl, sc := newSMTL(t)
+	tl.Tracker = &sms.Tracker{}
+	da, _ := sms.ParseAddress("+819087654321")
+
+	var ids []int
+	tl.Tracker.Update = func(m sms.TrackedSM) {
+		ids = append(ids, m.ID)
+	}
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		return sms.SubmitReport{FCS: 0xc5, SCTS: scts(time.Now())}, nil
+	}
+
+	sent, e := tl.Send(context.Background(), da, strings.Repeat("a", 400), nil)
+	var fe sms.FailureCauseError
+	if !errors.As(e, &fe) || fe.FCS != 0xc5 {
+		t.Errorf("unexpected error %v", e)
+	}
+	if len(sent) != 1 {
+		t.Errorf("%d parts are sent after failure", len(sent))
+	}
+	if m, ok := tl.Tracker.Get(ids[0]); ok {
+		t.Errorf("message is still tracked in %s", m.State)
+	}
+
+	// canceled before sending
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		t.Errorf("unexpected submit")
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sent, e = tl.Send(ctx, da, "hello", nil)
+	if !errors.Is(e, context.Canceled) || len(sent) != 0 {
+		t.Errorf("unexpected result %v %v", sent, e)
+	}
+}
+
+func TestSMTLStatusReport(t *testing.T) {
+	tl, sc := newSMTL(t)
+	tl.MsgRef, _ = sms.NewMsgRefAllocator(nil, "")
+	da, _ := sms.ParseAddress("+819087654321")
+
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		if !p.(sms.Submit).SRR {
+			t.Errorf("TP-SRR is not set")
+		}
+		return nil, nil
+	}
+	reported := make(chan sms.StatusReport, 1)
+	tl.StatusReport = func(r sms.StatusReport) {
+		reported <- r
+	}
+
+	sent, e := tl.Send(context.Background(), da, "hello", &sms.SendOptions{SRR: true})
+	if e != nil {
+		t.Fatalf("send failed: %s", e)
+	}
+
+	// TP-MR is outstanding until the final status report
+	for i := 0; i < 255; i++ {
+		if r, _ := tl.MsgRef.Next(); byte(r) == sent[0].TMR {
+			t.Fatalf("TP-MR %d is reused", r)
+		}
+	}
+	if _, e := tl.MsgRef.Next(); !errors.Is(e, sms.ErrNoReference) {
+		t.Errorf("unexpected error %v", e)
+	}
+
+	for _, st := range []byte{0x20, 0x00} {
+		r := sms.StatusReport{
+			TMR: sent[0].TMR, RA: da, ST: st,
+			SCTS: scts(time.Now()), DT: scts(time.Now())}
+		if _, e := sc.TranspReq(r); e != nil {
+			t.Fatalf("status report failed: %s", e)
+		}
+		if r := <-reported; r.ST != st {
+			t.Errorf("unexpected TP-ST %x", r.ST)
+		}
+	}
+	if r, e := tl.MsgRef.Next(); e != nil || byte(r) != sent[0].TMR {
+		t.Errorf("TP-MR is not released %d %v", r, e)
+	}
+}
+
+func TestSMTLReportTimeout(t *testing.T) {
+	tl, sc := newSMTL(t)
+	tl.MsgRef, _ = sms.NewMsgRefAllocator(nil, "")
+	tl.ReportTimeout = 50 * time.Millisecond
+	da, _ := sms.ParseAddress("+819087654321")
+	sc.TranspInd = func(p sms.TPDU) (sms.TPDU, error) {
+		return nil, nil
+	}
+
+	// exhaust the allocator except one TP-MR
+	for i := 0; i < 255; i++ {
+		if _, e := tl.MsgRef.Next(); e != nil {
+			t.Fatal(e)
+		}
+	}
+	o := &sms.SendOptions{SRR: true}
+	if _, e := tl.Send(context.Background(), da, "hello", o); e != nil {
+		t.Fatalf("send failed: %s", e)
+	}
+	if _, e := tl.Send(context.Background(), da, "hello", o); !errors.Is(e, sms.ErrNoReference) {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	// status report is lost
+	time.Sleep(100 * time.Millisecond)
+	if _, e := tl.Send(context.Background(), da, "hello", o); e != nil {
+		t.Fatalf("TP-MR is not released: %s", e)
+	}
+}
+
+func TestSMTLReceive(t *testing.T) {
+	tl, sc := newSMTL(t)
+	tl.ReassemblyTimeout = time.Millisecond * 50
+	oa, _ := sms.ParseAddress("+819012345678")
+
+	rcv := make(chan sms.ReceivedSM, 1)
+	tl.Received = func(m sms.ReceivedSM) {
+		rcv <- m
+	}
+	deliver := func(u sms.UserData, cs sms.Charset) {
+		d := sms.Deliver{
+			OA:   oa,
+			DCS:  sms.GeneralDataCoding{MsgCharset: cs},
+			SCTS: scts(time.Now()),
+			UD:   u}
+		if _, e := sc.TranspReq(d); e != nil {
+			t.Fatalf("deliver failed: %s", e)
+		}
+	}
+
+	// parts in reverse order with duplicate
+	text := strings.Repeat("0123456789", 40)
+	a, _ := sms.NewConcatRef16Allocator(nil, "")
+	ud, cs, _, _ := sms.MakeSeparatedTextRef(text, a)
+	deliver(ud[len(ud)-1], cs)
+	for i := len(ud) - 1; i >= 0; i-- {
+		deliver(ud[i], cs)
+	}
+	select {
+	case m := <-rcv:
+		if m.Text != text || !m.OA.Equal(oa) || len(m.Parts) != len(ud) {
+			t.Errorf("unexpected message %v", m)
+		}
+	default:
+		t.Errorf("message is not received")
+	}
+	select {
+	case m := <-rcv:
+		t.Errorf("unexpected message %v", m)
+	default:
+	}
+
+	// 8bit data
+	data := bytes.Repeat([]byte{0x5a}, 200)
+	ud, _, _ = sms.MakeSeparatedDataRef(data, a)
+	for _, u := range ud {
+		deliver(u, sms.Charset8bitData)
+	}
+	if m := <-rcv; !bytes.Equal(m.Data, data) {
+		t.Errorf("unexpected data % x", m.Data)
+	}
+
+	// incomplete message is discarded after timeout
+	ud, cs, _, _ = sms.MakeSeparatedTextRef(text, a)
+	deliver(ud[0], cs)
+	time.Sleep(tl.ReassemblyTimeout * 2)
+	for _, u := range ud[1:] {
+		deliver(u, cs)
+	}
+	select {
+	case m := <-rcv:
+		t.Errorf("unexpected message %v", m)
+	default:
+	}
+}
@@ -1,42 +1,213 @@
 package sms
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
-func marshalSCTimeStamp(t time.Time) (r []byte) {
-	r = make([]byte, 7)
-	r[0] = int2SemiOctet(t.Year())
-	r[1] = int2SemiOctet(int(t.Month()))
-	r[2] = int2SemiOctet(t.Day())
-	r[3] = int2SemiOctet(t.Hour())
-	r[4] = int2SemiOctet(t.Minute())
-	r[5] = int2SemiOctet(t.Second())
+// centuryPivot is the default boundary of two digit year in time stamp
+const centuryPivot = 100
+
+// SCTimeStamp is semi-octet time stamp value (TP-SCTS, TP-DT)
+type SCTimeStamp [7]byte
+
+// stampAt make SCTimeStamp of SC local time t,
+// in UTC if the time zone is not available
+func stampAt(t time.Time) SCTimeStamp {
+	s, e := TimeToSCTimeStamp(t)
+	if e != nil {
+		s, _ = TimeToSCTimeStamp(t.UTC())
+	}
+	return s
+}
+
+// TimeToSCTimeStamp make SCTimeStamp from time.
+// Time zone offset must be multiple of 15 minutes.
+// Year must be in 2000 to 2099.
+func TimeToSCTimeStamp(t time.Time) (SCTimeStamp, error) {
+	return TimeToSCTimeStampWithPivot(t, centuryPivot)
+}
+
+// TimeToSCTimeStampWithPivot is TimeToSCTimeStamp with century pivot p.
+// Year must be in 2000 to 1999+p or 1900+p to 1999.
+func TimeToSCTimeStampWithPivot(t time.Time, p int) (s SCTimeStamp, e error) {
+	y := t.Year()
+	switch {
+	case y >= 2000 && y < 2000+p:
+		y -= 2000
+	case y >= 1900+p && y < 2000:
+		y -= 1900
+	default:
+		e = InvalidTimeStampError{Field: "year", Value: y}
+		return
+	}
 
 	_, z := t.Zone()
+	if z%900 != 0 {
+		e = InvalidTimeStampError{Field: "time zone", Value: z}
+		return
+	}
 	z /= 900
+	if z > 79 || z < -79 {
+		e = InvalidTimeStampError{Field: "time zone", Value: z}
+		return
+	}
+
+	s[0] = int2SemiOctet(y)
+	s[1] = int2SemiOctet(int(t.Month()))
+	s[2] = int2SemiOctet(t.Day())
+	s[3] = int2SemiOctet(t.Hour())
+	s[4] = int2SemiOctet(t.Minute())
+	s[5] = int2SemiOctet(t.Second())
+	if z < 0 {
+		s[6] = int2SemiOctet(-z) | 0x08
+	} else {
+		s[6] = int2SemiOctet(z)
+	}
+	return
+}
+
+// Time returns time value of this time stamp.
+// Two digit year is 20xx.
+func (s SCTimeStamp) Time() (time.Time, error) {
+	return s.TimeWithPivot(centuryPivot)
+}
+
+// TimeWithPivot returns time value of this time stamp with century pivot p.
+// Two digit year less than p is 20xx, and others are 19xx.
+func (s SCTimeStamp) TimeWithPivot(p int) (time.Time, error) {
+	var d [6]int
+	for i, n := range []string{
+		"year", "month", "day", "hour", "minute", "second"} {
+		if d[i] = strictSemiOctet2Int(s[i]); d[i] < 0 {
+			return time.Time{}, InvalidTimeStampError{
+				Field: n, Value: int(s[i])}
+		}
+	}
+	z := strictSemiOctet2Int(s[6] & 0xf7)
 	if z < 0 {
+		return time.Time{}, InvalidTimeStampError{
+			Field: "time zone", Value: int(s[6])}
+	}
+	if s[6]&0x08 == 0x08 {
 		z = -z
-		r[6] = byte(z % 10)
-		r[6] = (r[6] << 4) | byte(((z/10)%10)&0x07)
-		r[6] = r[6] | 0x08
+	}
+
+	if d[0] < p {
+		d[0] += 2000
 	} else {
-		r[6] = byte(z % 10)
-		r[6] = (r[6] << 4) | byte(((z/10)%10)&0x07)
+		d[0] += 1900
+	}
+	if d[1] < 1 || d[1] > 12 {
+		return time.Time{}, InvalidTimeStampError{
+			Field: "month", Value: d[1]}
+	}
+	if d[2] < 1 || d[2] > daysIn(time.Month(d[1]), d[0]) {
+		return time.Time{}, InvalidTimeStampError{
+			Field: "day", Value: d[2]}
+	}
+	if d[3] > 23 {
+		return time.Time{}, InvalidTimeStampError{
+			Field: "hour", Value: d[3]}
+	}
+	if d[4] > 59 {
+		return time.Time{}, InvalidTimeStampError{
+			Field: "minute", Value: d[4]}
+	}
+	if d[5] > 59 {
+		return time.Time{}, InvalidTimeStampError{
+			Field: "second", Value: d[5]}
+	}
+
+	return time.Date(d[0], time.Month(d[1]), d[2], d[3], d[4], d[5], 0,
+		time.FixedZone("", z*900)), nil
+}
+
+// Zone returns time zone offset in quarters of an hour
+func (s SCTimeStamp) Zone() int {
+	z := semiOctet2Int(s[6] & 0xf7)
+	if s[6]&0x08 == 0x08 {
+		z = -z
+	}
+	return z
+}
+
+// Equal reports a and b are same
+func (s SCTimeStamp) Equal(b SCTimeStamp) bool {
+	return s == b
+}
+
+// IsZero reports this time stamp is not set
+func (s SCTimeStamp) IsZero() bool {
+	return s == SCTimeStamp{}
+}
+
+func (s SCTimeStamp) String() string {
+	t, e := s.Time()
+	if e != nil {
+		return fmt.Sprintf("invalid(% x)", s[:])
+	}
+	return t.String()
+}
+
+// MarshalJSON provide custom marshaller.
+// Zero time stamp is null, and invalid time stamp is hex string of raw octets.
+func (s SCTimeStamp) MarshalJSON() ([]byte, error) {
+	if s.IsZero() {
+		return []byte("null"), nil
+	}
+	t, e := s.Time()
+	if e != nil {
+		return json.Marshal(hex.EncodeToString(s[:]))
+	}
+	return json.Marshal(t)
+}
+
+// UnmarshalJSON provide custom marshaller
+func (s *SCTimeStamp) UnmarshalJSON(b []byte) (e error) {
+	if string(b) == "null" {
+		*s = SCTimeStamp{}
+		return
+	}
+	var t time.Time
+	if e = json.Unmarshal(b, &t); e == nil {
+		*s, e = TimeToSCTimeStamp(t)
+		return
+	}
+	var h string
+	if json.Unmarshal(b, &h) != nil || len(h) != 14 {
+		return
+	}
+	if r, he := hex.DecodeString(h); he == nil {
+		copy(s[:], r)
+		e = nil
 	}
 	return
 }
 
-func unmarshalSCTimeStamp(t [7]byte) time.Time {
-	d := [6]int{}
-	for i := range d {
-		d[i] = semiOctet2Int(t[i])
+func readSCTimeStamp(
+	f string, r *bytes.Reader, o *decoder) (s SCTimeStamp, e error) {
+	var p [7]byte
+	if p, e = read7Bytes(r); e == nil {
+		s = SCTimeStamp(p)
+		if _, e = s.Time(); e != nil && o.reservedBits() {
+			o.warn(f, e.Error())
+			e = nil
+		}
 	}
-	l := semiOctet2Int(t[6] & 0xf7)
-	if t[6]&0x08 == 0x08 {
-		l = -l
+	return
+}
+
+func daysIn(m time.Month, y int) int {
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func strictSemiOctet2Int(b byte) int {
+	if b&0x0f > 0x09 || b&0xf0 > 0x90 {
+		return -1
 	}
-	return time.Date(2000+d[0],
-		time.Month(d[1]), d[2], d[3], d[4], d[5], 0,
-		time.FixedZone("", l*900))
+	return semiOctet2Int(b)
 }
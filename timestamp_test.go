@@ -0,0 +1,108 @@
+package sms_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+)
+
+func TestConvertSCTimeStamp(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		orig := randDate()
+		s, e := sms.TimeToSCTimeStamp(orig)
+		if e != nil {
+			t.Fatal(e)
+		}
+		t.Logf("% x", s[:])
+		ocom, e := s.Time()
+		if e != nil {
+			t.Fatal(e)
+		}
+		if !orig.Equal(ocom) {
+			t.Fatalf("mismatch orig=%s ocom=%s", orig, ocom)
+		}
+		_, oz := orig.Zone()
+		if _, z := ocom.Zone(); z != oz || s.Zone()*900 != oz {
+			t.Fatalf("zone mismatch orig=%d ocom=%d", oz, z)
+		}
+	}
+}
+
+func TestInvalidSCTimeStamp(t *testing.T) {
+	var te sms.InvalidTimeStampError
+	for _, b := range []sms.SCTimeStamp{
+		{0x11, 0x31, 0x22, 0x41, 0x52, 0x04, 0x63}, // month 13
+		{0x11, 0x20, 0x03, 0x41, 0x52, 0x04, 0x63}, // Feb 30
+		{0x11, 0x30, 0x22, 0x42, 0x52, 0x04, 0x63}, // hour 24
+		{0x11, 0x30, 0x22, 0x41, 0x06, 0x04, 0x63}, // minute 60
+		{0x11, 0x30, 0x22, 0x41, 0x52, 0x0a, 0x63}, // invalid digit
+	} {
+		if _, e := b.Time(); !errors.As(e, &te) {
+			t.Fatalf("invalid time stamp % x is accepted", b[:])
+		}
+		t.Log(te)
+	}
+
+	b := []byte{
+		0x04, 0x04, 0x80, 0x21, 0x43, 0x00, 0x08, 0x11,
+		0x31, 0x22, 0x41, 0x52, 0x04, 0x63, 0x00}
+	if _, e := sms.UnmarshalTPMT(b); !errors.As(e, &te) {
+		t.Fatalf("invalid TP-SCTS is decoded: %v", e)
+	}
+
+	_, e := sms.TimeToSCTimeStamp(time.Date(
+		2011, time.March, 22, 14, 25, 40, 0, time.FixedZone("", 5*60)))
+	if !errors.As(e, &te) {
+		t.Fatalf("sub-quarter-hour zone is accepted: %v", e)
+	}
+}
+
+func TestCenturyPivot(t *testing.T) {
+	s := sms.SCTimeStamp{0x99, 0x21, 0x13, 0x32, 0x95, 0x95, 0x00}
+	r, e := s.TimeWithPivot(70)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if r.Year() != 1999 {
+		t.Fatalf("year mismatch: %d", r.Year())
+	}
+	if _, e = sms.TimeToSCTimeStampWithPivot(r.AddDate(71, 0, 0), 70); e == nil {
+		t.Fatal("out of range year is accepted")
+	}
+	if r, e = s.Time(); e != nil || r.Year() != 2099 {
+		t.Fatalf("default pivot is changed: %d %v", r.Year(), e)
+	}
+	if _, e = sms.TimeToSCTimeStamp(r.AddDate(-100, 0, 0)); e == nil {
+		t.Fatal("out of range year is accepted")
+	}
+}
+
+func TestMarshalJSON_timestamp(t *testing.T) {
+	for _, s := range []sms.SCTimeStamp{
+		{},
+		{0x11, 0x30, 0x22, 0x41, 0x52, 0x04, 0x63},
+		{0x11, 0x31, 0x22, 0x41, 0x52, 0x04, 0x63}, // month 13
+	} {
+		b, e := json.Marshal(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+		t.Log(string(b))
+		var r sms.SCTimeStamp
+		if e = json.Unmarshal(b, &r); e != nil {
+			t.Fatal(e)
+		}
+		if r != s {
+			t.Fatalf("mismatch orig=% x ocom=% x", s[:], r[:])
+		}
+	}
+
+	for _, p := range []interface{}{sms.Deliver{}, sms.StatusReport{}} {
+		if _, e := json.Marshal(p); e != nil {
+			t.Fatalf("empty %T can't be marshalled: %v", p, e)
+		}
+	}
+}
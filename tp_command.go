@@ -13,20 +13,87 @@ type Command struct {
 
 	SRR bool `json:"tp-srr"` // O / Status Report Request
 
-	TMR byte     `json:"tp-mr"`           // M / Message Reference for TP
-	PID byte     `json:"tp-pid"`          // M / Protocol Identifier
-	CT  byte     `json:"tp-ct"`           // M / Command Type
-	MN  byte     `json:"tp-mn"`           // M / Message Number
-	DA  Address  `json:"tp-da"`           // M / Destination Address
-	CD  UserData `json:"tp-cd,omitempty"` // O / Command Data
+	TMR byte        `json:"tp-mr"`           // M / Message Reference for TP
+	PID byte        `json:"tp-pid"`          // M / Protocol Identifier
+	CT  CommandType `json:"tp-ct"`           // M / Command Type
+	MN  byte        `json:"tp-mn"`           // M / Message Number
+	DA  Address     `json:"tp-da"`           // M / Destination Address
+	CD  UserData    `json:"tp-cd,omitempty"` // O / Command Data
 }
 
 var binDCS = GeneralDataCoding{MsgCharset: Charset8bitData}
 
+// CommandType is TP-CT value of Command
+type CommandType byte
+
+const (
+	// CommandEnquiry is enquiry relating to previously submitted short message
+	CommandEnquiry CommandType = 0x00
+	// CommandCancelSRR cancel status report request relating to
+	// previously submitted short message
+	CommandCancelSRR CommandType = 0x01
+	// CommandDelete delete previously submitted short message
+	CommandDelete CommandType = 0x02
+	// CommandEnableSRR enable status report request relating to
+	// previously submitted short message
+	CommandEnableSRR CommandType = 0x03
+)
+
+func (c CommandType) String() string {
+	switch c {
+	case CommandEnquiry:
+		return "Enquiry relating to previously submitted short message"
+	case CommandCancelSRR:
+		return "Cancel Status Report Request relating to" +
+			" previously submitted short message"
+	case CommandDelete:
+		return "Delete previously submitted Short Message"
+	case CommandEnableSRR:
+		return "Enable Status Report Request relating to" +
+			" previously submitted short message"
+	}
+	if c >= 0xe0 {
+		return fmt.Sprintf("SC specific(%d)", c)
+	}
+	return fmt.Sprintf("Reserved(%d)", c)
+}
+
+func (c CommandType) reserved() bool {
+	return c > CommandEnableSRR && c < 0xe0
+}
+
+// EnquiryCommand make Command that enquire status of the message
+// previously submitted with TP-MR mn to da.
+// Status report is requested for the result.
+func EnquiryCommand(mr, mn byte, da Address) Command {
+	return Command{SRR: true, TMR: mr, CT: CommandEnquiry, MN: mn, DA: da}
+}
+
+// CancelSRRCommand make Command that cancel status report request
+// of the message previously submitted with TP-MR mn to da
+func CancelSRRCommand(mr, mn byte, da Address) Command {
+	return Command{TMR: mr, CT: CommandCancelSRR, MN: mn, DA: da}
+}
+
+// DeleteCommand make Command that delete the message
+// previously submitted with TP-MR mn to da
+func DeleteCommand(mr, mn byte, da Address) Command {
+	return Command{TMR: mr, CT: CommandDelete, MN: mn, DA: da}
+}
+
+// EnableSRRCommand make Command that enable status report request
+// of the message previously submitted with TP-MR mn to da
+func EnableSRRCommand(mr, mn byte, da Address) Command {
+	return Command{TMR: mr, CT: CommandEnableSRR, MN: mn, DA: da}
+}
+
 // MarshalTP output byte data of this TPDU
 func (d Command) MarshalTP() []byte {
-	w := new(bytes.Buffer)
+	return d.AppendTP(nil)
+}
 
+// AppendTP append byte data of this TPDU to dst
+func (d Command) AppendTP(dst []byte) []byte {
 	b := byte(0x02)
 	if d.SRR {
 		b |= 0x20
@@ -34,27 +101,45 @@ func (d Command) MarshalTP() []byte {
 	if len(d.CD.UDH) != 0 {
 		b |= 0x40
 	}
-	w.WriteByte(b)
-	w.WriteByte(d.TMR)
-	w.WriteByte(d.PID)
-	w.WriteByte(d.CT)
-	w.WriteByte(d.MN)
-	l, a := d.DA.Marshal()
-	w.WriteByte(l)
-	w.Write(a)
-	d.CD.write(w, binDCS)
+	dst = append(dst, b, d.TMR, d.PID, byte(d.CT), d.MN)
+	dst = d.DA.appendTP(dst)
+	return d.CD.appendTo(dst, binDCS)
+}
 
-	return w.Bytes()
+// Validate check consistency of field values and returns ValidationError
+func (d Command) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	e.checkRPAddress("RP-DA", d.SCA)
+	e.checkPID(d.PID)
+	if d.CT.reserved() {
+		e.add("TP-CT", "reserved value 0x%02x", d.CT)
+	}
+	e.checkAddress("TP-DA", d.DA, false)
+	e.checkUD("TP-CD", d.CD, binDCS)
+	return e.result()
 }
 
 // MarshalRP output byte data of this RPDU
 func (d Command) MarshalRP() []byte {
-	return d.rpData.marshal(true, d.MarshalTP())
+	return d.AppendRP(nil)
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d Command) AppendRP(dst []byte) []byte {
+	dst, p := d.rpData.appendRP(true, dst)
+	return setLength(d.AppendTP(dst), p)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d Command) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d Command) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalCommand decode Submit from bytes
@@ -64,56 +149,76 @@ func UnmarshalCommand(b []byte) (d Command, e error) {
 }
 
 // UnmarshalTP get data of this TPDU
-func (d *Command) UnmarshalTP(b []byte) (e error) {
+func (d *Command) UnmarshalTP(b []byte) error {
+	return d.unmarshalTP(b, nil)
+}
+
+func (d *Command) unmarshalTP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("TP", b, o)
+	r.next("TP-MTI")
 	if len(b) == 0 {
-		return io.EOF
+		return r.fail(io.EOF)
 	}
 	if b[0]&0x03 != 0x02 {
-		return UnexpectedMessageTypeError{
-			Expected: 0x02, Actual: b[0] & 0x03}
+		return r.fail(UnexpectedMessageTypeError{
+			Expected: 0x02, Actual: b[0] & 0x03})
 	}
 
 	d.SRR = b[0]&0x20 == 0x20
+	r.skip(1)
 
-	r := bytes.NewReader(b[1:])
-
+	r.next("TP-MR")
 	if d.TMR, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
+	r.next("TP-PID")
 	if d.PID, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
-	if d.CT, e = r.ReadByte(); e != nil {
-		return
+	r.next("TP-CT")
+	var ct byte
+	if ct, e = r.ReadByte(); e != nil {
+		return r.fail(e)
 	}
+	d.CT = CommandType(ct)
+	r.next("TP-MN")
 	if d.MN, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
-	if d.DA, e = readTPAddr(r); e != nil {
-		return
+	r.next("TP-DA")
+	if d.DA, e = readTPAddr(r.Reader); e != nil {
+		return r.fail(e)
 	}
 
-	if e = d.CD.read(r, binDCS, b[0]&0x40 == 0x40); e != nil {
+	if e = d.CD.read("TP-CD", r, binDCS, b[0]&0x40 == 0x40, o); e != nil {
 		return
 	}
-	if r.Len() != 0 {
-		e = ErrExtraData
-	}
+	e = o.checkTrailing(r)
 	return
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *Command) UnmarshalRP(b []byte) (e error) {
-	if b, e = d.unmarshal(true, b); e == nil {
-		e = d.UnmarshalTP(b)
+func (d *Command) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *Command) unmarshalRP(b []byte, o *decoder) (e error) {
+	var tp []byte
+	if tp, e = d.unmarshal(true, b, o); e == nil {
+		e = innerError(d.unmarshalTP(tp, o), b, tp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *Command) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *Command) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *Command) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
@@ -161,7 +266,7 @@ func (d Command) String() string {
 	fmt.Fprintf(w, "%sTP-SRR:  %s\n", Indent, srrStat(d.SRR))
 	fmt.Fprintf(w, "%sTP-MR:   %d\n", Indent, d.TMR)
 	fmt.Fprintf(w, "%sTP-PID:  %s\n", Indent, pidStat(d.PID))
-	fmt.Fprintf(w, "%sTP-CT:   %d\n", Indent, d.CT)
+	fmt.Fprintf(w, "%sTP-CT:   %s\n", Indent, d.CT)
 	fmt.Fprintf(w, "%sTP-MN:   %d\n", Indent, d.MN)
 	fmt.Fprintf(w, "%sTP-DA:   %s\n", Indent, d.DA)
 	if !d.CD.isEmpty() {
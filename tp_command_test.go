@@ -73,7 +73,7 @@ func randCommand() sms.Command {
 		SRR: randBool(),
 		TMR: randByte(),
 		PID: randByte(),
-		CT:  randByte(),
+		CT:  sms.CommandType(randByte()),
 		MN:  randByte(),
 		DA:  randAddress()}
 	orig.CD = randUD(sms.GeneralDataCoding{MsgCharset: sms.Charset8bitData})
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"time"
 )
 
 // Deliver is TPDU message from SC to MS
@@ -17,17 +16,20 @@ type Deliver struct {
 	SRI bool `json:"tp-sri"` // O / Status Report Indication (true=status report shall be returned)
 	RP  bool `json:"tp-rp"`  // M / Reply Path
 
-	OA   Address    `json:"tp-oa"`           // M / Originating Address
-	PID  byte       `json:"tp-pid"`          // M / Protocol Identifier
-	DCS  DataCoding `json:"tp-dcs"`          // M / Data Coding Scheme
-	SCTS time.Time  `json:"tp-scts"`         // M / Service Centre Time Stamp
-	UD   UserData   `json:"tp-ud,omitempty"` // O / User Data
+	OA   Address     `json:"tp-oa"`           // M / Originating Address
+	PID  byte        `json:"tp-pid"`          // M / Protocol Identifier
+	DCS  DataCoding  `json:"tp-dcs"`          // M / Data Coding Scheme
+	SCTS SCTimeStamp `json:"tp-scts"`         // M / Service Centre Time Stamp
+	UD   UserData    `json:"tp-ud,omitempty"` // O / User Data
 }
 
 // MarshalTP output byte data of this TPDU
 func (d Deliver) MarshalTP() []byte {
-	w := new(bytes.Buffer)
+	return d.AppendTP(nil)
+}
 
+// AppendTP append byte data of this TPDU to dst
+func (d Deliver) AppendTP(dst []byte) []byte {
 	b := byte(0x00)
 	if !d.MMS {
 		b |= 0x04
@@ -44,30 +46,51 @@ func (d Deliver) MarshalTP() []byte {
 	if d.RP {
 		b |= 0x80
 	}
-	w.WriteByte(b)
-	l, a := d.OA.Marshal()
-	w.WriteByte(l)
-	w.Write(a)
-	w.WriteByte(d.PID)
+	dst = append(dst, b)
+	dst = d.OA.appendTP(dst)
+	dst = append(dst, d.PID)
 	if d.DCS == nil {
-		w.WriteByte(0x00)
+		dst = append(dst, 0x00)
 	} else {
-		w.WriteByte(d.DCS.Marshal())
+		dst = append(dst, d.DCS.Marshal())
 	}
-	w.Write(marshalSCTimeStamp(d.SCTS))
-	d.UD.write(w, d.DCS)
+	dst = append(dst, d.SCTS[:]...)
+	return d.UD.appendTo(dst, d.DCS)
+}
 
-	return w.Bytes()
+// Validate check consistency of field values and returns ValidationError
+func (d Deliver) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	e.checkRPAddress("RP-OA", d.SCA)
+	e.checkAddress("TP-OA", d.OA, true)
+	e.checkPID(d.PID)
+	e.checkDCS(d.DCS)
+	e.checkSCTS("TP-SCTS", d.SCTS)
+	e.checkUD("TP-UD", d.UD, d.DCS)
+	return e.result()
 }
 
 // MarshalRP output byte data of this RPDU
 func (d Deliver) MarshalRP() []byte {
-	return d.rpData.marshal(false, d.MarshalTP())
+	return d.AppendRP(nil)
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d Deliver) AppendRP(dst []byte) []byte {
+	dst, p := d.rpData.appendRP(false, dst)
+	return setLength(d.AppendTP(dst), p)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d Deliver) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d Deliver) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalDeliver decode Deliver from bytes
@@ -77,57 +100,72 @@ func UnmarshalDeliver(b []byte) (d Deliver, e error) {
 }
 
 // UnmarshalTP get data of this TPDU
-func (d *Deliver) UnmarshalTP(b []byte) (e error) {
+func (d *Deliver) UnmarshalTP(b []byte) error {
+	return d.unmarshalTP(b, nil)
+}
+
+func (d *Deliver) unmarshalTP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("TP", b, o)
+	r.next("TP-MTI")
 	if len(b) == 0 {
-		return io.EOF
+		return r.fail(io.EOF)
 	}
 	if b[0]&0x03 != 0x00 {
-		return UnexpectedMessageTypeError{
-			Expected: 0x00, Actual: b[0] & 0x03}
+		return r.fail(UnexpectedMessageTypeError{
+			Expected: 0x00, Actual: b[0] & 0x03})
 	}
 
 	d.MMS = b[0]&0x04 != 0x04
 	d.LP = b[0]&0x08 == 0x08
 	d.SRI = b[0]&0x20 == 0x20
 	d.RP = b[0]&0x80 == 0x80
+	r.skip(1)
 
-	r := bytes.NewReader(b[1:])
-
-	if d.OA, e = readTPAddr(r); e != nil {
-		return
+	r.next("TP-OA")
+	if d.OA, e = readTPAddr(r.Reader); e != nil {
+		return r.fail(e)
 	}
+	r.next("TP-PID")
 	if d.PID, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
-	if d.DCS, e = readDataCoding(r); e != nil {
-		return
+	r.next("TP-DCS")
+	if d.DCS, e = readDataCoding(r.Reader, o); e != nil {
+		return r.fail(e)
 	}
-	var tmp [7]byte
-	if tmp, e = read7Bytes(r); e != nil {
-		return
+	r.next("TP-SCTS")
+	if d.SCTS, e = readSCTimeStamp("TP-SCTS", r.Reader, o); e != nil {
+		return r.fail(e)
 	}
-	d.SCTS = unmarshalSCTimeStamp(tmp)
-	if e = d.UD.read(r, d.DCS, b[0]&0x40 == 0x40); e != nil {
+	if e = d.UD.read("TP-UD", r, d.DCS, b[0]&0x40 == 0x40, o); e != nil {
 		return
 	}
-	if r.Len() != 0 {
-		e = ErrExtraData
-	}
+	e = o.checkTrailing(r)
 	return
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *Deliver) UnmarshalRP(b []byte) (e error) {
-	if b, e = d.unmarshal(false, b); e == nil {
-		e = d.UnmarshalTP(b)
+func (d *Deliver) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *Deliver) unmarshalRP(b []byte, o *decoder) (e error) {
+	var tp []byte
+	if tp, e = d.unmarshal(false, b, o); e == nil {
+		e = innerError(d.unmarshalTP(tp, o), b, tp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *Deliver) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *Deliver) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *Deliver) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
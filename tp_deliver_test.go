@@ -37,9 +37,9 @@ func TestDecodeDeliver(t *testing.T) {
 			Compressed: false,
 			MsgClass:   sms.NoMessageClass,
 			MsgCharset: sms.CharsetUCS2},
-		SCTS: time.Date(
+		SCTS: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("unknown", 9*60*60)),
+			time.FixedZone("unknown", 9*60*60))),
 		UD: sms.UserData{Text: "あいうえお"}}
 	p.UD.UDH = append(p.UD.UDH, sms.ConcatenatedSM{
 		RefNum: 0x84, MaxNum: 0x0a, SeqNum: 0x01})
@@ -58,7 +58,7 @@ func randDeliver() sms.Deliver {
 		OA:   randAddress(),
 		PID:  randByte(),
 		DCS:  randDCS(),
-		SCTS: randDate(),
+		SCTS: randSCTS(),
 	}
 
 	orig.UD = randUD(orig.DCS)
@@ -246,9 +246,9 @@ func TestMarshalJSON_deliver(t *testing.T) {
 			Compressed: false,
 			MsgClass:   sms.NoMessageClass,
 			MsgCharset: sms.CharsetUCS2},
-		SCTS: time.Date(
+		SCTS: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("unknown", 9*60*60)),
+			time.FixedZone("unknown", 9*60*60))),
 		UD: sms.UserData{Text: "あいうえお"}}
 	p.UD.UDH = append(p.UD.UDH, sms.ConcatenatedSM{
 		RefNum: 0x84, MaxNum: 0x0a, SeqNum: 0x01})
@@ -23,15 +23,18 @@ type DeliverReport struct {
 
 // MarshalTP output byte data of this TPDU
 func (d DeliverReport) MarshalTP() []byte {
-	w := new(bytes.Buffer)
+	return d.AppendTP(nil)
+}
 
+// AppendTP append byte data of this TPDU to dst
+func (d DeliverReport) AppendTP(dst []byte) []byte {
 	b := byte(0x00)
 	if len(d.UD.UDH) != 0 {
 		b |= 0x40
 	}
-	w.WriteByte(b)
+	dst = append(dst, b)
 	if d.FCS&0x80 == 0x80 {
-		w.WriteByte(d.FCS)
+		dst = append(dst, d.FCS)
 	}
 	b = byte(0x00)
 	if d.PID != nil {
@@ -43,33 +46,62 @@ func (d DeliverReport) MarshalTP() []byte {
 	if len(d.UD.Text) != 0 || len(d.UD.UDH) != 0 {
 		b |= 0x04
 	}
-	w.WriteByte(b)
+	dst = append(dst, b)
 	if d.PID != nil {
-		w.WriteByte(*d.PID)
+		dst = append(dst, *d.PID)
 	}
 	if d.DCS != nil {
-		w.WriteByte(d.DCS.Marshal())
+		dst = append(dst, d.DCS.Marshal())
 	}
 	if !d.UD.isEmpty() {
-		d.UD.write(w, d.DCS)
+		dst = d.UD.appendTo(dst, d.DCS)
 	}
+	return dst
+}
 
-	return w.Bytes()
+// Validate check consistency of field values and returns ValidationError
+func (d DeliverReport) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	e.checkFCS(d.FCS)
+	if d.FCS&0x80 == 0x80 {
+		e.checkRPCause(d.CS)
+	}
+	if d.PID != nil {
+		e.checkPID(*d.PID)
+	}
+	e.checkDCS(d.DCS)
+	e.checkUD("TP-UD", d.UD, d.DCS)
+	return e.result()
 }
 
 // MarshalRP output byte data of this RPDU
 func (d DeliverReport) MarshalRP() []byte {
+	return d.AppendRP(nil)
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d DeliverReport) AppendRP(dst []byte) []byte {
+	var p int
 	if d.FCS&0x80 == 0x80 {
 		rp := RpError{RMR: d.RMR, CS: d.CS, DIAG: d.DIAG}
-		return rp.marshalRP(true, d.MarshalTP())
+		dst, p = rp.appendRP(dst, true, true)
+	} else {
+		rp := RpAck{RMR: d.RMR}
+		dst, p = rp.appendRP(dst, true, true)
 	}
-	rp := RpAck{RMR: d.RMR}
-	return rp.marshalRP(true, d.MarshalTP())
+	return setLength(d.AppendTP(dst), p)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d DeliverReport) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d DeliverReport) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalDeliverReport decode DeliverReport from bytes
@@ -79,82 +111,102 @@ func UnmarshalDeliverReport(b []byte) (d DeliverReport, e error) {
 }
 
 // UnmarshalTP get data of this TPDU
-func (d *DeliverReport) UnmarshalTP(b []byte) (e error) {
+func (d *DeliverReport) UnmarshalTP(b []byte) error {
+	return d.unmarshalTP(b, nil)
+}
+
+func (d *DeliverReport) unmarshalTP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("TP", b, o)
+	r.next("TP-MTI")
 	if len(b) == 0 {
-		return io.EOF
+		return r.fail(io.EOF)
 	}
 	if b[0]&0x03 != 0x00 {
-		return UnexpectedMessageTypeError{
-			Expected: 0x00, Actual: b[0] & 0x03}
+		return r.fail(UnexpectedMessageTypeError{
+			Expected: 0x00, Actual: b[0] & 0x03})
 	}
+	r.skip(1)
 
-	r := bytes.NewReader(b[1:])
-
+	r.next("TP-PI")
 	var pi byte
 	if pi, e = r.ReadByte(); e == nil && pi&0x80 == 0x80 {
 		d.FCS = pi
+		r.rename("TP-FCS")
+		r.next("TP-PI")
 		pi, e = r.ReadByte()
 	}
 	if e != nil {
-		return
+		return r.fail(e)
 	}
 	if pi&0x01 == 0x01 {
+		r.next("TP-PID")
 		var p byte
 		if p, e = r.ReadByte(); e != nil {
-			return
+			return r.fail(e)
 		}
 		d.PID = &p
 	}
 	if pi&0x02 == 0x02 {
-		if d.DCS, e = readDataCoding(r); e != nil {
-			return
+		r.next("TP-DCS")
+		if d.DCS, e = readDataCoding(r.Reader, o); e != nil {
+			return r.fail(e)
 		}
 	}
 	if pi&0x04 == 0x04 {
-		e = d.UD.read(r, d.DCS, b[0]&0x40 == 0x40)
+		e = d.UD.read("TP-UD", r, d.DCS, b[0]&0x40 == 0x40, o)
 		if e != nil {
 			return
 		}
 	}
-	if r.Len() != 0 {
-		e = ErrExtraData
-	}
+	e = o.checkTrailing(r)
 	return
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *DeliverReport) UnmarshalRP(b []byte) (e error) {
+func (d *DeliverReport) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *DeliverReport) unmarshalRP(b []byte, o *decoder) (e error) {
 	if len(b) == 0 {
-		return io.EOF
+		return DecodeError{Layer: "RP", Field: "RP-MTI", Err: io.EOF}
 	}
+	var tp []byte
 	switch b[0] & 0x07 {
 	case 0x02:
 		rp := RpAck{}
-		b, e = rp.unmarshalRP(true, b)
-		if e == nil {
+		if tp, e = rp.unmarshalRP(true, b, o); e == nil {
 			d.RMR = rp.RMR
 		}
 	case 0x04:
 		rp := RpError{}
-		b, e = rp.unmarshalRP(true, b)
-		if e == nil {
+		if tp, e = rp.unmarshalRP(true, b, o); e == nil {
 			d.RMR = rp.RMR
 			d.CS = rp.CS
 			d.DIAG = rp.DIAG
 		}
+	default:
+		e = DecodeError{Layer: "RP", Field: "RP-MTI",
+			Err: UnexpectedMessageTypeError{Expected: 0x02, Actual: b[0]}}
 	}
-	if b == nil {
-		e = io.EOF
-	} else {
-		e = d.UnmarshalTP(b)
+	if e == nil && tp == nil {
+		e = DecodeError{
+			Layer: "RP", Field: "RP-User-Data", Offset: len(b), Err: io.EOF}
+	} else if e == nil {
+		e = innerError(d.unmarshalTP(tp, o), b, tp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *DeliverReport) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *DeliverReport) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *DeliverReport) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"time"
 )
 
 // StatusReport is TPDU message from SC to MS
@@ -16,20 +15,23 @@ type StatusReport struct {
 	LP  bool `json:"tp-lp"`  // O / Loop Prevention
 	SRQ bool `json:"tp-srq"` // M / Status Report Qualifier (true=status report shall be returned)
 
-	TMR  byte       `json:"tp-mr"`            // M / Message Reference
-	RA   Address    `json:"tp-ra"`            // M / Destination Address
-	SCTS time.Time  `json:"tp-scts"`          // M / Service Centre Time Stamp
-	DT   time.Time  `json:"tp-dt"`            // M / Discharge Time
-	ST   byte       `json:"tp-st"`            // M / Status
-	PID  *byte      `json:"tp-pid,omitempty"` // O / Protocol Identifier
-	DCS  DataCoding `json:"tp-dcs,omitempty"` // O / Data Coding Scheme
-	UD   UserData   `json:"tp-ud,omitempty"`  // O / User Data
+	TMR  byte        `json:"tp-mr"`            // M / Message Reference
+	RA   Address     `json:"tp-ra"`            // M / Destination Address
+	SCTS SCTimeStamp `json:"tp-scts"`          // M / Service Centre Time Stamp
+	DT   SCTimeStamp `json:"tp-dt"`            // M / Discharge Time
+	ST   byte        `json:"tp-st"`            // M / Status
+	PID  *byte       `json:"tp-pid,omitempty"` // O / Protocol Identifier
+	DCS  DataCoding  `json:"tp-dcs,omitempty"` // O / Data Coding Scheme
+	UD   UserData    `json:"tp-ud,omitempty"`  // O / User Data
 }
 
 // MarshalTP output byte data of this TPDU
 func (d StatusReport) MarshalTP() []byte {
-	w := new(bytes.Buffer)
+	return d.AppendTP(nil)
+}
 
+// AppendTP append byte data of this TPDU to dst
+func (d StatusReport) AppendTP(dst []byte) []byte {
 	b := byte(0x02)
 	if !d.MMS {
 		b |= 0x04
@@ -43,14 +45,11 @@ func (d StatusReport) MarshalTP() []byte {
 	if len(d.UD.UDH) != 0 {
 		b |= 0x40
 	}
-	w.WriteByte(b)
-	w.WriteByte(d.TMR)
-	l, a := d.RA.Marshal()
-	w.WriteByte(l)
-	w.Write(a)
-	w.Write(marshalSCTimeStamp(d.SCTS))
-	w.Write(marshalSCTimeStamp(d.DT))
-	w.WriteByte(d.ST)
+	dst = append(dst, b, d.TMR)
+	dst = d.RA.appendTP(dst)
+	dst = append(dst, d.SCTS[:]...)
+	dst = append(dst, d.DT[:]...)
+	dst = append(dst, d.ST)
 	b = byte(0x00)
 	if d.PID != nil {
 		b |= 0x01
@@ -62,29 +61,57 @@ func (d StatusReport) MarshalTP() []byte {
 		b |= 0x04
 	}
 	if b == 0x00 {
-		return w.Bytes()
+		return dst
 	}
-	w.WriteByte(b)
+	dst = append(dst, b)
 	if d.PID != nil {
-		w.WriteByte(*d.PID)
+		dst = append(dst, *d.PID)
 	}
 	if d.DCS != nil {
-		w.WriteByte(d.DCS.Marshal())
+		dst = append(dst, d.DCS.Marshal())
 	}
 	if !d.UD.isEmpty() {
-		d.UD.write(w, d.DCS)
+		dst = d.UD.appendTo(dst, d.DCS)
 	}
-	return w.Bytes()
+	return dst
+}
+
+// Validate check consistency of field values and returns ValidationError
+func (d StatusReport) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	e.checkRPAddress("RP-OA", d.SCA)
+	e.checkAddress("TP-RA", d.RA, true)
+	e.checkSCTS("TP-SCTS", d.SCTS)
+	e.checkSCTS("TP-DT", d.DT)
+	if d.PID != nil {
+		e.checkPID(*d.PID)
+	}
+	e.checkDCS(d.DCS)
+	e.checkUD("TP-UD", d.UD, d.DCS)
+	return e.result()
 }
 
 // MarshalRP output byte data of this RPDU
 func (d StatusReport) MarshalRP() []byte {
-	return d.rpData.marshal(false, d.MarshalTP())
+	return d.AppendRP(nil)
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d StatusReport) AppendRP(dst []byte) []byte {
+	dst, p := d.rpData.appendRP(false, dst)
+	return setLength(d.AppendTP(dst), p)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d StatusReport) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d StatusReport) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalStatusReport decode StatusReport from bytes
@@ -94,82 +121,100 @@ func UnmarshalStatusReport(b []byte) (d StatusReport, e error) {
 }
 
 // UnmarshalTP get data of this TPDU
-func (d *StatusReport) UnmarshalTP(b []byte) (e error) {
+func (d *StatusReport) UnmarshalTP(b []byte) error {
+	return d.unmarshalTP(b, nil)
+}
+
+func (d *StatusReport) unmarshalTP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("TP", b, o)
+	r.next("TP-MTI")
 	if len(b) == 0 {
-		return io.EOF
+		return r.fail(io.EOF)
 	}
 	if b[0]&0x03 != 0x02 {
-		return UnexpectedMessageTypeError{
-			Expected: 0x02, Actual: b[0] & 0x03}
+		return r.fail(UnexpectedMessageTypeError{
+			Expected: 0x02, Actual: b[0] & 0x03})
 	}
 
 	d.MMS = b[0]&0x04 != 0x04
 	d.LP = b[0]&0x08 == 0x08
 	d.SRQ = b[0]&0x20 == 0x20
+	r.skip(1)
 
-	r := bytes.NewReader(b[1:])
-
+	r.next("TP-MR")
 	if d.TMR, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
-	if d.RA, e = readTPAddr(r); e != nil {
-		return
+	r.next("TP-RA")
+	if d.RA, e = readTPAddr(r.Reader); e != nil {
+		return r.fail(e)
 	}
-	var p [7]byte
-	if p, e = read7Bytes(r); e != nil {
-		return
+	r.next("TP-SCTS")
+	if d.SCTS, e = readSCTimeStamp("TP-SCTS", r.Reader, o); e != nil {
+		return r.fail(e)
 	}
-	d.SCTS = unmarshalSCTimeStamp(p)
-	if p, e = read7Bytes(r); e != nil {
-		return
+	r.next("TP-DT")
+	if d.DT, e = readSCTimeStamp("TP-DT", r.Reader, o); e != nil {
+		return r.fail(e)
 	}
-	d.DT = unmarshalSCTimeStamp(p)
+	r.next("TP-ST")
 	if d.ST, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
 	if r.Len() == 0 {
 		return
 	}
+	r.next("TP-PI")
 	var pi byte
 	if pi, e = r.ReadByte(); e != nil {
 		e = nil
 		return
 	}
 	if pi&0x01 == 0x01 {
+		r.next("TP-PID")
 		var p byte
 		if p, e = r.ReadByte(); e != nil {
-			return
+			return r.fail(e)
 		}
 		d.PID = &p
 	}
 	if pi&0x02 == 0x02 {
-		if d.DCS, e = readDataCoding(r); e != nil {
-			return
+		r.next("TP-DCS")
+		if d.DCS, e = readDataCoding(r.Reader, o); e != nil {
+			return r.fail(e)
 		}
 	}
 	if pi&0x04 == 0x04 {
-		if e = d.UD.read(r, d.DCS, b[0]&0x40 == 0x40); e != nil {
+		if e = d.UD.read("TP-UD", r, d.DCS, b[0]&0x40 == 0x40, o); e != nil {
 			return
 		}
 	}
-	if r.Len() != 0 {
-		e = ErrExtraData
-	}
+	e = o.checkTrailing(r)
 	return
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *StatusReport) UnmarshalRP(b []byte) (e error) {
-	if b, e = d.unmarshal(false, b); e == nil {
-		e = d.UnmarshalTP(b)
+func (d *StatusReport) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *StatusReport) unmarshalRP(b []byte, o *decoder) (e error) {
+	var tp []byte
+	if tp, e = d.unmarshal(false, b, o); e == nil {
+		e = innerError(d.unmarshalTP(tp, o), b, tp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *StatusReport) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *StatusReport) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *StatusReport) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
@@ -233,7 +278,7 @@ func (d StatusReport) String() string {
 	fmt.Fprintf(w, "%sTP-MR:   %d\n", Indent, d.TMR)
 	fmt.Fprintf(w, "%sTP-RA:   %s\n", Indent, d.RA)
 	fmt.Fprintf(w, "%sTP-SCTS: %s\n", Indent, d.SCTS)
-	fmt.Fprintf(w, "%sTP-DT:   %s\n", Indent, d.SCTS)
+	fmt.Fprintf(w, "%sTP-DT:   %s\n", Indent, d.DT)
 	fmt.Fprintf(w, "%sTP-ST:   %s\n", Indent, stStat(d.ST))
 	if d.PID != nil {
 		fmt.Fprintf(w, "%sTP-PID:  %s\n", Indent, pidStat(*d.PID))
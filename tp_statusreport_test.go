@@ -30,12 +30,12 @@ func TestDecodeStatusReport(t *testing.T) {
 		SRQ: false,
 		TMR: 0x00,
 		RA:  sms.Address{TON: 0, NPI: 0},
-		SCTS: time.Date(
+		SCTS: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("unknown", 9*60*60)),
-		DT: time.Date(
+			time.FixedZone("unknown", 9*60*60))),
+		DT: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("unknown", 9*60*60)),
+			time.FixedZone("unknown", 9*60*60))),
 		ST: 0x00}
 	p.RA.Addr, _ = teldata.ParseTBCD("1234")
 
@@ -50,12 +50,12 @@ func TestMarshalJSON_statusreport(t *testing.T) {
 		SRQ: false,
 		TMR: 0x00,
 		RA:  sms.Address{TON: 0, NPI: 0},
-		SCTS: time.Date(
+		SCTS: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("unknown", 9*60*60)),
-		DT: time.Date(
+			time.FixedZone("unknown", 9*60*60))),
+		DT: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("unknown", 9*60*60)),
+			time.FixedZone("unknown", 9*60*60))),
 		ST: 0x00}
 	p.RA.Addr, _ = teldata.ParseTBCD("1234")
 	t.Log(p.String())
@@ -80,8 +80,8 @@ func randStatusreport() sms.StatusReport {
 		SRQ:  randBool(),
 		TMR:  randByte(),
 		RA:   randAddress(),
-		SCTS: randDate(),
-		DT:   randDate(),
+		SCTS: randSCTS(),
+		DT:   randSCTS(),
 		ST:   randByte(),
 		DCS:  sms.UnmarshalDataCoding(randByte()),
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 )
 
 // Submit is TPDU message from MS to SC
@@ -25,23 +26,22 @@ type Submit struct {
 
 // MarshalTP output byte data of this TPDU
 func (d Submit) MarshalTP() []byte {
-	w := new(bytes.Buffer)
+	return d.AppendTP(nil)
+}
 
+// AppendTP append byte data of this TPDU to dst
+func (d Submit) AppendTP(dst []byte) []byte {
 	b := byte(0x01)
 	if d.RD {
 		b |= 0x04
 	}
-	var vp []byte
-	switch v := d.VP.(type) {
+	switch d.VP.(type) {
 	case VPRelative:
 		b |= 0x10
-		vp = []byte{byte(v)}
 	case VPEnhanced:
 		b |= 0x08
-		vp = []byte{v[0], v[1], v[2], v[3], v[4], v[5], v[6]}
 	case VPAbsolute:
 		b |= 0x18
-		vp = []byte{v[0], v[1], v[2], v[3], v[4], v[5], v[6]}
 	default:
 		// nil VP value
 	}
@@ -54,31 +54,58 @@ func (d Submit) MarshalTP() []byte {
 	if d.RP {
 		b |= 0x80
 	}
-	w.WriteByte(b)
-	w.WriteByte(d.TMR)
-	l, a := d.DA.Marshal()
-	w.WriteByte(l)
-	w.Write(a)
-	w.WriteByte(d.PID)
+	dst = append(dst, b, d.TMR)
+	dst = d.DA.appendTP(dst)
+	dst = append(dst, d.PID)
 	if d.DCS == nil {
-		w.WriteByte(0x00)
+		dst = append(dst, 0x00)
 	} else {
-		w.WriteByte(d.DCS.Marshal())
+		dst = append(dst, d.DCS.Marshal())
+	}
+	switch v := d.VP.(type) {
+	case VPRelative:
+		dst = append(dst, byte(v))
+	case VPEnhanced:
+		dst = append(dst, v[:]...)
+	case VPAbsolute:
+		dst = append(dst, v[:]...)
 	}
-	w.Write(vp)
-	d.UD.write(w, d.DCS)
+	return d.UD.appendTo(dst, d.DCS)
+}
 
-	return w.Bytes()
+// Validate check consistency of field values and returns ValidationError
+func (d Submit) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	e.checkRPAddress("RP-DA", d.SCA)
+	e.checkAddress("TP-DA", d.DA, false)
+	e.checkPID(d.PID)
+	e.checkDCS(d.DCS)
+	e.checkVP(d.VP)
+	e.checkUD("TP-UD", d.UD, d.DCS)
+	return e.result()
 }
 
 // MarshalRP output byte data of this RPDU
 func (d Submit) MarshalRP() []byte {
-	return d.rpData.marshal(true, d.MarshalTP())
+	return d.AppendRP(nil)
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d Submit) AppendRP(dst []byte) []byte {
+	dst, p := d.rpData.appendRP(true, dst)
+	return setLength(d.AppendTP(dst), p)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d Submit) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d Submit) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalSubmit decode Submit from bytes
@@ -88,33 +115,47 @@ func UnmarshalSubmit(b []byte) (d Submit, e error) {
 }
 
 // UnmarshalTP get data of this TPDU
-func (d *Submit) UnmarshalTP(b []byte) (e error) {
+func (d *Submit) UnmarshalTP(b []byte) error {
+	return d.unmarshalTP(b, nil)
+}
+
+func (d *Submit) unmarshalTP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("TP", b, o)
+	r.next("TP-MTI")
 	if len(b) == 0 {
-		return io.EOF
+		return r.fail(io.EOF)
 	}
 	if b[0]&0x03 != 0x01 {
-		return UnexpectedMessageTypeError{
-			Expected: 0x01, Actual: b[0] & 0x03}
+		return r.fail(UnexpectedMessageTypeError{
+			Expected: 0x01, Actual: b[0] & 0x03})
 	}
 
 	d.RD = b[0]&0x04 == 0x04
 	d.SRR = b[0]&0x20 == 0x20
 	d.RP = b[0]&0x80 == 0x80
+	r.skip(1)
 
-	r := bytes.NewReader(b[1:])
-
+	r.next("TP-MR")
 	if d.TMR, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
-	if d.DA, e = readTPAddr(r); e != nil {
-		return
+	r.next("TP-DA")
+	if d.DA, e = readTPAddr(r.Reader); e != nil {
+		return r.fail(e)
 	}
+	if d.DA.TON == TypeAlphanumeric {
+		return r.fail(UnexpectedAddressTypeError{
+			Field: "TP-DA", TON: d.DA.TON})
+	}
+	r.next("TP-PID")
 	if d.PID, e = r.ReadByte(); e != nil {
-		return
+		return r.fail(e)
 	}
-	if d.DCS, e = readDataCoding(r); e != nil {
-		return
+	r.next("TP-DCS")
+	if d.DCS, e = readDataCoding(r.Reader, o); e != nil {
+		return r.fail(e)
 	}
+	r.next("TP-VP")
 	switch b[0] & 0x18 {
 	case 0x00:
 		d.VP = nil
@@ -123,44 +164,52 @@ func (d *Submit) UnmarshalTP(b []byte) (e error) {
 		if p, e = r.ReadByte(); e == nil {
 			d.VP = VPRelative(p)
 		} else {
-			return
+			return r.fail(e)
 		}
 	case 0x08:
 		var p [7]byte
-		if p, e = read7Bytes(r); e == nil {
+		if p, e = read7Bytes(r.Reader); e == nil {
 			d.VP = VPEnhanced(p)
 		} else {
-			return
+			return r.fail(e)
 		}
 	case 0x18:
 		var p [7]byte
-		if p, e = read7Bytes(r); e == nil {
+		if p, e = read7Bytes(r.Reader); e == nil {
 			d.VP = VPAbsolute(p)
 		} else {
-			return
+			return r.fail(e)
 		}
 	}
-	if e = d.UD.read(r, d.DCS, b[0]&0x40 == 0x40); e != nil {
+	if e = d.UD.read("TP-UD", r, d.DCS, b[0]&0x40 == 0x40, o); e != nil {
 		return
 	}
-	if r.Len() != 0 {
-		e = ErrExtraData
-	}
+	e = o.checkTrailing(r)
 	return
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *Submit) UnmarshalRP(b []byte) (e error) {
-	if b, e = d.unmarshal(true, b); e == nil {
-		e = d.UnmarshalTP(b)
+func (d *Submit) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *Submit) unmarshalRP(b []byte, o *decoder) (e error) {
+	var tp []byte
+	if tp, e = d.unmarshal(true, b, o); e == nil {
+		e = innerError(d.unmarshalTP(tp, o), b, tp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *Submit) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *Submit) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *Submit) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
@@ -180,9 +229,11 @@ func (d Submit) MarshalJSON() ([]byte, error) {
 		al.Dcs = 0
 	}
 	if d.VP != nil {
+		f := VPFormatOf(d.VP)
 		al.Vp = &jvp{
 			T: d.VP.Duration(),
-			S: d.VP.SingleAttempt()}
+			S: d.VP.SingleAttempt(),
+			F: &f}
 	}
 	if !d.UD.isEmpty() {
 		al.Ud = &d.UD
@@ -206,7 +257,13 @@ func (d *Submit) UnmarshalJSON(b []byte) error {
 		return e
 	}
 	d.DCS = UnmarshalDataCoding(al.Dcs)
-	if al.Vp != nil {
+	if al.Vp != nil && al.Vp.F != nil {
+		vp, e := MakeValidityPeriod(*al.Vp.F, al.Vp.T, al.Vp.S, time.Now())
+		if e != nil {
+			return e
+		}
+		d.VP = vp
+	} else if al.Vp != nil {
 		d.VP = ValidityPeriodOf(al.Vp.T, al.Vp.S)
 	}
 	if al.Ud != nil {
@@ -87,7 +87,7 @@ func randSubmit() sms.Submit {
 		SRR: randBool(),
 		RP:  randBool(),
 		TMR: randByte(),
-		DA:  randAddress(),
+		DA:  randNumberAddress(),
 		PID: randByte(),
 		DCS: randDCS(),
 		VP:  randVP(),
@@ -280,9 +280,9 @@ func TestEncodeSubmitReport(t *testing.T) {
 
 func TestDecodeSubmitReport(t *testing.T) {
 	p := &sms.SubmitReport{
-		SCTS: time.Date(
+		SCTS: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("", 9*60*60)).Local(),
+			time.FixedZone("", 9*60*60)).Local()),
 		PID: nil,
 		DCS: nil}
 
@@ -293,9 +293,9 @@ func TestDecodeSubmitReport(t *testing.T) {
 func TestMarshalJSON_submitreport(t *testing.T) {
 	p := &sms.SubmitReport{
 		FCS: 0xC0,
-		SCTS: time.Date(
+		SCTS: scts(time.Date(
 			2011, time.March, 22, 14, 25, 40, 0,
-			time.FixedZone("", 9*60*60)).Local(),
+			time.FixedZone("", 9*60*60)).Local()),
 		PID: nil,
 		DCS: nil}
 	t.Log(p.String())
@@ -316,7 +316,7 @@ func TestMarshalJSON_submitreport(t *testing.T) {
 func randSubmitreport() sms.SubmitReport {
 	orig := sms.SubmitReport{
 		FCS:  byte(rand.Int31n(129)),
-		SCTS: randDate(),
+		SCTS: randSCTS(),
 		DCS:  sms.UnmarshalDataCoding(randByte()),
 	}
 
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"time"
 )
 
 // SubmitReport is TPDU message from SC to MS
@@ -16,24 +15,27 @@ type SubmitReport struct {
 	CS   byte  `json:"rp-cs"`          // M / Cause
 	DIAG *byte `json:"diag,omitempty"` // O / Diagnostics
 
-	FCS  byte       `json:"tp-fcs,omitempty"` // C / Failure Cause
-	SCTS time.Time  `json:"tp-scts"`          // M / Service Centre Time Stamp
-	PID  *byte      `json:"tp-pid,omitempty"` // O / Protocol Identifier
-	DCS  DataCoding `json:"tp-dcs,omitempty"` // O / Data Coding Scheme
-	UD   UserData   `json:"tp-uid,omitempty"` // O / User Data
+	FCS  byte        `json:"tp-fcs,omitempty"` // C / Failure Cause
+	SCTS SCTimeStamp `json:"tp-scts"`          // M / Service Centre Time Stamp
+	PID  *byte       `json:"tp-pid,omitempty"` // O / Protocol Identifier
+	DCS  DataCoding  `json:"tp-dcs,omitempty"` // O / Data Coding Scheme
+	UD   UserData    `json:"tp-uid,omitempty"` // O / User Data
 }
 
 // MarshalTP output byte data of this TPDU
 func (d SubmitReport) MarshalTP() []byte {
-	w := new(bytes.Buffer)
+	return d.AppendTP(nil)
+}
 
+// AppendTP append byte data of this TPDU to dst
+func (d SubmitReport) AppendTP(dst []byte) []byte {
 	b := byte(0x01)
 	if len(d.UD.UDH) != 0 {
 		b |= 0x40
 	}
-	w.WriteByte(b)
+	dst = append(dst, b)
 	if d.FCS&0x80 == 0x80 {
-		w.WriteByte(d.FCS)
+		dst = append(dst, d.FCS)
 	}
 	b = byte(0x00)
 	if d.PID != nil {
@@ -45,34 +47,64 @@ func (d SubmitReport) MarshalTP() []byte {
 	if len(d.UD.Text) != 0 || len(d.UD.UDH) != 0 {
 		b |= 0x04
 	}
-	w.WriteByte(b)
-	w.Write(marshalSCTimeStamp(d.SCTS))
+	dst = append(dst, b)
+	dst = append(dst, d.SCTS[:]...)
 	if d.PID != nil {
-		w.WriteByte(*d.PID)
+		dst = append(dst, *d.PID)
 	}
 	if d.DCS != nil {
-		w.WriteByte(d.DCS.Marshal())
+		dst = append(dst, d.DCS.Marshal())
 	}
 	if !d.UD.isEmpty() {
-		d.UD.write(w, d.DCS)
+		dst = d.UD.appendTo(dst, d.DCS)
 	}
+	return dst
+}
 
-	return w.Bytes()
+// Validate check consistency of field values and returns ValidationError
+func (d SubmitReport) Validate() error {
+	var e ValidationError
+	e.checkTI(d.TI)
+	e.checkFCS(d.FCS)
+	if d.FCS&0x80 == 0x80 {
+		e.checkRPCause(d.CS)
+	}
+	e.checkSCTS("TP-SCTS", d.SCTS)
+	if d.PID != nil {
+		e.checkPID(*d.PID)
+	}
+	e.checkDCS(d.DCS)
+	e.checkUD("TP-UD", d.UD, d.DCS)
+	return e.result()
 }
 
 // MarshalRP output byte data of this RPDU
 func (d SubmitReport) MarshalRP() []byte {
+	return d.AppendRP(nil)
+}
+
+// AppendRP append byte data of this RPDU to dst
+func (d SubmitReport) AppendRP(dst []byte) []byte {
+	var p int
 	if d.FCS&0x80 == 0x80 {
 		rp := RpError{RMR: d.RMR, CS: d.CS, DIAG: d.DIAG}
-		return rp.marshalRP(false, d.MarshalTP())
+		dst, p = rp.appendRP(dst, false, true)
+	} else {
+		rp := RpAck{RMR: d.RMR}
+		dst, p = rp.appendRP(dst, false, true)
 	}
-	rp := RpAck{RMR: d.RMR}
-	return rp.marshalRP(false, d.MarshalTP())
+	return setLength(d.AppendTP(dst), p)
 }
 
 // MarshalCP output byte data of this CPDU
 func (d SubmitReport) MarshalCP() []byte {
-	return d.cpData.marshal(d.MarshalRP())
+	return d.AppendCP(nil)
+}
+
+// AppendCP append byte data of this CPDU to dst
+func (d SubmitReport) AppendCP(dst []byte) []byte {
+	dst, p := d.cpData.appendCP(dst)
+	return setLength(d.AppendRP(dst), p)
 }
 
 // UnmarshalSubmitReport decode SubmitReport from bytes
@@ -82,87 +114,106 @@ func UnmarshalSubmitReport(b []byte) (d SubmitReport, e error) {
 }
 
 // UnmarshalTP get data of this TPDU
-func (d *SubmitReport) UnmarshalTP(b []byte) (e error) {
+func (d *SubmitReport) UnmarshalTP(b []byte) error {
+	return d.unmarshalTP(b, nil)
+}
+
+func (d *SubmitReport) unmarshalTP(b []byte, o *decoder) (e error) {
+	r := newFieldReader("TP", b, o)
+	r.next("TP-MTI")
 	if len(b) == 0 {
-		return io.EOF
+		return r.fail(io.EOF)
 	}
 	if b[0]&0x03 != 0x01 {
-		return UnexpectedMessageTypeError{
-			Expected: 0x01, Actual: b[0] & 0x03}
+		return r.fail(UnexpectedMessageTypeError{
+			Expected: 0x01, Actual: b[0] & 0x03})
 	}
+	r.skip(1)
 
-	r := bytes.NewReader(b[1:])
-
+	r.next("TP-PI")
 	var pi byte
 	if pi, e = r.ReadByte(); e == nil && pi&0x80 == 0x80 {
 		d.FCS = pi
+		r.rename("TP-FCS")
+		r.next("TP-PI")
 		pi, e = r.ReadByte()
 	}
 	if e != nil {
-		return
+		return r.fail(e)
 	}
-	var p [7]byte
-	if p, e = read7Bytes(r); e != nil {
-		return
+	r.next("TP-SCTS")
+	if d.SCTS, e = readSCTimeStamp("TP-SCTS", r.Reader, o); e != nil {
+		return r.fail(e)
 	}
-	d.SCTS = unmarshalSCTimeStamp(p)
 	if pi&0x01 == 0x01 {
+		r.next("TP-PID")
 		var p byte
 		if p, e = r.ReadByte(); e != nil {
-			return
+			return r.fail(e)
 		}
 		d.PID = &p
 	}
 	if pi&0x02 == 0x02 {
-		if d.DCS, e = readDataCoding(r); e != nil {
-			return
+		r.next("TP-DCS")
+		if d.DCS, e = readDataCoding(r.Reader, o); e != nil {
+			return r.fail(e)
 		}
 	}
 	if pi&0x04 == 0x04 {
-		e = d.UD.read(r, d.DCS, b[0]&0x40 == 0x40)
+		e = d.UD.read("TP-UD", r, d.DCS, b[0]&0x40 == 0x40, o)
 		if e != nil {
 			return
 		}
 	}
-	if r.Len() != 0 {
-		e = ErrExtraData
-	}
+	e = o.checkTrailing(r)
 	return
 }
 
 // UnmarshalRP get data of this RPDU
-func (d *SubmitReport) UnmarshalRP(b []byte) (e error) {
+func (d *SubmitReport) UnmarshalRP(b []byte) error {
+	return d.unmarshalRP(b, nil)
+}
+
+func (d *SubmitReport) unmarshalRP(b []byte, o *decoder) (e error) {
 	if len(b) == 0 {
-		return io.EOF
+		return DecodeError{Layer: "RP", Field: "RP-MTI", Err: io.EOF}
 	}
+	var tp []byte
 	switch b[0] & 0x07 {
 	case 0x03:
 		rp := RpAck{}
-		b, e = rp.unmarshalRP(false, b)
-		if e == nil {
+		if tp, e = rp.unmarshalRP(false, b, o); e == nil {
 			d.RMR = rp.RMR
 		}
 	case 0x05:
 		rp := RpError{}
-		b, e = rp.unmarshalRP(false, b)
-		if e == nil {
+		if tp, e = rp.unmarshalRP(false, b, o); e == nil {
 			d.RMR = rp.RMR
 			d.CS = rp.CS
 			d.DIAG = rp.DIAG
 		}
+	default:
+		e = DecodeError{Layer: "RP", Field: "RP-MTI",
+			Err: UnexpectedMessageTypeError{Expected: 0x03, Actual: b[0]}}
 	}
-	if b == nil {
-		e = io.EOF
-	} else {
-		e = d.UnmarshalTP(b)
+	if e == nil && tp == nil {
+		e = DecodeError{
+			Layer: "RP", Field: "RP-User-Data", Offset: len(b), Err: io.EOF}
+	} else if e == nil {
+		e = innerError(d.unmarshalTP(tp, o), b, tp)
 	}
 	return
 }
 
 // UnmarshalCP get data of this CPDU
-func (d *SubmitReport) UnmarshalCP(b []byte) (e error) {
-	if b, e = d.cpData.unmarshal(b); e == nil {
-		e = d.UnmarshalRP(b)
+func (d *SubmitReport) UnmarshalCP(b []byte) error {
+	return d.unmarshalCP(b, nil)
+}
+
+func (d *SubmitReport) unmarshalCP(b []byte, o *decoder) (e error) {
+	var rp []byte
+	if rp, e = d.cpData.unmarshal(b, o); e == nil {
+		e = innerError(d.unmarshalRP(rp, o), b, rp)
 	}
 	return
 }
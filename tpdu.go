@@ -24,10 +24,15 @@ func NextMsgReference() byte {
 	return ret
 }
 
-// TPDU represents a SMS TP PDU
+// TPDU represents a SMS TP PDU.
+// MarshalTP and AppendTP encode field values as is, even if it
+// violates the specification, except too long address that is
+// truncated to keep the data decodable.
+// Validate is the gate to check the values before encoding.
 type TPDU interface {
 	RPDU
 	MarshalTP() []byte
+	AppendTP([]byte) []byte
 }
 
 // UnmarshalerTP is the interface implemented by types
@@ -38,34 +43,72 @@ type UnmarshalerTP interface {
 
 // UnmarshalTPMO parse byte data to TPDU as SC.
 func UnmarshalTPMO(b []byte) (TPDU, error) {
+	return unmarshalTPMO(b, newDecoder(nil))
+}
+
+// UnmarshalTPMOWithOptions is UnmarshalTPMO with lenient decoding by DecodeOptions o,
+// and it returns violations that are ignored by o.
+func UnmarshalTPMOWithOptions(b []byte, o *DecodeOptions) (TPDU, []Violation, error) {
+	d := newDecoder(o)
+	p, e := unmarshalTPMO(b, d)
+	return p, d.warnings, e
+}
+
+func unmarshalTPMO(b []byte, op *decoder) (TPDU, error) {
 	if len(b) == 0 {
-		return nil, io.EOF
+		return nil, DecodeError{Layer: "TP", Field: "TP-MTI", Err: io.EOF}
 	}
 	switch b[0] & 0x03 {
 	case 0x00:
-		return UnmarshalDeliverReport(b)
+		var t DeliverReport
+		e := t.unmarshalTP(b, op)
+		return t, e
 	case 0x01:
-		return UnmarshalSubmit(b)
+		var t Submit
+		e := t.unmarshalTP(b, op)
+		return t, e
 	case 0x02:
-		return UnmarshalCommand(b)
+		var t Command
+		e := t.unmarshalTP(b, op)
+		return t, e
 	}
-	return nil, UnknownMessageTypeError{Actual: b[0] & 0x03}
+	return nil, DecodeError{Layer: "TP", Field: "TP-MTI",
+		Err: UnknownMessageTypeError{Actual: b[0] & 0x03}}
 }
 
 // UnmarshalTPMT parse byte data to TPDU as MS.
-func UnmarshalTPMT(b []byte) (t TPDU, e error) {
+func UnmarshalTPMT(b []byte) (TPDU, error) {
+	return unmarshalTPMT(b, newDecoder(nil))
+}
+
+// UnmarshalTPMTWithOptions is UnmarshalTPMT with lenient decoding by DecodeOptions o,
+// and it returns violations that are ignored by o.
+func UnmarshalTPMTWithOptions(b []byte, o *DecodeOptions) (TPDU, []Violation, error) {
+	d := newDecoder(o)
+	p, e := unmarshalTPMT(b, d)
+	return p, d.warnings, e
+}
+
+func unmarshalTPMT(b []byte, op *decoder) (TPDU, error) {
 	if len(b) == 0 {
-		return nil, io.EOF
+		return nil, DecodeError{Layer: "TP", Field: "TP-MTI", Err: io.EOF}
 	}
 	switch b[0] & 0x03 {
 	case 0x00:
-		return UnmarshalDeliver(b)
+		var t Deliver
+		e := t.unmarshalTP(b, op)
+		return t, e
 	case 0x01:
-		return UnmarshalSubmitReport(b)
+		var t SubmitReport
+		e := t.unmarshalTP(b, op)
+		return t, e
 	case 0x02:
-		return UnmarshalStatusReport(b)
+		var t StatusReport
+		e := t.unmarshalTP(b, op)
+		return t, e
 	}
-	return nil, UnknownMessageTypeError{Actual: b[0] & 0x03}
+	return nil, DecodeError{Layer: "TP", Field: "TP-MTI",
+		Err: UnknownMessageTypeError{Actual: b[0] & 0x03}}
 }
 
 func read7Bytes(r *bytes.Reader) ([7]byte, error) {
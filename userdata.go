@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"unicode"
 	"unicode/utf16"
 )
 
@@ -104,10 +105,12 @@ func (u UserData) Get8bitData() ([]byte, error) {
 	return base64.StdEncoding.DecodeString(u.Text)
 }
 
-func (u *UserData) read(r *bytes.Reader, d DataCoding, h bool) error {
+func (u *UserData) read(
+	f string, r *fieldReader, d DataCoding, h bool, op *decoder) error {
+	r.next(f + "L")
 	p, e := r.ReadByte()
 	if e != nil {
-		return e
+		return r.fail(e)
 	}
 
 	c := CharsetGSM7bit
@@ -124,18 +127,56 @@ func (u *UserData) read(r *bytes.Reader, d DataCoding, h bool) error {
 		}
 	}
 	if l > 140 {
-		return ErrInvalidLength
+		if !op.wrongUDL() {
+			return r.fail(ErrInvalidLength)
+		}
+		op.warn(f, fmt.Sprintf("length %d octets exceeds 140 octets", l))
 	}
 
-	ud := make([]byte, l)
-	if r.Len() < len(ud) {
-		return io.EOF
+	r.next(f)
+	n := -1
+	if r.Len() < l {
+		switch {
+		case op.truncatedUD():
+			op.warn(f, fmt.Sprintf("truncated %d of %d octets", r.Len(), l))
+		case op.wrongUDL():
+			op.warn(f, fmt.Sprintf("length %d mismatch to %d octets of data",
+				p, r.Len()))
+		default:
+			return r.fail(io.EOF)
+		}
+		n = r.Len()
+	} else if r.Len() > l && op.wrongUDL() && !op.trailingData() {
+		op.warn(f, fmt.Sprintf("length %d mismatch to %d octets of data",
+			p, r.Len()))
+		n = r.Len()
 	}
+	if n >= 0 {
+		l = n
+		if c == CharsetGSM7bit {
+			n = n * 8 / 7
+		}
+		p = byte(n)
+		if n > 0xff {
+			p = 0xff
+		}
+	}
+
+	ud := make([]byte, l)
 	r.Read(ud)
 
 	l = int(p)
 	o := 0
 	if h {
+		if len(ud) == 0 || int(ud[0]) >= len(ud) {
+			if !op.truncatedUD() {
+				return DecodeError{Layer: "UDH", Field: "UDHL",
+					Offset: r.offset, Err: ErrInvalidLength}
+			}
+			op.warn(f, "user data header is truncated")
+			u.UDH = UnmarshalUDHs(ud)
+			return nil
+		}
 		if c == CharsetGSM7bit {
 			o = int(ud[0]+1) * 8
 			l -= o / 7
@@ -147,6 +188,9 @@ func (u *UserData) read(r *bytes.Reader, d DataCoding, h bool) error {
 		} else {
 			l -= int(ud[0] + 1)
 		}
+		if l < 0 {
+			l = 0
+		}
 		u.UDH = UnmarshalUDHs(ud[0 : ud[0]+1])
 		ud = ud[ud[0]+1:]
 	}
@@ -158,6 +202,9 @@ func (u *UserData) read(r *bytes.Reader, d DataCoding, h bool) error {
 	case Charset8bitData:
 		u.Text = base64.StdEncoding.EncodeToString(ud)
 	case CharsetUCS2:
+		if l > len(ud) {
+			l = len(ud)
+		}
 		s := make([]uint16, l/2)
 		for i := range s {
 			s[i] = uint16(ud[2*i])<<8 | uint16(ud[2*i+1])
@@ -167,14 +214,15 @@ func (u *UserData) read(r *bytes.Reader, d DataCoding, h bool) error {
 	return nil
 }
 
-func (u UserData) write(w *bytes.Buffer, d DataCoding) {
+// appendTo append TP-UDL and TP-UD of coding d to dst
+func (u UserData) appendTo(dst []byte, d DataCoding) []byte {
 	c := CharsetGSM7bit
 	if d != nil {
 		c = d.Charset()
 	}
-	udh := MarshalUDHs(u.UDH)
-	var ud []byte
-	l := len(udh)
+	p := len(dst)
+	dst = appendUDHs(append(dst, 0), u.UDH)
+	l := len(dst) - p - 1
 	max := 140 - l
 
 	switch c {
@@ -186,53 +234,79 @@ func (u UserData) write(w *bytes.Buffer, d DataCoding) {
 			o = 7 - o
 			l++
 		}
-		s, _ := StringToGSM7bit(u.Text)
-		s = s.trim(max * 8 / 7)
-		ud = s.Marshal(o)
-		l += s.Length()
+		var n int
+		dst, n = appendGSM7bit(dst, u.Text, o, max*8/7)
+		l += n
 	case Charset8bitData:
-		var e error
-		ud, e = base64.StdEncoding.DecodeString(u.Text)
+		q := len(dst)
+		n := base64.StdEncoding.DecodedLen(len(u.Text))
+		for i := 0; i < n; i++ {
+			dst = append(dst, 0)
+		}
+		n, e := base64.StdEncoding.Decode(dst[q:], []byte(u.Text))
 		if e != nil {
-			ud = []byte{}
+			n = 0
 		}
-		if len(ud) > max {
-			ud = ud[:max]
+		if n > max {
+			n = max
 		}
-		l += len(ud)
+		dst = dst[:q+n]
+		l += n
 	case CharsetUCS2:
-		u := utf16.Encode([]rune(u.Text))
-		if len(u)*2 > max {
-			u = u[:max/2]
-		}
-		ud = make([]byte, len(u)*2)
-		for i, c := range u {
-			ud[i*2] = byte((c >> 8) & 0xff)
-			ud[i*2+1] = byte(c & 0xff)
+		n := 0
+		for _, r := range u.Text {
+			if n+2 > max {
+				break
+			}
+			if r1, r2 := utf16.EncodeRune(r); r1 != unicode.ReplacementChar {
+				dst = append(dst, byte(r1>>8), byte(r1))
+				n += 2
+				if n+2 > max {
+					break
+				}
+				r = r2
+			}
+			dst = append(dst, byte(r>>8), byte(r))
+			n += 2
 		}
-		l += len(ud)
+		l += n
 	}
 
-	w.WriteByte(byte(l))
-	w.Write(udh)
-	w.Write(ud)
+	dst[p] = byte(l)
+	return dst
 }
 
 func (u UserData) isEmpty() bool {
 	return len(u.Text) == 0 && len(u.UDH) == 0
 }
 
-// MakeSeparatedText generate splited data
+// MakeSeparatedText generate splited data.
+// ud is nil if s needs more than 255 segments.
 func MakeSeparatedText(s string, id byte) (ud []UserData, cs Charset) {
-	ud = []UserData{}
-	g7s, e := StringToGSM7bit(s)
+	if ud, cs, _ = separateText(s, 6); len(ud) > 1 {
+		for i := range ud {
+			ud[i].UDH = append(ud[i].UDH, ConcatenatedSM{
+				RefNum: id,
+				MaxNum: byte(len(ud)),
+				SeqNum: byte(i + 1)})
+		}
+	}
 
-	if e == nil {
+	return
+}
+
+// separateText split s to segments that can have
+// h octets user data header for concatenation
+func separateText(s string, h int) (ud []UserData, cs Charset, e error) {
+	gl := (140 - h) * 8 / 7
+	ul := (140 - h) / 2
+	ud = []UserData{}
+	if g7s, ge := StringToGSM7bit(s); ge == nil {
 		cs = CharsetGSM7bit
 		if g7s.Length() <= 160 {
 			ud = append(ud, UserData{Text: string(s)})
 		} else {
-			rs := make([]rune, 0, 153)
+			rs := make([]rune, 0, gl)
 			i := 0
 			for _, r := range s {
 				l := 1
@@ -240,7 +314,7 @@ func MakeSeparatedText(s string, id byte) (ud []UserData, cs Charset) {
 					l++
 				}
 				i += l
-				if i <= 153 {
+				if i <= gl {
 					rs = append(rs, r)
 				} else {
 					ud = append(ud, UserData{Text: string(rs)})
@@ -256,9 +330,9 @@ func MakeSeparatedText(s string, id byte) (ud []UserData, cs Charset) {
 		if len(s) <= 70 {
 			ud = append(ud, UserData{Text: string(s)})
 		} else {
-			rs := make([]rune, 0, 67)
+			rs := make([]rune, 0, ul)
 			for _, r := range s {
-				if len(rs)+1 <= 67 {
+				if len(rs)+1 <= ul {
 					rs = append(rs, r)
 				} else {
 					ud = append(ud, UserData{Text: string(rs)})
@@ -270,14 +344,31 @@ func MakeSeparatedText(s string, id byte) (ud []UserData, cs Charset) {
 		}
 	}
 
-	if len(ud) > 1 {
-		for i := range ud {
-			ud[i].UDH = append(ud[i].UDH, ConcatenatedSM{
-				RefNum: id,
-				MaxNum: byte(len(ud)),
-				SeqNum: byte(i + 1)})
-		}
+	if len(ud) > 0xff {
+		ud, e = nil, ErrTooManySegments
 	}
+	return
+}
 
+// separateData split d to segments that can have
+// h octets user data header for concatenation
+func separateData(d []byte, h int) (ud []UserData, e error) {
+	if len(d) <= 140 {
+		ud = []UserData{{}}
+		ud[0].Set8bitData(d)
+		return
+	}
+	for l := 140 - h; len(d) != 0; {
+		if l > len(d) {
+			l = len(d)
+		}
+		u := UserData{}
+		u.Set8bitData(d[:l])
+		ud = append(ud, u)
+		d = d[l:]
+	}
+	if len(ud) > 0xff {
+		ud, e = nil, ErrTooManySegments
+	}
 	return
 }
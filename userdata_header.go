@@ -45,22 +45,36 @@ func UnmarshalUDHs(b []byte) (h []UserDataHdr) {
 
 // MarshalUDHs ganerate binary data of this UDHs
 func MarshalUDHs(h []UserDataHdr) []byte {
+	return appendUDHs([]byte{}, h)
+}
+
+// appendUDHs append UDHL and UDHs to dst
+func appendUDHs(dst []byte, h []UserDataHdr) []byte {
 	if len(h) == 0 {
-		return []byte{}
+		return dst
 	}
 
-	var b bytes.Buffer
-	b.WriteByte(0x00)
+	p := len(dst)
+	dst = append(dst, 0x00)
 	for _, u := range h {
-		d := u.Marshal()
-		if b.Len()+len(d) > 140 {
+		q := len(dst)
+		if a, ok := u.(udhAppender); ok {
+			dst = a.appendTo(dst)
+		} else {
+			dst = append(dst, u.Marshal()...)
+		}
+		if len(dst)-p > 140 {
+			dst = dst[:q]
 			break
 		}
-		b.Write(d)
 	}
-	r := b.Bytes()
-	r[0] = byte(len(r) - 1)
-	return r
+	dst[p] = byte(len(dst) - p - 1)
+	return dst
+}
+
+// udhAppender is UDH that can append its binary data without allocation
+type udhAppender interface {
+	appendTo([]byte) []byte
 }
 
 // GenericIEI is User Data Header
@@ -105,6 +119,11 @@ func (h GenericIEI) Marshal() []byte {
 	return r
 }
 
+func (h GenericIEI) appendTo(dst []byte) []byte {
+	dst = append(dst, h.K, byte(len(h.V)))
+	return append(dst, h.V...)
+}
+
 // UnmarshalGeneric make Generic UDH
 func UnmarshalGeneric(b []byte) (h GenericIEI) {
 	if b != nil {
@@ -157,6 +176,10 @@ func (h ConcatenatedSM) Marshal() []byte {
 	return []byte{0x00, 0x03, h.RefNum, h.MaxNum, h.SeqNum}
 }
 
+func (h ConcatenatedSM) appendTo(dst []byte) []byte {
+	return append(dst, 0x00, 0x03, h.RefNum, h.MaxNum, h.SeqNum)
+}
+
 // UnmarshalConcatenatedSM make ConcatenatedSM UDH
 func UnmarshalConcatenatedSM(b []byte) (h ConcatenatedSM) {
 	if len(b) >= 3 {
@@ -214,6 +237,11 @@ func (h ConcatenatedSM16bit) Marshal() []byte {
 		byte(h.RefNum >> 8), byte(h.RefNum & 0x00ff), h.MaxNum, h.SeqNum}
 }
 
+func (h ConcatenatedSM16bit) appendTo(dst []byte) []byte {
+	return append(dst, 0x08, 0x04,
+		byte(h.RefNum>>8), byte(h.RefNum&0x00ff), h.MaxNum, h.SeqNum)
+}
+
 // UnmarshalConcatenatedSM16bit make ConcatenatedSM16bit UDH
 func UnmarshalConcatenatedSM16bit(b []byte) (h ConcatenatedSM16bit) {
 	if len(b) >= 4 {
@@ -0,0 +1,219 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Violation is a specification violation of a PDU field
+type Violation struct {
+	Field  string
+	Reason string
+}
+
+func (v Violation) String() string {
+	return v.Field + ": " + v.Reason
+}
+
+// ValidationError show all violations of a PDU
+type ValidationError []Violation
+
+func (e ValidationError) Error() string {
+	w := new(bytes.Buffer)
+	fmt.Fprintf(w, "%d violation(s)", len(e))
+	for i, v := range e {
+		if i == 0 {
+			w.WriteString(": ")
+		} else {
+			w.WriteString(", ")
+		}
+		w.WriteString(v.String())
+	}
+	return w.String()
+}
+
+// Validator is the interface implemented by PDUs that can check
+// consistency of own field values
+type Validator interface {
+	Validate() error
+}
+
+func (e *ValidationError) add(f, format string, a ...interface{}) {
+	*e = append(*e, Violation{Field: f, Reason: fmt.Sprintf(format, a...)})
+}
+
+func (e ValidationError) result() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) checkTI(ti byte) {
+	if ti&0xf0 != 0 {
+		e.add("CP-TI", "value %d exceeds 4 bits", ti)
+	}
+}
+
+func (e *ValidationError) checkAddress(f string, a Address, alpha bool) {
+	if v := a.check(f, alpha); v != nil {
+		e.add(f, "%s", v)
+	}
+}
+
+func (e *ValidationError) checkRPAddress(f string, a Address) {
+	if a.Addr == nil {
+		e.add(f, "address is empty")
+		return
+	}
+	e.checkAddress(f, a, false)
+}
+
+func (e *ValidationError) checkPID(pid byte) {
+	if pidReserved(pid) {
+		e.add("TP-PID", "reserved value 0x%02x", pid)
+	}
+}
+
+func (e *ValidationError) checkDCS(d DataCoding) {
+	if d == nil {
+		return
+	}
+	if r := UnmarshalDataCoding(d.Marshal()); r == nil || !r.Equal(d) {
+		e.add("TP-DCS", "reserved value 0x%02x", d.Marshal())
+	}
+}
+
+func (e *ValidationError) checkRPCause(cs byte) {
+	if cs&0x80 == 0x80 {
+		e.add("RP-CS", "value %d exceeds 7 bits", cs)
+	}
+}
+
+func (e *ValidationError) checkFCS(fcs byte) {
+	if fcs != 0 && fcs&0x80 == 0 {
+		e.add("TP-FCS", "reserved value 0x%02x", fcs)
+	}
+}
+
+func (e *ValidationError) checkSCTS(f string, s SCTimeStamp) {
+	if _, v := s.Time(); v != nil {
+		e.add(f, "%s", v)
+	}
+}
+
+func (e *ValidationError) checkVP(vp ValidityPeriod) {
+	switch v := vp.(type) {
+	case VPEnhanced:
+		switch v.Format() {
+		case EnhancedNone:
+			return
+		case EnhancedRelative:
+			if _, ok := v.Relative(); ok {
+				return
+			}
+		case EnhancedSeconds:
+			if _, ok := v.Seconds(); ok {
+				return
+			}
+		case EnhancedHHMMSS:
+			if _, _, _, ok := v.HHMMSS(); ok {
+				return
+			}
+		}
+		e.add("TP-VP", "invalid enhanced format %s", v)
+	case VPAbsolute:
+		if _, r := SCTimeStamp(v).Time(); r != nil {
+			e.add("TP-VP", "%s", r)
+		}
+	}
+}
+
+// checkUD checks UDH and length of the user data.
+// Fill bits of GSM 7bit text are not checked with UDHL,
+// because UserData has no fill bits and they are made from UDHL on encoding.
+// The length check includes the fill bits.
+func (e *ValidationError) checkUD(f string, u UserData, d DataCoding) {
+	c := CharsetGSM7bit
+	if d != nil {
+		c = d.Charset()
+	}
+
+	udh := MarshalUDHs(u.UDH)
+	l := 0
+	for _, h := range u.UDH {
+		l += len(h.Marshal())
+		if v, ok := h.(ConcatenatedSM); ok &&
+			(v.MaxNum == 0 || v.SeqNum == 0 || v.SeqNum > v.MaxNum) {
+			e.add(f, "invalid concatenated SM sequence %d/%d",
+				v.SeqNum, v.MaxNum)
+		}
+		if v, ok := h.(ConcatenatedSM16bit); ok &&
+			(v.MaxNum == 0 || v.SeqNum == 0 || v.SeqNum > v.MaxNum) {
+			e.add(f, "invalid concatenated SM sequence %d/%d",
+				v.SeqNum, v.MaxNum)
+		}
+	}
+	if len(u.UDH) != 0 && l+1 != len(udh) {
+		e.add(f, "user data header length %d exceeds 140 octets", l+1)
+		return
+	}
+
+	switch c {
+	case CharsetGSM7bit:
+		s, v := StringToGSM7bit(u.Text)
+		if v != nil {
+			if _, ok := d.(MessageWaiting); ok {
+				e.add(f, "message waiting DCS with non GSM 7bit text, %s", v)
+			} else {
+				e.add(f, "DCS mismatch, %s", v)
+			}
+			return
+		}
+		l = (len(udh)*8 + 6) / 7
+		l = (l+s.Length())*7 + 7
+		l /= 8
+	case Charset8bitData:
+		b, v := base64.StdEncoding.DecodeString(u.Text)
+		if v != nil {
+			e.add(f, "DCS mismatch, 8bit data is not base64 encoded")
+			return
+		}
+		l = len(udh) + len(b)
+	case CharsetUCS2:
+		l = len(udh) + len(utf16.Encode([]rune(u.Text)))*2
+	default:
+		return
+	}
+	if l > 140 {
+		e.add(f, "length %d octets exceeds 140 octets", l)
+	}
+}
+
+func pidReserved(b byte) bool {
+	switch {
+	case b < 0x2e:
+		return false
+	case b < 0x30:
+		return true
+	case b < 0x33:
+		return false
+	case b < 0x38:
+		return true
+	case b < 0x49:
+		return false
+	case b < 0x5e:
+		return true
+	case b < 0x60:
+		return false
+	case b < 0x7c:
+		return true
+	case b < 0x80:
+		return false
+	case b < 0xc0:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package sms_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fkgi/sms"
+	"github.com/fkgi/teldata"
+)
+
+func hasViolation(e sms.ValidationError, f string) bool {
+	for _, v := range e {
+		if v.Field == f {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateSubmit(t *testing.T) {
+	p := sms.Submit{
+		PID: 0x60,
+		DCS: sms.GeneralDataCoding{MsgCharset: sms.CharsetGSM7bit},
+		VP:  sms.VPEnhanced{0x07},
+		UD:  sms.UserData{Text: "あいうえお"}}
+	p.SCA, _ = sms.ParseAddress("+819000000000")
+	p.DA, _ = sms.ParseAddress("Operator")
+
+	e := p.Validate()
+	var ve sms.ValidationError
+	if !errors.As(e, &ve) {
+		t.Fatalf("invalid Submit is accepted: %v", e)
+	}
+	t.Log(ve)
+	for _, f := range []string{"TP-DA", "TP-PID", "TP-VP", "TP-UD"} {
+		if !hasViolation(ve, f) {
+			t.Fatalf("violation of %s is not detected", f)
+		}
+	}
+
+	p.DA, _ = sms.ParseAddress("+819012345678")
+	p.PID = 0
+	p.VP = nil
+	p.UD = sms.UserData{Text: randText(161)}
+	if e = p.Validate(); !errors.As(e, &ve) || !hasViolation(ve, "TP-UD") {
+		t.Fatalf("too long UD is accepted: %v", e)
+	}
+	p.UD = sms.UserData{Text: strings.Repeat("a", 160)}
+	if e = p.Validate(); e != nil {
+		t.Fatalf("valid Submit is rejected: %v", e)
+	}
+}
+
+func TestValidateUDFillBits(t *testing.T) {
+	// 6 octets UDH needs 1 fill bit, and 153 septets remain for text
+	h := []sms.UserDataHdr{sms.ConcatenatedSM{RefNum: 1, MaxNum: 2, SeqNum: 1}}
+	p := sms.Submit{
+		DCS: sms.GeneralDataCoding{MsgCharset: sms.CharsetGSM7bit},
+		UD:  sms.UserData{UDH: h, Text: strings.Repeat("a", 153)}}
+	p.SCA, _ = sms.ParseAddress("+819000000000")
+	p.DA, _ = sms.ParseAddress("+819012345678")
+	if e := p.Validate(); e != nil {
+		t.Fatalf("valid Submit is rejected: %v", e)
+	}
+	r, e := sms.UnmarshalTPMO(p.MarshalTP())
+	if e != nil {
+		t.Fatal(e)
+	}
+	if txt := r.(sms.Submit).UD.Text; txt != p.UD.Text {
+		t.Errorf("text mismatch: %s", txt)
+	}
+
+	p.UD.Text += "a"
+	var ve sms.ValidationError
+	if e := p.Validate(); !errors.As(e, &ve) || !hasViolation(ve, "TP-UD") {
+		t.Fatalf("too long UD with fill bits is accepted: %v", e)
+	}
+}
+
+func TestValidateAddressLength(t *testing.T) {
+	alpha, _ := sms.StringToGSM7bit("ABCDEFGHIJKL")
+	long, _ := teldata.ParseTBCD(strings.Repeat("1", 21))
+	oa := sms.Address{TON: sms.TypeAlphanumeric, Addr: alpha}
+	da := sms.Address{TON: sms.TypeInternational, NPI: sms.PlanISDNTelephone, Addr: long}
+
+	for _, c := range []struct {
+		p sms.Validator
+		f string
+	}{
+		{sms.Deliver{OA: oa}, "TP-OA"},
+		{sms.Submit{DA: da}, "TP-DA"},
+		{sms.Command{DA: da}, "TP-DA"},
+		{sms.StatusReport{RA: da}, "TP-RA"},
+	} {
+		var ve sms.ValidationError
+		if e := c.p.Validate(); !errors.As(e, &ve) || !hasViolation(ve, c.f) {
+			t.Fatalf("too long %s is accepted: %v", c.f, e)
+		}
+	}
+	for _, a := range []sms.Address{oa, da} {
+		if _, _, e := a.Marshal(); !errors.Is(e, sms.ErrInvalidLength) {
+			t.Fatalf("too long address is marshaled: %v", e)
+		}
+	}
+
+	// encoders truncate too long address to keep the data decodable
+	ts := scts(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	d := sms.Deliver{OA: oa, SCTS: ts}
+	d.SCA = da
+	p, e := sms.UnmarshalCPMT(d.MarshalCP())
+	if e != nil {
+		t.Fatalf("failed to decode encoded data: %v", e)
+	}
+	if r := p.(sms.Deliver); r.OA.Addr.String() != "ABCDEFGHIJK" ||
+		r.SCA.Addr.String() != strings.Repeat("1", 20) {
+		t.Errorf("unexpected address %s %s", r.OA, r.SCA)
+	}
+	s := sms.Submit{DA: da}
+	s.SCA = da
+	if p, e = sms.UnmarshalCPMO(s.MarshalCP()); e != nil {
+		t.Fatalf("failed to decode encoded data: %v", e)
+	}
+	if r := p.(sms.Submit); r.DA.Addr.String() != strings.Repeat("1", 20) {
+		t.Errorf("unexpected address %s", r.DA)
+	}
+	if _, e = sms.UnmarshalTPMO(sms.Command{DA: da}.MarshalTP()); e != nil {
+		t.Fatalf("failed to decode encoded data: %v", e)
+	}
+	if _, e = sms.UnmarshalTPMT(sms.StatusReport{RA: oa, SCTS: ts, DT: ts}.MarshalTP()); e != nil {
+		t.Fatalf("failed to decode encoded data: %v", e)
+	}
+}
+
+func TestValidateDeliver(t *testing.T) {
+	p := sms.Deliver{
+		DCS: sms.MessageWaiting{Behavior: sms.StoreMessageGSM7bit},
+		UD: sms.UserData{
+			Text: "あいうえお",
+			UDH: []sms.UserDataHdr{
+				sms.ConcatenatedSM{RefNum: 1, MaxNum: 2, SeqNum: 3}}}}
+	p.SCA, _ = sms.ParseAddress("+819000000000")
+	p.OA, _ = sms.ParseAddress("Operator")
+
+	e := p.Validate()
+	var ve sms.ValidationError
+	if !errors.As(e, &ve) {
+		t.Fatalf("invalid Deliver is accepted: %v", e)
+	}
+	t.Log(ve)
+	for _, f := range []string{"TP-SCTS", "TP-UD"} {
+		if !hasViolation(ve, f) {
+			t.Fatalf("violation of %s is not detected", f)
+		}
+	}
+	if hasViolation(ve, "TP-OA") {
+		t.Fatal("alphanumeric TP-OA is rejected")
+	}
+}
+
+func TestValidateRP(t *testing.T) {
+	if e := (sms.RpErrorMT{CS: 0x80}).Validate(); e == nil {
+		t.Fatal("invalid RP-CS is accepted")
+	}
+	if e := (sms.CpAck{TI: 0x10}).Validate(); e == nil {
+		t.Fatal("invalid CP-TI is accepted")
+	}
+	if e := (sms.SubmitReport{FCS: 0x01}).Validate(); e == nil {
+		t.Fatal("invalid TP-FCS is accepted")
+	}
+}
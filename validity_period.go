@@ -23,68 +23,205 @@ type ValidityPeriod interface {
 type jvp struct {
 	T time.Duration `json:"duration"`
 	S bool          `json:"single"`
+	F *VPFormat     `json:"format,omitempty"`
 }
 
-// ValidityPeriodOf returns VP from deadend time and single-attempt flag
+// VPFormat is TP-VPF value
+type VPFormat byte
+
+const (
+	// VPFormatNone means TP-VP field not present
+	VPFormatNone VPFormat = 0x00
+	// VPFormatEnhanced means TP-VP field present - enhanced format
+	VPFormatEnhanced VPFormat = 0x08
+	// VPFormatRelative means TP-VP field present - relative format
+	VPFormatRelative VPFormat = 0x10
+	// VPFormatAbsolute means TP-VP field present - absolute format
+	VPFormatAbsolute VPFormat = 0x18
+)
+
+// VPFormatOf returns TP-VPF value of the VP
+func VPFormatOf(vp ValidityPeriod) VPFormat {
+	switch vp.(type) {
+	case VPRelative:
+		return VPFormatRelative
+	case VPEnhanced:
+		return VPFormatEnhanced
+	case VPAbsolute:
+		return VPFormatAbsolute
+	}
+	return VPFormatNone
+}
+
+func (f VPFormat) String() string {
+	switch f {
+	case VPFormatNone:
+		return "none"
+	case VPFormatEnhanced:
+		return "enhanced"
+	case VPFormatRelative:
+		return "relative"
+	case VPFormatAbsolute:
+		return "absolute"
+	}
+	return fmt.Sprintf("unknown(0x%x)", byte(f))
+}
+
+// MarshalText provide custom marshaller
+func (f VPFormat) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText provide custom marshaller
+func (f *VPFormat) UnmarshalText(b []byte) error {
+	for _, v := range []VPFormat{
+		VPFormatNone, VPFormatEnhanced, VPFormatRelative, VPFormatAbsolute} {
+		if v.String() == string(b) {
+			*f = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown VP format %s", b)
+}
+
+// ValidityPeriodOf returns VP from deadend time and single-attempt flag.
+// Absolute format is calculated from current time.
 func ValidityPeriodOf(t time.Duration, s bool) ValidityPeriod {
+	return ValidityPeriodAt(t, s, time.Now())
+}
+
+// ValidityPeriodAt returns VP from deadend time and single-attempt flag.
+// Absolute format is calculated from the reference time r.
+// Single-attempt VP is enhanced format, and the duration that enhanced
+// format can't represent exactly is rounded up to relative value,
+// up to 63 weeks.
+func ValidityPeriodAt(t time.Duration, s bool, r time.Time) ValidityPeriod {
 	if t%time.Second != 0 {
 		t = t.Truncate(time.Second)
 	}
 
 	if s {
-		vp := VPEnhanced{}
-		vp[0] = 0x40
-		if t == 0 {
-		} else if t%week == 0 && t >= week*5 && t <= week*63 {
-			vp[0] |= 0x01
-			vp[1] = byte(t/week) + 192
-		} else if t%day == 0 && t >= day*2 && t <= day*30 {
-			vp[0] |= 0x01
-			vp[1] = byte(t/day) + 166
-		} else if t%(time.Minute*30) == 0 && t <= day && t >= time.Hour*12+time.Minute*30 {
-			vp[0] |= 0x01
-			vp[1] = byte((t-time.Hour*12)/(time.Minute*30)) + 143
-		} else if t%(time.Minute*5) == 0 && t <= time.Hour*12 && t >= time.Minute*5 {
-			vp[0] |= 0x01
-			vp[1] = byte(t/(time.Minute*5)) - 1
-		} else if t <= time.Second*255 {
-			vp[0] |= 0x02
-			vp[1] = byte(t / time.Second)
-		} else if t <= time.Hour*99+time.Minute*59+time.Second*59 {
-			vp[0] |= 0x03
-			vp[1] = int2SemiOctet(int(t / time.Hour))
-			vp[2] = int2SemiOctet(int((t % time.Hour) / time.Minute))
-			vp[3] = int2SemiOctet(int((t % time.Minute) / time.Second))
+		if vp, ok := enhancedOf(t, true); ok {
+			return vp
 		}
-		return vp
+		v, ok := relativeCeil(t)
+		if !ok {
+			v = 255
+		}
+		return EnhancedRelativeVP(VPRelative(v), true)
 	}
-
 	if t == 0 {
 		return VPEnhanced{}
-	} else if t%week == 0 && t >= week*5 && t <= week*63 {
-		return VPRelative(byte(t/week) + 192)
-	} else if t%day == 0 && t >= day*2 && t <= day*30 {
-		return VPRelative(byte(t/day) + 166)
-	} else if t%(time.Minute*30) == 0 && t <= day && t >= time.Hour*12+time.Minute*30 {
-		return VPRelative(byte((t-time.Hour*12)/(time.Minute*30)) + 143)
-	} else if t%(time.Minute*5) == 0 && t <= time.Hour*12 && t >= time.Minute*5 {
-		return VPRelative(byte(t/(time.Minute*5)) - 1)
-	} else if t <= time.Second*255 {
-		vp := VPEnhanced{}
-		vp[0] = 0x02
-		vp[1] = byte(t / time.Second)
-		return vp
-	} else if t <= time.Hour*99+time.Minute*59+time.Second*59 {
-		vp := VPEnhanced{}
-		vp[0] = 0x03
-		vp[1] = int2SemiOctet(int(t / time.Hour))
-		vp[2] = int2SemiOctet(int((t % time.Hour) / time.Minute))
-		vp[3] = int2SemiOctet(int((t % time.Minute) / time.Second))
+	}
+	if v, ok := relativeOf(t); ok {
+		return VPRelative(v)
+	}
+	if vp, ok := enhancedOf(t, false); ok {
 		return vp
 	}
+	vp, _ := TimeToSCTimeStamp(r.Add(t))
+	return VPAbsolute(vp)
+}
+
+// MakeValidityPeriod returns VP of the format f from deadend time and
+// single-attempt flag. Absolute format is calculated from the reference
+// time r. Relative format value is rounded up to the next representable
+// duration.
+func MakeValidityPeriod(f VPFormat, t time.Duration, s bool, r time.Time) (ValidityPeriod, error) {
+	if t%time.Second != 0 {
+		t = t.Truncate(time.Second)
+	}
+	if t < 0 && f != VPFormatAbsolute {
+		return nil, ErrInvalidValidityPeriod
+	}
 
-	vp := marshalSCTimeStamp(time.Now().Add(t))
-	return VPAbsolute{vp[0], vp[1], vp[2], vp[3], vp[4], vp[5], vp[6]}
+	switch f {
+	case VPFormatNone:
+		if s || t != 0 {
+			return nil, ErrInvalidValidityPeriod
+		}
+		return nil, nil
+	case VPFormatRelative:
+		if s {
+			return nil, ErrInvalidValidityPeriod
+		}
+		if v, ok := relativeCeil(t); ok {
+			return VPRelative(v), nil
+		}
+	case VPFormatEnhanced:
+		if vp, ok := enhancedOf(t, s); ok {
+			return vp, nil
+		}
+		if v, ok := relativeCeil(t); ok {
+			return EnhancedRelativeVP(VPRelative(v), s), nil
+		}
+	case VPFormatAbsolute:
+		if s {
+			return nil, ErrInvalidValidityPeriod
+		}
+		vp, e := TimeToSCTimeStamp(r.Add(t))
+		if e != nil {
+			return nil, e
+		}
+		return VPAbsolute(vp), nil
+	}
+	return nil, ErrInvalidValidityPeriod
+}
+
+// relativeOf returns relative format value that is exactly same as t
+func relativeOf(t time.Duration) (byte, bool) {
+	switch {
+	case t%week == 0 && t >= week*5 && t <= week*63:
+		return byte(t/week) + 192, true
+	case t%day == 0 && t >= day*2 && t <= day*30:
+		return byte(t/day) + 166, true
+	case t%(time.Minute*30) == 0 && t <= day && t >= time.Hour*12+time.Minute*30:
+		return byte((t-time.Hour*12)/(time.Minute*30)) + 143, true
+	case t%(time.Minute*5) == 0 && t <= time.Hour*12 && t >= time.Minute*5:
+		return byte(t/(time.Minute*5)) - 1, true
+	}
+	return 0, false
+}
+
+// relativeCeil returns relative format value that is not shorter than t
+func relativeCeil(t time.Duration) (byte, bool) {
+	ceil := func(t, u time.Duration) time.Duration {
+		return (t + u - 1) / u
+	}
+	switch {
+	case t <= time.Minute*5:
+		return 0, true
+	case t <= time.Hour*12:
+		return byte(ceil(t, time.Minute*5)) - 1, true
+	case t <= day:
+		return byte(ceil(t-time.Hour*12, time.Minute*30)) + 143, true
+	case t <= day*30:
+		return byte(ceil(t, day)) + 166, true
+	case t <= week*63:
+		return byte(ceil(t, week)) + 192, true
+	}
+	return 0, false
+}
+
+// enhancedOf returns enhanced format value that is exactly same as t
+func enhancedOf(t time.Duration, s bool) (VPEnhanced, bool) {
+	if t == 0 {
+		return EnhancedNoVP(s), true
+	}
+	if v, ok := relativeOf(t); ok {
+		return EnhancedRelativeVP(VPRelative(v), s), true
+	}
+	if t <= time.Second*255 {
+		return EnhancedSecondsVP(byte(t/time.Second), s), true
+	}
+	if t <= time.Hour*99+time.Minute*59+time.Second*59 {
+		vp, _ := EnhancedHHMMSSVP(
+			int(t/time.Hour),
+			int((t%time.Hour)/time.Minute),
+			int((t%time.Minute)/time.Second), s)
+		return vp, true
+	}
+	return VPEnhanced{}, false
 }
 
 // VPRelative is relative format VP value
@@ -150,17 +287,19 @@ func (f VPRelative) Equal(b ValidityPeriod) bool {
 type VPAbsolute [7]byte
 
 func (f VPAbsolute) String() string {
-	return fmt.Sprintf("absolute, %s", unmarshalSCTimeStamp(f))
+	return fmt.Sprintf("absolute, %s", SCTimeStamp(f))
 }
 
 // ExpireTime return expire time
 func (f VPAbsolute) ExpireTime(t time.Time) time.Time {
-	return unmarshalSCTimeStamp(f)
+	r, _ := SCTimeStamp(f).Time()
+	return r
 }
 
 // Duration return duration to expire time
 func (f VPAbsolute) Duration() time.Duration {
-	return time.Until(unmarshalSCTimeStamp(f))
+	r, _ := SCTimeStamp(f).Time()
+	return time.Until(r)
 }
 
 // SingleAttempt return single attempt is required or not
@@ -182,66 +321,177 @@ func (f VPAbsolute) Equal(b ValidityPeriod) bool {
 	return true
 }
 
+// EnhancedFormat is validity period format of enhanced VP
+type EnhancedFormat byte
+
+const (
+	// EnhancedNone means no validity period specified
+	EnhancedNone EnhancedFormat = 0x00
+	// EnhancedRelative means relative format value
+	EnhancedRelative EnhancedFormat = 0x01
+	// EnhancedSeconds means relative integer seconds value
+	EnhancedSeconds EnhancedFormat = 0x02
+	// EnhancedHHMMSS means relative hours, minutes and seconds value
+	EnhancedHHMMSS EnhancedFormat = 0x03
+)
+
 // VPEnhanced is enhanced format VP value
 type VPEnhanced [7]byte
 
+// EnhancedNoVP make enhanced format VP without validity period
+func EnhancedNoVP(s bool) (f VPEnhanced) {
+	f.setIndicator(EnhancedNone, s)
+	return
+}
+
+// EnhancedRelativeVP make enhanced format VP with relative format value
+func EnhancedRelativeVP(v VPRelative, s bool) (f VPEnhanced) {
+	f.setIndicator(EnhancedRelative, s)
+	f[1] = byte(v)
+	return
+}
+
+// EnhancedSecondsVP make enhanced format VP with integer seconds
+func EnhancedSecondsVP(v byte, s bool) (f VPEnhanced) {
+	f.setIndicator(EnhancedSeconds, s)
+	f[1] = v
+	return
+}
+
+// EnhancedHHMMSSVP make enhanced format VP with hours, minutes and seconds
+func EnhancedHHMMSSVP(h, m, sec int, s bool) (f VPEnhanced, e error) {
+	if h < 0 || h > 99 || m < 0 || m > 59 || sec < 0 || sec > 59 {
+		e = ErrInvalidValidityPeriod
+		return
+	}
+	f.setIndicator(EnhancedHHMMSS, s)
+	f[1] = int2SemiOctet(h)
+	f[2] = int2SemiOctet(m)
+	f[3] = int2SemiOctet(sec)
+	return
+}
+
+func (f *VPEnhanced) setIndicator(v EnhancedFormat, s bool) {
+	f[0] = byte(v) & 0x07
+	if s {
+		f[0] |= 0x40
+	}
+}
+
+// Extended returns the functionality indicator has extension octets
+func (f VPEnhanced) Extended() bool {
+	return f[0]&0x80 == 0x80
+}
+
+// Extension returns extension octets of the functionality indicator
+func (f VPEnhanced) Extension() []byte {
+	return f[1:f.offset()]
+}
+
+// Format returns validity period format of this VP
+func (f VPEnhanced) Format() EnhancedFormat {
+	return EnhancedFormat(f[0] & 0x07)
+}
+
+// Relative returns relative format value
+func (f VPEnhanced) Relative() (VPRelative, bool) {
+	o := f.offset()
+	if f.Format() != EnhancedRelative || o >= len(f) {
+		return 0, false
+	}
+	return VPRelative(f[o]), true
+}
+
+// Seconds returns integer seconds value
+func (f VPEnhanced) Seconds() (byte, bool) {
+	o := f.offset()
+	if f.Format() != EnhancedSeconds || o >= len(f) {
+		return 0, false
+	}
+	return f[o], true
+}
+
+// HHMMSS returns hours, minutes and seconds value
+func (f VPEnhanced) HHMMSS() (h, m, s int, ok bool) {
+	if f.Format() != EnhancedHHMMSS {
+		return
+	}
+	o := f.offset()
+	if o+2 >= len(f) {
+		return
+	}
+	h = semiOctet2Int(f[o])
+	m = semiOctet2Int(f[o+1])
+	s = semiOctet2Int(f[o+2])
+	ok = m < 60 && s < 60
+	return
+}
+
+// offset returns index of the validity period value
+func (f VPEnhanced) offset() int {
+	i := 0
+	for i < len(f)-1 && f[i]&0x80 == 0x80 {
+		i++
+	}
+	return i + 1
+}
+
 func (f VPEnhanced) String() string {
 	var s bytes.Buffer
 	s.WriteString("enhanced")
-	if f[0]&0x40 == 0x40 {
+	if f.SingleAttempt() {
 		s.WriteString(", single-shot")
 	}
-	switch f[0] & 0x03 {
-	case 0x00:
+	switch f.Format() {
+	case EnhancedNone:
 		s.WriteString(", no validity period")
-	case 0x01:
-		s.WriteString(", ")
-		s.WriteString(relativeFormatString(f[1]))
-	case 0x02:
-		s.WriteString(fmt.Sprintf(", %d sec", f[1]))
-	case 0x03:
-		s.WriteString(fmt.Sprintf(", %d:%02d:%02d",
-			semiOctet2Int(f[1]),
-			semiOctet2Int(f[2])%60,
-			semiOctet2Int(f[3])%60))
-	default:
-		s.WriteString(", invalid format")
+		return s.String()
+	case EnhancedRelative:
+		if v, ok := f.Relative(); ok {
+			s.WriteString(", ")
+			s.WriteString(relativeFormatString(byte(v)))
+			return s.String()
+		}
+	case EnhancedSeconds:
+		if v, ok := f.Seconds(); ok {
+			s.WriteString(fmt.Sprintf(", %d sec", v))
+			return s.String()
+		}
+	case EnhancedHHMMSS:
+		if h, m, sec, ok := f.HHMMSS(); ok {
+			s.WriteString(fmt.Sprintf(", %d:%02d:%02d", h, m, sec))
+			return s.String()
+		}
 	}
+	s.WriteString(", invalid format")
 	return s.String()
 }
 
 // ExpireTime return expire time
 func (f VPEnhanced) ExpireTime(t time.Time) time.Time {
-	switch f[0] & 0x03 {
-	case 0x00:
-		return time.Time{}
-	case 0x01:
-		return t.Add(relativeFormatDuration(f[1]))
-	case 0x02:
-		return t.Add(time.Duration(f[1]) * time.Second)
-	case 0x03:
-		i := time.Duration(semiOctet2Int(f[1])) * time.Hour
-		i += time.Duration(semiOctet2Int(f[2])%60) * time.Minute
-		i += time.Duration(semiOctet2Int(f[3])%60) * time.Second
-		return t.Add(i)
+	switch f.Format() {
+	case EnhancedRelative, EnhancedSeconds, EnhancedHHMMSS:
+		return t.Add(f.Duration())
 	}
 	return time.Time{}
 }
 
 // Duration return duration to expire time
 func (f VPEnhanced) Duration() time.Duration {
-	switch f[0] & 0x03 {
-	case 0x00:
-		return time.Duration(0)
-	case 0x01:
-		return relativeFormatDuration(f[1])
-	case 0x02:
-		return time.Duration(f[1]) * time.Second
-	case 0x03:
-		i := time.Duration(semiOctet2Int(f[1])) * time.Hour
-		i += time.Duration(semiOctet2Int(f[2])%60) * time.Minute
-		i += time.Duration(semiOctet2Int(f[3])%60) * time.Second
-		return i
+	switch f.Format() {
+	case EnhancedRelative:
+		if v, ok := f.Relative(); ok {
+			return relativeFormatDuration(byte(v))
+		}
+	case EnhancedSeconds:
+		if v, ok := f.Seconds(); ok {
+			return time.Duration(v) * time.Second
+		}
+	case EnhancedHHMMSS:
+		h, m, s, _ := f.HHMMSS()
+		return time.Duration(h)*time.Hour +
+			time.Duration(m%60)*time.Minute +
+			time.Duration(s%60)*time.Second
 	}
 	return time.Duration(0)
 }
@@ -257,10 +507,5 @@ func (f VPEnhanced) Equal(b ValidityPeriod) bool {
 	if !ok {
 		return false
 	}
-	for i := range f {
-		if a[i] != f[i] {
-			return false
-		}
-	}
-	return true
+	return a == f
 }
@@ -53,3 +53,96 @@ func TestDurationValue(t *testing.T) {
 		}
 	}
 }
+
+func TestValidityPeriodAt(t *testing.T) {
+	ref := time.Date(2011, time.March, 22, 14, 25, 40, 0, time.UTC)
+	d := time.Hour*100 + time.Second
+	vp1 := sms.ValidityPeriodAt(d, false, ref)
+	vp2 := sms.ValidityPeriodAt(d, false, ref)
+	t.Log(vp1)
+	if _, ok := vp1.(sms.VPAbsolute); !ok {
+		t.Fatalf("unexpected VP format: %s", vp1)
+	}
+	if !vp1.Equal(vp2) {
+		t.Fatalf("VP mismatch: %s, %s", vp1, vp2)
+	}
+	if exp := vp1.ExpireTime(ref); !exp.Equal(ref.Add(d)) {
+		t.Fatalf("expire mismatch: %s", exp)
+	}
+}
+
+func TestValidityPeriodAtSingleAttempt(t *testing.T) {
+	ref := time.Date(2011, time.March, 22, 14, 25, 40, 0, time.UTC)
+	for _, c := range []struct {
+		d, exp time.Duration
+	}{
+		{time.Hour * 30, time.Hour * 30},
+		{time.Hour*100 + time.Second, time.Hour * 24 * 5},
+		{time.Hour * 24 * 500, time.Hour * 24 * 7 * 63},
+	} {
+		vp := sms.ValidityPeriodAt(c.d, true, ref)
+		t.Log(vp)
+		if _, ok := vp.(sms.VPEnhanced); !ok || !vp.SingleAttempt() {
+			t.Fatalf("single attempt is dropped: %s", vp)
+		}
+		if vp.Duration() != c.exp {
+			t.Fatalf("duration mismatch: %s", vp.Duration())
+		}
+	}
+}
+
+func TestEnhancedVP(t *testing.T) {
+	vp := sms.EnhancedRelativeVP(sms.VPRelative(167), true)
+	if v, ok := vp.Relative(); !ok || v != 167 || !vp.SingleAttempt() {
+		t.Fatalf("relative value mismatch: %s", vp)
+	}
+	if vp.Duration() != time.Hour*24 {
+		t.Fatalf("duration mismatch: %s", vp.Duration())
+	}
+
+	vp = sms.EnhancedSecondsVP(200, false)
+	if v, ok := vp.Seconds(); !ok || v != 200 || vp.SingleAttempt() {
+		t.Fatalf("seconds value mismatch: %s", vp)
+	}
+
+	vp, e := sms.EnhancedHHMMSSVP(12, 34, 56, false)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if h, m, s, ok := vp.HHMMSS(); !ok || h != 12 || m != 34 || s != 56 {
+		t.Fatalf("HH:MM:SS value mismatch: %s", vp)
+	}
+	if _, e = sms.EnhancedHHMMSSVP(1, 60, 0, false); e == nil {
+		t.Fatal("invalid minutes is accepted")
+	}
+
+	vp = sms.VPEnhanced{0x82, 0x00, 0x1e}
+	if !vp.Extended() || len(vp.Extension()) != 1 {
+		t.Fatalf("extension mismatch: %s", vp)
+	}
+	if v, ok := vp.Seconds(); !ok || v != 30 {
+		t.Fatalf("extended seconds value mismatch: %s", vp)
+	}
+}
+
+func TestMakeValidityPeriod(t *testing.T) {
+	ref := time.Date(2011, time.March, 22, 14, 25, 40, 0, time.UTC)
+	for _, f := range []sms.VPFormat{
+		sms.VPFormatRelative, sms.VPFormatEnhanced, sms.VPFormatAbsolute} {
+		vp, e := sms.MakeValidityPeriod(f, time.Hour*3+time.Minute*2, false, ref)
+		if e != nil {
+			t.Fatal(e)
+		}
+		t.Log(vp)
+		if sms.VPFormatOf(vp) != f {
+			t.Fatalf("format mismatch: %s", vp)
+		}
+		if vp.ExpireTime(ref).Before(ref.Add(time.Hour*3 + time.Minute*2)) {
+			t.Fatalf("expire time is too early: %s", vp.ExpireTime(ref))
+		}
+	}
+	if _, e := sms.MakeValidityPeriod(
+		sms.VPFormatRelative, time.Hour, true, ref); e == nil {
+		t.Fatal("single attempt relative format is accepted")
+	}
+}
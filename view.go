@@ -0,0 +1,470 @@
+package sms
+
+import (
+	"bytes"
+	"io"
+)
+
+// AddrView is zero-copy view of address field
+// that starts from the length octet
+type AddrView struct {
+	b  []byte
+	rp bool
+}
+
+// Bytes returns raw data of the address field
+func (a AddrView) Bytes() []byte {
+	return a.b
+}
+
+// TON returns type of number of the address
+func (a AddrView) TON() byte {
+	if len(a.b) < 2 {
+		return 0
+	}
+	return (a.b[1] >> 4) & 0x07
+}
+
+// NPI returns numbering plan identification of the address
+func (a AddrView) NPI() byte {
+	if len(a.b) < 2 {
+		return 0
+	}
+	return a.b[1] & 0x0f
+}
+
+// Digits returns raw semi-octets or packed 7bit data of the address
+func (a AddrView) Digits() []byte {
+	if len(a.b) < 2 {
+		return nil
+	}
+	return a.b[2:]
+}
+
+// Equal reports the address is same as b, without decoding
+func (a AddrView) Equal(b Address) bool {
+	if len(a.b) < 2 {
+		return b.Addr == nil
+	}
+	l, t := b.header()
+	if t != a.b[1] {
+		return false
+	}
+	if !a.rp && l != a.b[0] {
+		return false
+	}
+	var d []byte
+	if b.Addr != nil {
+		d = b.Addr.Bytes()
+	}
+	v := a.b[2:]
+	if len(d) != len(v) {
+		return false
+	}
+	n := len(v) - 1
+	if n < 0 || a.TON() == TypeAlphanumeric ||
+		(a.rp && v[n]&0xf0 != 0xf0) || (!a.rp && a.b[0]%2 == 0) {
+		return bytes.Equal(v, d)
+	}
+	// ignore filler of odd number of digits
+	return bytes.Equal(v[:n], d[:n]) && v[n]&0x0f == d[n]&0x0f
+}
+
+// Address decodes the address
+func (a AddrView) Address() (Address, error) {
+	if a.rp {
+		return readRPAddr(bytes.NewReader(a.b), "")
+	}
+	return readTPAddr(bytes.NewReader(a.b))
+}
+
+func (a AddrView) String() string {
+	d, e := a.Address()
+	if e != nil {
+		return e.Error()
+	}
+	return d.String()
+}
+
+// TPView is zero-copy view of TPDU data.
+// Only position of the fields is checked when the view is made,
+// and each field is decoded when it is accessed.
+type TPView struct {
+	b  []byte
+	mo bool
+
+	// offset of the fields, 0 means the field is not available
+	mr, addr, pid, dcs, udl int
+}
+
+// ViewTPMO make TPView of TPDU data from MS to SC
+func ViewTPMO(b []byte) (TPView, error) {
+	return viewTP(b, true)
+}
+
+// ViewTPMT make TPView of TPDU data from SC to MS
+func ViewTPMT(b []byte) (TPView, error) {
+	return viewTP(b, false)
+}
+
+func viewTP(b []byte, mo bool) (v TPView, e error) {
+	v.b = b
+	v.mo = mo
+	if len(b) == 0 {
+		return v, DecodeError{Layer: "TP", Field: "TP-MTI", Err: io.EOF}
+	}
+
+	p := 1
+	switch mti := b[0] & 0x03; {
+	case mo && mti == 0x00, !mo && mti == 0x01:
+		// DeliverReport and SubmitReport has no field to view
+		return
+	case mo && mti == 0x01:
+		v.mr = 1
+		if p, e = v.viewAddr("TP-DA", 2); e != nil {
+			return
+		}
+		v.pid = p
+		v.dcs = p + 1
+		p += 2
+		switch b[0] & 0x18 {
+		case 0x10:
+			p++
+		case 0x08, 0x18:
+			p += 7
+		}
+		v.udl = p
+	case mo && mti == 0x02:
+		v.mr = 1
+		v.pid = 2
+		if p, e = v.viewAddr("TP-DA", 5); e != nil {
+			return
+		}
+		v.udl = p
+	case !mo && mti == 0x00:
+		if p, e = v.viewAddr("TP-OA", 1); e != nil {
+			return
+		}
+		v.pid = p
+		v.dcs = p + 1
+		v.udl = p + 9
+	case !mo && mti == 0x02:
+		v.mr = 1
+		if p, e = v.viewAddr("TP-RA", 2); e != nil {
+			return
+		}
+		if p += 15; p > len(b) {
+			return v, DecodeError{Layer: "TP", Field: "TP-ST",
+				Offset: len(b), Err: io.EOF}
+		}
+		if p == len(b) {
+			return
+		}
+		pi := b[p]
+		p++
+		if pi&0x01 == 0x01 {
+			v.pid = p
+			p++
+		}
+		if pi&0x02 == 0x02 {
+			v.dcs = p
+			p++
+		}
+		if pi&0x04 == 0x04 {
+			v.udl = p
+		}
+	default:
+		return v, DecodeError{Layer: "TP", Field: "TP-MTI",
+			Err: UnknownMessageTypeError{Actual: mti}}
+	}
+
+	for _, f := range [...]struct {
+		n string
+		o int
+	}{{"TP-PID", v.pid}, {"TP-DCS", v.dcs}, {"TP-UDL", v.udl}} {
+		if f.o >= len(b) {
+			return v, DecodeError{Layer: "TP", Field: f.n,
+				Offset: len(b), Err: io.EOF}
+		}
+	}
+	return
+}
+
+func (v *TPView) viewAddr(f string, p int) (int, error) {
+	if p >= len(v.b) {
+		return 0, DecodeError{Layer: "TP", Field: f, Offset: p, Err: io.EOF}
+	}
+	l := v.b[p]
+	if l > 20 {
+		return 0, DecodeError{Layer: "TP", Field: f, Offset: p, Err: ErrInvalidLength}
+	}
+	n := p + 2 + int(l+1)/2
+	if n > len(v.b) {
+		return 0, DecodeError{Layer: "TP", Field: f, Offset: p, Err: io.EOF}
+	}
+	v.addr = p
+	return n, nil
+}
+
+// Bytes returns raw data of the TPDU
+func (v TPView) Bytes() []byte {
+	return v.b
+}
+
+// MTI returns TP-MTI
+func (v TPView) MTI() byte {
+	return v.b[0] & 0x03
+}
+
+// MR returns TP-MR of Submit, Command or StatusReport
+func (v TPView) MR() (byte, bool) {
+	if v.mr == 0 {
+		return 0, false
+	}
+	return v.b[v.mr], true
+}
+
+// Addr returns TP-DA of Submit and Command, TP-OA of Deliver
+// or TP-RA of StatusReport
+func (v TPView) Addr() (AddrView, bool) {
+	if v.addr == 0 {
+		return AddrView{}, false
+	}
+	return AddrView{b: v.b[v.addr : v.addr+2+int(v.b[v.addr]+1)/2]}, true
+}
+
+// PID returns TP-PID
+func (v TPView) PID() (byte, bool) {
+	if v.pid == 0 {
+		return 0, false
+	}
+	return v.b[v.pid], true
+}
+
+// DCS returns raw TP-DCS
+func (v TPView) DCS() (byte, bool) {
+	if v.dcs == 0 {
+		return 0, false
+	}
+	return v.b[v.dcs], true
+}
+
+// UDHI returns TP-UDHI
+func (v TPView) UDHI() bool {
+	return v.b[0]&0x40 == 0x40
+}
+
+// UDL returns TP-UDL or TP-CDL
+func (v TPView) UDL() (byte, bool) {
+	if v.udl == 0 {
+		return 0, false
+	}
+	return v.b[v.udl], true
+}
+
+// UD returns raw TP-UD or TP-CD, that is the data after TP-UDL
+func (v TPView) UD() []byte {
+	if v.udl == 0 {
+		return nil
+	}
+	return v.b[v.udl+1:]
+}
+
+// TPDU decode whole TPDU data of the view
+func (v TPView) TPDU() (TPDU, error) {
+	if v.mo {
+		return UnmarshalTPMO(v.b)
+	}
+	return UnmarshalTPMT(v.b)
+}
+
+// RPView is zero-copy view of RPDU data
+type RPView struct {
+	b  []byte
+	mo bool
+
+	// offset of the fields, 0 means the field is not available
+	addr, ud int
+}
+
+// ViewRPMO make RPView of RPDU data from MS to network
+func ViewRPMO(b []byte) (RPView, error) {
+	return viewRP(b, true)
+}
+
+// ViewRPMT make RPView of RPDU data from network to MS
+func ViewRPMT(b []byte) (RPView, error) {
+	return viewRP(b, false)
+}
+
+func viewRP(b []byte, mo bool) (v RPView, e error) {
+	v.b = b
+	v.mo = mo
+	if len(b) < 2 {
+		f := "RP-MR"
+		if len(b) == 0 {
+			f = "RP-MTI"
+		}
+		return v, DecodeError{Layer: "RP", Field: f, Offset: len(b), Err: io.EOF}
+	}
+
+	p := 2
+	switch mti := b[0] & 0x07; {
+	case mo && mti == 0x00, !mo && mti == 0x01:
+		f := "RP-OA"
+		if mo {
+			f = "RP-DA"
+			p++ // RP-OA
+		}
+		if p >= len(b) || p+1+int(b[p]) > len(b) {
+			return v, DecodeError{Layer: "RP", Field: f, Offset: p, Err: io.EOF}
+		}
+		v.addr = p
+		p += 1 + int(b[p])
+		if !mo {
+			p++ // RP-DA
+		}
+		v.ud = p
+	case mo && mti == 0x02, !mo && mti == 0x03:
+		if p < len(b) && b[p] == 0x41 {
+			v.ud = p + 1
+		}
+	case mo && mti == 0x04, !mo && mti == 0x05:
+		if p < len(b) {
+			p += 1 + int(b[p])
+		}
+		if p < len(b) && b[p] == 0x41 {
+			v.ud = p + 1
+		}
+	case mo && mti == 0x06:
+	default:
+		return v, DecodeError{Layer: "RP", Field: "RP-MTI",
+			Err: UnknownMessageTypeError{Actual: b[0]}}
+	}
+
+	if v.ud != 0 && v.ud >= len(b) {
+		return v, DecodeError{Layer: "RP", Field: "RP-User-Data",
+			Offset: len(b), Err: io.EOF}
+	}
+	return
+}
+
+// Bytes returns raw data of the RPDU
+func (v RPView) Bytes() []byte {
+	return v.b
+}
+
+// MTI returns RP-MTI
+func (v RPView) MTI() byte {
+	return v.b[0] & 0x07
+}
+
+// MR returns RP-MR
+func (v RPView) MR() byte {
+	return v.b[1]
+}
+
+// SCA returns RP-DA of MO RP-DATA or RP-OA of MT RP-DATA
+func (v RPView) SCA() (AddrView, bool) {
+	if v.addr == 0 {
+		return AddrView{}, false
+	}
+	return AddrView{b: v.b[v.addr : v.addr+1+int(v.b[v.addr])], rp: true}, true
+}
+
+// TP returns TPView of RP-User-Data
+func (v RPView) TP() (TPView, error) {
+	if v.ud == 0 {
+		return TPView{}, DecodeError{Layer: "RP", Field: "RP-User-Data",
+			Offset: len(v.b), Err: io.EOF}
+	}
+	l := int(v.b[v.ud])
+	tp := v.b[v.ud+1:]
+	if l > len(tp) {
+		return TPView{}, DecodeError{Layer: "RP", Field: "RP-User-Data",
+			Offset: v.ud, Err: io.EOF}
+	}
+	t, e := viewTP(tp[:l], v.mo)
+	return t, innerError(e, v.b, tp)
+}
+
+// RPDU decode whole RPDU data of the view
+func (v RPView) RPDU() (RPDU, error) {
+	if v.mo {
+		return UnmarshalRPMO(v.b)
+	}
+	return UnmarshalRPMT(v.b)
+}
+
+// CPView is zero-copy view of CPDU data
+type CPView struct {
+	b  []byte
+	mo bool
+}
+
+// ViewCPMO make CPView of CPDU data from MS to network
+func ViewCPMO(b []byte) (CPView, error) {
+	return viewCP(b, true)
+}
+
+// ViewCPMT make CPView of CPDU data from network to MS
+func ViewCPMT(b []byte) (CPView, error) {
+	return viewCP(b, false)
+}
+
+func viewCP(b []byte, mo bool) (CPView, error) {
+	v := CPView{b: b, mo: mo}
+	if len(b) < 2 {
+		f := "CP-MTI"
+		if len(b) == 0 {
+			f = "CP-PD"
+		}
+		return v, DecodeError{Layer: "CP", Field: f, Offset: len(b), Err: io.EOF}
+	}
+	switch b[1] {
+	case 0x01, 0x04, 0x10:
+	default:
+		return v, DecodeError{Layer: "CP", Field: "CP-MTI", Offset: 1,
+			Err: UnknownMessageTypeError{Actual: b[1]}}
+	}
+	return v, nil
+}
+
+// Bytes returns raw data of the CPDU
+func (v CPView) Bytes() []byte {
+	return v.b
+}
+
+// TI returns CP-TI
+func (v CPView) TI() byte {
+	return v.b[0] >> 4
+}
+
+// MTI returns CP-MTI
+func (v CPView) MTI() byte {
+	return v.b[1]
+}
+
+// RP returns RPView of CP-User-Data of CP-DATA
+func (v CPView) RP() (RPView, error) {
+	if v.b[1] != 0x01 || len(v.b) < 3 {
+		return RPView{}, DecodeError{Layer: "CP", Field: "CP-User-Data",
+			Offset: 2, Err: io.EOF}
+	}
+	l := int(v.b[2])
+	rp := v.b[3:]
+	if l > len(rp) {
+		return RPView{}, DecodeError{Layer: "CP", Field: "CP-User-Data",
+			Offset: 2, Err: io.EOF}
+	}
+	r, e := viewRP(rp[:l], v.mo)
+	return r, innerError(e, v.b, rp)
+}
+
+// CPDU decode whole CPDU data of the view
+func (v CPView) CPDU() (CPDU, error) {
+	if v.mo {
+		return UnmarshalCPMO(v.b)
+	}
+	return UnmarshalCPMT(v.b)
+}
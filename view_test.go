@@ -0,0 +1,144 @@
+package sms_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fkgi/sms"
+)
+
+func TestViewSubmit(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		p := randSubmit()
+		v, e := sms.ViewCPMO(p.MarshalCP())
+		if e != nil {
+			t.Fatalf("view failed: %s", e)
+		}
+		rv, e := v.RP()
+		if e != nil {
+			t.Fatalf("view failed: %s", e)
+		}
+		if a, ok := rv.SCA(); !ok || !a.Equal(p.SCA) {
+			t.Fatalf("RP-DA mismatch: %s", a)
+		}
+		tv, e := rv.TP()
+		if e != nil {
+			t.Fatalf("view failed: %s", e)
+		}
+
+		if mr, _ := tv.MR(); mr != p.TMR || tv.MTI() != 0x01 {
+			t.Errorf("TP-MR mismatch")
+		}
+		if a, ok := tv.Addr(); !ok || !a.Equal(p.DA) {
+			t.Errorf("TP-DA mismatch: %s %s", a, p.DA)
+		}
+		if pid, _ := tv.PID(); pid != p.PID {
+			t.Errorf("TP-PID mismatch")
+		}
+		if dcs, _ := tv.DCS(); p.DCS != nil && dcs != p.DCS.Marshal() {
+			t.Errorf("TP-DCS mismatch")
+		}
+		if tv.UDHI() != (len(p.UD.UDH) != 0) {
+			t.Errorf("TP-UDHI mismatch")
+		}
+		tp := p.MarshalTP()
+		if udl, _ := tv.UDL(); !bytes.HasSuffix(tp, append([]byte{udl}, tv.UD()...)) {
+			t.Errorf("TP-UD mismatch")
+		}
+
+		c, e := v.CPDU()
+		if e != nil {
+			t.Fatalf("decode failed: %s", e)
+		}
+		if e = compareTPSubmit(p, c.(sms.Submit)); e != nil {
+			t.Error(e)
+		}
+	}
+}
+
+func TestViewOthers(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		d := randDeliver()
+		v, e := sms.ViewTPMT(d.MarshalTP())
+		if e != nil {
+			t.Fatalf("view failed: %s", e)
+		}
+		if a, ok := v.Addr(); !ok || !a.Equal(d.OA) {
+			t.Errorf("TP-OA mismatch: %s %s", a, d.OA)
+		}
+		if _, ok := v.MR(); ok {
+			t.Errorf("Deliver has no TP-MR")
+		}
+
+		s := randStatusreport()
+		if v, e = sms.ViewTPMT(s.MarshalTP()); e != nil {
+			t.Fatalf("view failed: %s", e)
+		}
+		if mr, _ := v.MR(); mr != s.TMR {
+			t.Errorf("TP-MR mismatch")
+		}
+		if a, ok := v.Addr(); !ok || !a.Equal(s.RA) {
+			t.Errorf("TP-RA mismatch: %s %s", a, s.RA)
+		}
+		if pid, ok := v.PID(); ok != (s.PID != nil) || ok && pid != *s.PID {
+			t.Errorf("TP-PID mismatch")
+		}
+
+		c := randCommand()
+		if v, e = sms.ViewTPMO(c.MarshalTP()); e != nil {
+			t.Fatalf("view failed: %s", e)
+		}
+		if a, ok := v.Addr(); !ok || !a.Equal(c.DA) {
+			t.Errorf("TP-DA mismatch: %s %s", a, c.DA)
+		}
+		if l, _ := v.UDL(); int(l) != len(v.UD()) {
+			t.Errorf("TP-CD mismatch")
+		}
+	}
+
+	if _, e := sms.ViewTPMO(lenientSubmit[:5]); e == nil {
+		t.Errorf("broken data is viewed")
+	}
+	if _, e := sms.ViewRPMO([]byte{0x07, 0x00}); e == nil {
+		t.Errorf("unknown type is viewed")
+	}
+}
+
+func TestViewAllocs(t *testing.T) {
+	p := benchSubmit()
+	b := p.MarshalRP()
+	da := p.DA
+	n := testing.AllocsPerRun(100, func() {
+		v, _ := sms.ViewRPMO(b)
+		tv, _ := v.TP()
+		a, _ := tv.Addr()
+		tv.PID()
+		tv.DCS()
+		if !a.Equal(da) {
+			t.Fatalf("TP-DA mismatch")
+		}
+	})
+	if n != 0 {
+		t.Errorf("view allocates %f times", n)
+	}
+}
+
+func BenchmarkViewSubmit(b *testing.B) {
+	d := benchSubmit().MarshalRP()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v, _ := sms.ViewRPMO(d)
+		tv, _ := v.TP()
+		tv.Addr()
+		tv.PID()
+		tv.DCS()
+	}
+}
+
+func BenchmarkUnmarshalSubmit(b *testing.B) {
+	d := benchSubmit().MarshalRP()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sms.UnmarshalRPMO(d)
+	}
+}